@@ -21,6 +21,11 @@ type Callbacks struct {
 	// icon name changes.
 	IconName func(string)
 
+	// WindowRequest callback. When set, this function is called when a
+	// program requests to manipulate the window using XTWINOPS, such as
+	// resizing, moving, or iconifying it.
+	WindowRequest func(req WindowRequest)
+
 	// AltScreen callback. When set, this function is called when the alternate
 	// screen is activated or deactivated.
 	AltScreen func(bool)
@@ -49,10 +54,44 @@ type Callbacks struct {
 	// foreground color changes. Nil indicates the default terminal color.
 	ForegroundColor func(color color.Color)
 
+	// PaletteColor callback. When set, this function is called when a palette
+	// color changes. Indices 0 through 255 are the indexed colors and indices
+	// 256 through 260 are the special colors, see [SpecialColorBold]. Nil
+	// indicates the default color.
+	PaletteColor func(index int, color color.Color)
+
 	// WorkingDirectory callback. When set, this function is called when the
 	// current working directory changes.
 	WorkingDirectory func(string)
 
+	// Notification callback. When set, this function is called when a
+	// program sends a desktop notification using OSC 9, OSC 777, or OSC 99.
+	Notification func(n Notification)
+
+	// CloseNotification callback. When set, this function is called when a
+	// program closes the OSC 99 notification with the given identifier.
+	CloseNotification func(id string)
+
+	// Progress callback. When set, this function is called when a program
+	// sets the progress bar state using OSC 9;4.
+	Progress func(p Progress)
+
+	// Attention callback. When set, this function is called when a program
+	// requests the user's attention using OSC 1337 RequestAttention.
+	Attention func(a Attention)
+
+	// Clipboard callback. When set, this function is called when a program
+	// sets the content of a clipboard selection using OSC 52. The selection is
+	// one of 'c' (clipboard), 'p' (primary), 'q' (secondary), 's' (select), or
+	// '0' through '7' (cut buffers). An empty content clears the selection.
+	Clipboard func(selection byte, content string)
+
+	// FrameComplete callback. When set, this function is called when a
+	// synchronized update using [ansi.ModeSynchronizedOutput] ends, either
+	// because the application reset the mode or because the update timed
+	// out. Host renderers can use it to present complete frames.
+	FrameComplete func()
+
 	// EnableMode callback. When set, this function is called when a mode is
 	// enabled.
 	EnableMode func(mode ansi.Mode)
@@ -0,0 +1,111 @@
+package vt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ClipboardProvider provides the host clipboard content to answer OSC 52
+// clipboard queries.
+type ClipboardProvider interface {
+	// ReadClipboard returns the content of the given selection. It returns
+	// false if the selection is not available.
+	ReadClipboard(selection byte) (string, bool)
+}
+
+// ClipboardPolicy controls the access of programs running in the terminal to
+// the host clipboard using OSC 52.
+type ClipboardPolicy uint8
+
+// Clipboard policies.
+const (
+	// ClipboardAllowWrite allows programs to set the clipboard content.
+	ClipboardAllowWrite ClipboardPolicy = 1 << iota
+	// ClipboardAllowRead allows programs to query the clipboard content.
+	ClipboardAllowRead
+
+	// ClipboardDenyAll denies both reading and writing the clipboard.
+	ClipboardDenyAll ClipboardPolicy = 0
+	// ClipboardAllowAll allows both reading and writing the clipboard.
+	ClipboardAllowAll = ClipboardAllowWrite | ClipboardAllowRead
+)
+
+// DefaultClipboardPolicy is the default clipboard policy. It allows writing
+// to the clipboard but not reading from it.
+const DefaultClipboardPolicy = ClipboardAllowWrite
+
+// SetClipboardProvider sets the provider used to answer clipboard queries.
+// Queries are ignored when no provider is set.
+func (e *Emulator) SetClipboardProvider(p ClipboardProvider) {
+	e.clipboard = p
+}
+
+// SetClipboardPolicy sets the clipboard access policy.
+func (e *Emulator) SetClipboardPolicy(p ClipboardPolicy) {
+	e.clipboardPolicy = p
+}
+
+// handleClipboard handles OSC 52 clipboard sequences.
+//
+//	OSC 52 ; Pc ; Pd ST
+//
+// Pc is a list of selections to operate on. Pd is the base64 encoded content
+// to set, "?" to query the content, or anything else to clear it.
+func (e *Emulator) handleClipboard(cmd int, data []byte) {
+	parts := bytes.SplitN(data, []byte{';'}, 3)
+	if len(parts) != 3 || cmd != 52 {
+		// Invalid, ignore
+		return
+	}
+
+	var sels []byte
+	for _, c := range parts[1] {
+		switch {
+		case c == 'c', c == 'p', c == 'q', c == 's', c >= '0' && c <= '7':
+			sels = append(sels, c)
+		}
+	}
+	if len(parts[1]) == 0 {
+		// Default to the selection and cut buffer 0 like xterm.
+		sels = []byte{'s', '0'}
+	}
+	if len(sels) == 0 {
+		return
+	}
+
+	if string(parts[2]) == "?" {
+		if e.clipboardPolicy&ClipboardAllowRead == 0 || e.clipboard == nil {
+			e.logf("clipboard read denied")
+			return
+		}
+		for _, sel := range sels {
+			if content, ok := e.clipboard.ReadClipboard(sel); ok {
+				_, _ = io.WriteString(e.pw, ansi.SetClipboard(sel, content))
+				return
+			}
+		}
+		return
+	}
+
+	if e.clipboardPolicy&ClipboardAllowWrite == 0 {
+		e.logf("clipboard write denied")
+		return
+	}
+
+	// Invalid base64 data clears the selections.
+	content, err := base64.StdEncoding.DecodeString(string(parts[2]))
+	if err != nil {
+		content = nil
+	}
+	if e.cb.Clipboard != nil {
+		for _, sel := range sels {
+			e.cb.Clipboard(sel, string(content))
+		}
+	}
+	for _, sel := range sels {
+		emit(&e.events, ClipboardChanged{Selection: sel, Content: string(content)})
+	}
+}
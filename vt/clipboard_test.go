@@ -0,0 +1,74 @@
+package vt
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+type testClipboard map[byte]string
+
+func (c testClipboard) ReadClipboard(selection byte) (string, bool) {
+	s, ok := c[selection]
+	return s, ok
+}
+
+func TestClipboard(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		e := NewEmulator(10, 10)
+		got := map[byte]string{}
+		e.SetCallbacks(Callbacks{
+			Clipboard: func(selection byte, content string) {
+				got[selection] = content
+			},
+		})
+
+		_, _ = e.WriteString(ansi.SetSystemClipboard("hello"))
+		_, _ = e.WriteString("\x1b]52;pq;d29ybGQ=\x07")
+		want := map[byte]string{'c': "hello", 'p': "world", 'q': "world"}
+		for sel, content := range want {
+			if got[sel] != content {
+				t.Errorf("selection %q = %q, want %q", sel, got[sel], content)
+			}
+		}
+
+		_, _ = e.WriteString(ansi.ResetSystemClipboard)
+		if got['c'] != "" {
+			t.Errorf("expected clipboard to be cleared, got %q", got['c'])
+		}
+	})
+
+	t.Run("write denied", func(t *testing.T) {
+		e := NewEmulator(10, 10)
+		called := false
+		e.SetCallbacks(Callbacks{
+			Clipboard: func(byte, string) { called = true },
+		})
+		e.SetClipboardPolicy(ClipboardDenyAll)
+		_, _ = e.WriteString(ansi.SetSystemClipboard("hello"))
+		if called {
+			t.Error("expected clipboard write to be denied")
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		e := NewEmulator(10, 10)
+		e.SetClipboardProvider(testClipboard{'c': "hello"})
+
+		// Reads are denied by default.
+		got := readInput(t, e, func() {
+			_, _ = e.WriteString(ansi.RequestSystemClipboard)
+		})
+		if got != "" {
+			t.Errorf("expected no reply, got %q", got)
+		}
+
+		e.SetClipboardPolicy(ClipboardAllowAll)
+		got = readInput(t, e, func() {
+			_, _ = e.WriteString(ansi.RequestSystemClipboard)
+		})
+		if want := ansi.SetSystemClipboard("hello"); got != want {
+			t.Errorf("reply = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package vt
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// maxKittyKeyboardStack is the maximum number of entries in the Kitty
+// keyboard protocol flags stack. When the stack is full, the oldest entry is
+// evicted.
+const maxKittyKeyboardStack = 16
+
+// kittyKeyboardFlags returns the current Kitty keyboard protocol flags.
+func (s *Screen) kittyKeyboardFlags() int {
+	if len(s.kittyFlags) == 0 {
+		return 0
+	}
+	return s.kittyFlags[len(s.kittyFlags)-1]
+}
+
+// pushKittyKeyboard pushes the given flags onto the Kitty keyboard protocol
+// flags stack.
+func (s *Screen) pushKittyKeyboard(flags int) {
+	if len(s.kittyFlags) >= maxKittyKeyboardStack {
+		s.kittyFlags = s.kittyFlags[1:]
+	}
+	s.kittyFlags = append(s.kittyFlags, flags&ansi.KittyAllFlags)
+}
+
+// popKittyKeyboard pops n entries from the Kitty keyboard protocol flags
+// stack. Popping more entries than the stack holds empties the stack and
+// resets all flags.
+func (s *Screen) popKittyKeyboard(n int) {
+	if n >= len(s.kittyFlags) {
+		s.kittyFlags = s.kittyFlags[:0]
+		return
+	}
+	s.kittyFlags = s.kittyFlags[:len(s.kittyFlags)-n]
+}
+
+// setKittyKeyboard sets the current Kitty keyboard protocol flags. The mode
+// specifies how the flags are applied:
+//
+//	1: Set given flags and unset all others
+//	2: Set given flags and keep existing flags unchanged
+//	3: Unset given flags and keep existing flags unchanged
+func (s *Screen) setKittyKeyboard(flags, mode int) {
+	flags &= ansi.KittyAllFlags
+	cur := s.kittyKeyboardFlags()
+	switch mode {
+	case 1:
+		cur = flags
+	case 2:
+		cur |= flags
+	case 3:
+		cur &^= flags
+	default:
+		return
+	}
+	if len(s.kittyFlags) == 0 {
+		s.kittyFlags = append(s.kittyFlags, cur)
+	} else {
+		s.kittyFlags[len(s.kittyFlags)-1] = cur
+	}
+}
+
+// handleKittyKeyboard handles the Kitty keyboard protocol sequences.
+//
+//	CSI > flags u         Push flags
+//	CSI < n u             Pop n entries
+//	CSI = flags ; mode u  Set flags
+//	CSI ? u               Query flags
+//
+// See: https://sw.kovidgoyal.net/kitty/keyboard-protocol/#progressive-enhancement
+func (e *Emulator) handleKittyKeyboard(prefix byte, params ansi.Params) {
+	switch prefix {
+	case '>':
+		flags, _, _ := params.Param(0, 0)
+		e.scr.pushKittyKeyboard(flags)
+	case '<':
+		n, _, _ := params.Param(0, 1)
+		e.scr.popKittyKeyboard(n)
+	case '=':
+		flags, _, _ := params.Param(0, 0)
+		mode, _, _ := params.Param(1, 1)
+		e.scr.setKittyKeyboard(flags, mode)
+	case '?':
+		flags := e.scr.kittyKeyboardFlags()
+		_, _ = io.WriteString(e.pw, "\x1b[?"+strconv.Itoa(flags)+"u")
+	}
+}
+
+// handleModifyKeys handles the XTerm key modifier options sequences. Only the
+// modifyOtherKeys resource is supported.
+//
+//	CSI > 4 ; value m   Set modifyOtherKeys [ansi.XTMODKEYS]
+//	CSI > 4 m           Reset modifyOtherKeys
+//	CSI ? 4 m           Query modifyOtherKeys [ansi.XTQMODKEYS]
+//
+// It returns false if the resource is not supported.
+func (e *Emulator) handleModifyKeys(query bool, params ansi.Params) bool {
+	res, _, ok := params.Param(0, 0)
+	if !ok || res != 4 {
+		return false
+	}
+
+	if query {
+		_, _ = io.WriteString(e.pw, ansi.XTMODKEYS(4, e.modifyOtherKeys))
+		return true
+	}
+
+	level, _, _ := params.Param(1, 0)
+	if level < 0 || level > 2 {
+		return false
+	}
+	e.modifyOtherKeys = level
+	return true
+}
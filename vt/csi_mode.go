@@ -49,12 +49,15 @@ func (e *Emulator) setAltScreenMode(on bool) {
 	} else {
 		e.scr = &e.scrs[0]
 	}
+	e.scr.damageArea(e.scr.Bounds())
 	if e.cb.AltScreen != nil {
 		e.cb.AltScreen(on)
 	}
+	emit(&e.events, AltScreen{Enabled: on})
 	if e.cb.CursorVisibility != nil {
 		e.cb.CursorVisibility(!e.scr.cur.Hidden)
 	}
+	emit(&e.events, CursorVisibilityChanged{Visible: !e.scr.cur.Hidden})
 }
 
 // saveCursor saves the cursor position.
@@ -90,6 +93,12 @@ func (e *Emulator) setMode(mode ansi.Mode, setting ansi.ModeSetting) {
 			e.saveCursor()
 		}
 		e.setAltScreenMode(setting.IsSet())
+	case ansi.ModeSynchronizedOutput:
+		if setting.IsSet() {
+			e.beginSync()
+		} else {
+			e.endSync()
+		}
 	case ansi.ModeInBandResize:
 		if setting.IsSet() {
 			_, _ = io.WriteString(e.pw, ansi.InBandResize(e.Height(), e.Width(), 0, 0))
@@ -99,10 +108,12 @@ func (e *Emulator) setMode(mode ansi.Mode, setting ansi.ModeSetting) {
 		if e.cb.EnableMode != nil {
 			e.cb.EnableMode(mode)
 		}
+		emit(&e.events, ModeChanged{Mode: mode, Enabled: true})
 	} else if setting.IsReset() {
 		if e.cb.DisableMode != nil {
 			e.cb.DisableMode(mode)
 		}
+		emit(&e.events, ModeChanged{Mode: mode})
 	}
 }
 
@@ -0,0 +1,226 @@
+package vt
+
+import (
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// rectBounds returns the area rectangular area operations are limited to and
+// the origin of their coordinates. When [ansi.DECOM] is set, the coordinates
+// are relative to the margins and operations are clipped to them.
+func (e *Emulator) rectBounds() uv.Rectangle {
+	if e.isModeSet(ansi.DECOM) {
+		return e.scr.ScrollRegion()
+	}
+	return e.scr.Bounds()
+}
+
+// rectArea returns the area of a rectangular area operation given by the
+// 1-based top, left, bottom, and right parameters starting at index i. It
+// returns false if the area is empty.
+func (e *Emulator) rectArea(params ansi.Params, i int) (uv.Rectangle, bool) {
+	bounds := e.rectBounds()
+	param := func(j, def int) int {
+		n, _, _ := params.Param(i+j, def)
+		if n < 1 {
+			return def
+		}
+		return n
+	}
+	top, left := param(0, 1), param(1, 1)
+	bottom, right := param(2, bounds.Dy()), param(3, bounds.Dx())
+	if top > bottom || left > right {
+		return uv.Rectangle{}, false
+	}
+	area := uv.Rect(bounds.Min.X+left-1, bounds.Min.Y+top-1, right-left+1, bottom-top+1)
+	area = area.Intersect(bounds)
+	return area, !area.Empty()
+}
+
+// fillRect fills a rectangular area with the given character using the
+// current pen. This performs DECFRA.
+func (e *Emulator) fillRect(params ansi.Params) {
+	ch, _, _ := params.Param(0, 0)
+	if (ch < 32 || ch > 126) && (ch < 160 || ch > 255) {
+		return
+	}
+	area, ok := e.rectArea(params, 1)
+	if !ok {
+		return
+	}
+	c := uv.Cell{Content: string(rune(ch)), Width: 1, Style: e.scr.cursorPen()}
+	e.scr.FillArea(&c, area)
+}
+
+// eraseRect erases a rectangular area. This performs DECERA.
+func (e *Emulator) eraseRect(params ansi.Params) {
+	area, ok := e.rectArea(params, 0)
+	if !ok {
+		return
+	}
+	e.scr.FillArea(e.scr.blankCell(), area)
+}
+
+// selectiveEraseRect erases the cells in a rectangular area that are not
+// protected by DECSCA. This performs DECSERA.
+func (e *Emulator) selectiveEraseRect(params ansi.Params) {
+	area, ok := e.rectArea(params, 0)
+	if !ok {
+		return
+	}
+	e.scr.selectiveEraseArea(area)
+}
+
+// copyRect copies a rectangular area to another position. The source and
+// destination may overlap. Page parameters are ignored. This performs
+// DECCRA.
+func (e *Emulator) copyRect(params ansi.Params) {
+	src, ok := e.rectArea(params, 0)
+	if !ok {
+		return
+	}
+	bounds := e.rectBounds()
+	top, _, _ := params.Param(5, 1)
+	left, _, _ := params.Param(6, 1)
+	dst := uv.Rect(bounds.Min.X+max(left, 1)-1, bounds.Min.Y+max(top, 1)-1, src.Dx(), src.Dy())
+	dst = dst.Intersect(bounds)
+	if dst.Empty() {
+		return
+	}
+
+	// Copy the source cells first so that overlapping areas work.
+	lines := make([][]uv.Cell, dst.Dy())
+	protected := make([][]bool, dst.Dy())
+	for y := range lines {
+		lines[y] = make([]uv.Cell, dst.Dx())
+		protected[y] = make([]bool, dst.Dx())
+		for x := range lines[y] {
+			sx, sy := src.Min.X+x, src.Min.Y+y
+			protected[y][x] = e.scr.isProtected(sx, sy)
+			c := e.scr.CellAt(sx, sy)
+			switch {
+			case c == nil,
+				c.Width == 0 && c.Content == "" && sx == src.Min.X,
+				c.Width > 1 && sx+c.Width > src.Max.X:
+				// Wide characters cut by the source area become blanks.
+				lines[y][x] = uv.EmptyCell
+			default:
+				lines[y][x] = *c
+			}
+		}
+	}
+
+	for y, line := range lines {
+		for x, c := range line {
+			if c.Width == 0 && c.Content == "" {
+				// Wide character placeholders are set by the buffer.
+				continue
+			}
+			e.scr.SetCell(dst.Min.X+x, dst.Min.Y+y, &c)
+			e.scr.protect(uv.Rect(dst.Min.X+x, dst.Min.Y+y, 1, 1), protected[y][x])
+		}
+	}
+	e.scr.unwrapArea(dst)
+}
+
+// attrAreas returns the areas affected by DECCARA and DECRARA for the given
+// area. Depending on DECSACE, it's either the area itself or the stream of
+// characters from its top left to its bottom right corner.
+func (e *Emulator) attrAreas(area uv.Rectangle) []uv.Rectangle {
+	bounds := e.rectBounds()
+	if e.rectExtent || area.Dy() == 1 {
+		return []uv.Rectangle{area}
+	}
+	first := uv.Rect(area.Min.X, area.Min.Y, bounds.Max.X-area.Min.X, 1)
+	middle := uv.Rect(bounds.Min.X, area.Min.Y+1, bounds.Dx(), area.Dy()-2)
+	last := uv.Rect(bounds.Min.X, area.Max.Y-1, area.Max.X-bounds.Min.X, 1)
+	return []uv.Rectangle{first, middle, last}
+}
+
+// changeRectAttrs changes the attributes of the cells in a rectangular area.
+// This performs DECCARA when reverse is false and DECRARA otherwise.
+func (e *Emulator) changeRectAttrs(params ansi.Params, reverse bool) {
+	area, ok := e.rectArea(params, 0)
+	if !ok {
+		return
+	}
+	attrs := params[min(4, len(params)):]
+	if len(attrs) == 0 {
+		attrs = ansi.Params{0}
+	}
+
+	for _, a := range e.attrAreas(area) {
+		for y := a.Min.Y; y < a.Max.Y; y++ {
+			for x := a.Min.X; x < a.Max.X; x++ {
+				c := e.scr.CellAt(x, y)
+				if c == nil || (c.Width == 0 && c.Content == "") {
+					continue
+				}
+				nc := *c
+				for _, p := range attrs {
+					if reverse {
+						reverseAttr(&nc.Style, p.Param(0))
+					} else {
+						changeAttr(&nc.Style, p.Param(0))
+					}
+				}
+				if !nc.Style.Equal(&c.Style) {
+					e.scr.SetCell(x, y, &nc)
+				}
+			}
+		}
+	}
+}
+
+// changeAttr sets or clears the attribute given by the SGR parameter n.
+func changeAttr(s *uv.Style, n int) {
+	switch n {
+	case 0:
+		s.Attrs &^= uv.AttrBold | uv.AttrBlink | uv.AttrReverse | uv.AttrConceal
+		s.Underline = ansi.UnderlineNone
+	case 1:
+		s.Attrs |= uv.AttrBold
+	case 4:
+		s.Underline = ansi.UnderlineSingle
+	case 5:
+		s.Attrs |= uv.AttrBlink
+	case 7:
+		s.Attrs |= uv.AttrReverse
+	case 8:
+		s.Attrs |= uv.AttrConceal
+	case 22:
+		s.Attrs &^= uv.AttrBold
+	case 24:
+		s.Underline = ansi.UnderlineNone
+	case 25:
+		s.Attrs &^= uv.AttrBlink
+	case 27:
+		s.Attrs &^= uv.AttrReverse
+	case 28:
+		s.Attrs &^= uv.AttrConceal
+	}
+}
+
+// reverseAttr toggles the attribute given by the SGR parameter n. Zero
+// toggles bold, underline, blink, and reverse.
+func reverseAttr(s *uv.Style, n int) {
+	switch n {
+	case 0:
+		s.Attrs ^= uv.AttrBold | uv.AttrBlink | uv.AttrReverse
+		reverseAttr(s, 4)
+	case 1:
+		s.Attrs ^= uv.AttrBold
+	case 4:
+		if s.Underline == ansi.UnderlineNone {
+			s.Underline = ansi.UnderlineSingle
+		} else {
+			s.Underline = ansi.UnderlineNone
+		}
+	case 5:
+		s.Attrs ^= uv.AttrBlink
+	case 7:
+		s.Attrs ^= uv.AttrReverse
+	case 8:
+		s.Attrs ^= uv.AttrConceal
+	}
+}
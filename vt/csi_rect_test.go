@@ -0,0 +1,116 @@
+package vt
+
+import (
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+func TestRectangularArea(t *testing.T) {
+	const screen = "abcdef\r\nghijkl\r\nmnopqr\r\nstuvwx"
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "fill",
+			input: "\x1b[46;2;2;3;4$x",
+			want:  "abcdef\ng...kl\nm...qr\nstuvwx",
+		},
+		{
+			name:  "fill defaults",
+			input: "\x1b[46;3$x",
+			want:  "abcdef\nghijkl\n......\n......",
+		},
+		{
+			name:  "fill invalid character",
+			input: "\x1b[10;1;1;2;2$x",
+			want:  "abcdef\nghijkl\nmnopqr\nstuvwx",
+		},
+		{
+			name:  "erase",
+			input: "\x1b[1;2;2;5$z",
+			want:  "a    f\ng    l\nmnopqr\nstuvwx",
+		},
+		{
+			name:  "selective erase",
+			input: "\x1b[3;1;4;2${",
+			want:  "abcdef\nghijkl\n  opqr\n  uvwx",
+		},
+		{
+			name:  "copy",
+			input: "\x1b[1;1;2;2;1;3;4$v",
+			want:  "abcdef\nghijkl\nmnoabr\nstughx",
+		},
+		{
+			name:  "copy overlapping",
+			input: "\x1b[1;1;1;4;1;1;3$v",
+			want:  "ababcd\nghijkl\nmnopqr\nstuvwx",
+		},
+		{
+			name:  "origin mode",
+			input: "\x1b[2;3r\x1b[?6h\x1b[46;1;1;9;9$x",
+			want:  "abcdef\n......\n......\nstuvwx",
+		},
+		{
+			name:  "origin mode with margins",
+			input: "\x1b[?69h\x1b[2;4s\x1b[?6h\x1b[46$x",
+			want:  "a...ef\ng...kl\nm...qr\ns...wx",
+		},
+		{
+			name:  "wrong order",
+			input: "\x1b[46;3;1;2;2$x",
+			want:  "abcdef\nghijkl\nmnopqr\nstuvwx",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(6, 4)
+			_, _ = e.WriteString(screen + tc.input)
+			if got := e.String(); got != tc.want {
+				t.Errorf("screen = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRectangularAttributes(t *testing.T) {
+	e := NewEmulator(4, 3)
+	_, _ = e.WriteString("abcd\r\nefgh\r\nijkl")
+
+	// DECCARA applies to the stream of characters by default.
+	_, _ = e.WriteString("\x1b[1;2;2;3;1;4$r")
+	bold := func(x, y int) bool {
+		return e.CellAt(x, y).Style.Attrs&uv.AttrBold != 0
+	}
+	for _, p := range []uv.Position{uv.Pos(1, 0), uv.Pos(2, 0), uv.Pos(3, 0), uv.Pos(0, 1), uv.Pos(1, 1), uv.Pos(2, 1)} {
+		if !bold(p.X, p.Y) {
+			t.Errorf("expected cell %v to be bold", p)
+		}
+	}
+	if bold(0, 0) || bold(3, 1) {
+		t.Errorf("expected cells outside the stream to be unchanged")
+	}
+	if e.CellAt(0, 1).Style.Underline == 0 {
+		t.Errorf("expected cell to be underlined")
+	}
+
+	// With DECSACE 2, it applies to the rectangle. DECRARA toggles the
+	// attributes.
+	_, _ = e.WriteString("\x1b[2*x\x1b[1;2;3;2;1$t")
+	if bold(1, 0) || bold(1, 1) || !bold(1, 2) || !bold(2, 0) {
+		t.Errorf("expected bold to be toggled in the rectangle")
+	}
+
+	// Zero clears all attributes.
+	_, _ = e.WriteString("\x1b[$r")
+	for y := range 3 {
+		for x := range 4 {
+			if s := e.CellAt(x, y).Style; s.Attrs != 0 || s.Underline != 0 {
+				t.Errorf("expected cell (%d, %d) to have no attributes, got %+v", x, y, s)
+			}
+		}
+	}
+}
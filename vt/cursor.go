@@ -22,4 +22,8 @@ type Cursor struct {
 	Style  CursorStyle
 	Steady bool // Not blinking
 	Hidden bool
+
+	// protected indicates if the written characters are protected from
+	// selective erase, see DECSCA.
+	protected bool
 }
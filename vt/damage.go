@@ -63,9 +63,77 @@ type MoveDamage struct {
 	Src, Dst uv.Rectangle
 }
 
+// Bounds returns the bounds of the damaged area.
+func (d MoveDamage) Bounds() uv.Rectangle {
+	return d.Src.Union(d.Dst)
+}
+
 // ScrollDamage represents a scrolled area.
-// The area is scrolled by the given deltas.
+// The area is scrolled by the given deltas. A negative Dy scrolls the content
+// up, and a positive Dy scrolls it down.
 type ScrollDamage struct {
 	uv.Rectangle
 	Dx, Dy int
 }
+
+// maxDamage is the maximum number of damaged areas recorded before they're
+// collapsed into a [ScreenDamage].
+const maxDamage = 1024
+
+// addDamage records a damaged area of the screen.
+func (s *Screen) addDamage(d Damage) {
+	if len(s.damage) > 0 {
+		if _, ok := s.damage[0].(ScreenDamage); ok {
+			// The whole screen is already damaged.
+			return
+		}
+	}
+
+	switch d := d.(type) {
+	case ScreenDamage:
+		s.damage = append(s.damage[:0], d)
+		return
+	case CellDamage:
+		// Merge adjacent cells on the same line.
+		if len(s.damage) > 0 {
+			if last, ok := s.damage[len(s.damage)-1].(CellDamage); ok &&
+				last.Y == d.Y && last.X+last.Width == d.X {
+				last.Width += d.Width
+				s.damage[len(s.damage)-1] = last
+				return
+			}
+		}
+	}
+
+	if len(s.damage) >= maxDamage {
+		s.damage = append(s.damage[:0], ScreenDamage{Width: s.buf.Width(), Height: s.buf.Height()})
+		return
+	}
+	s.damage = append(s.damage, d)
+}
+
+// damageArea records the given area as damaged.
+func (s *Screen) damageArea(area uv.Rectangle) {
+	bounds := s.Bounds()
+	area = area.Intersect(bounds)
+	switch {
+	case area.Empty():
+	case area == bounds:
+		s.addDamage(ScreenDamage{Width: bounds.Dx(), Height: bounds.Dy()})
+	default:
+		s.addDamage(RectDamage(area))
+	}
+}
+
+// DrainDamage returns the areas of the active screen damaged since the last
+// call and clears them. Renderers can use [ScrollDamage] to scroll their
+// content instead of redrawing it. The damage is held back while a
+// synchronized update is in progress.
+func (e *Emulator) DrainDamage() []Damage {
+	if e.syncing() {
+		return nil
+	}
+	d := e.scr.damage
+	e.scr.damage = nil
+	return d
+}
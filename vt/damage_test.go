@@ -0,0 +1,86 @@
+package vt
+
+import (
+	"reflect"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+func TestDamage(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []Damage
+	}{
+		{
+			name:  "text",
+			input: "ab\r\nc",
+			want: []Damage{
+				CellDamage{X: 0, Y: 0, Width: 2},
+				CellDamage{X: 0, Y: 1, Width: 1},
+			},
+		},
+		{
+			name:  "wide",
+			input: "a世",
+			want:  []Damage{CellDamage{X: 0, Y: 0, Width: 3}},
+		},
+		{
+			name:  "clear",
+			input: "ab\x1b[2J",
+			want:  []Damage{ScreenDamage{Width: 10, Height: 4}},
+		},
+		{
+			name:  "erase line",
+			input: "\x1b[2;1H\x1b[2K",
+			want:  []Damage{RectDamage(uv.Rect(0, 1, 10, 1))},
+		},
+		{
+			name:  "scroll",
+			input: "\x1b[4;1H\n",
+			want:  []Damage{ScrollDamage{Rectangle: uv.Rect(0, 0, 10, 4), Dy: -1}},
+		},
+		{
+			name:  "scroll region",
+			input: "\x1b[2;3r\x1b[2T",
+			want:  []Damage{ScrollDamage{Rectangle: uv.Rect(0, 1, 10, 2), Dy: 2}},
+		},
+		{
+			name:  "insert lines",
+			input: "\x1b[2;1H\x1b[L",
+			want:  []Damage{RectDamage(uv.Rect(0, 1, 10, 3))},
+		},
+		{
+			name:  "delete chars",
+			input: "\x1b[1;4H\x1b[P",
+			want:  []Damage{RectDamage(uv.Rect(3, 0, 7, 1))},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(10, 4)
+			e.DrainDamage()
+			_, _ = e.WriteString(tc.input)
+			if got := e.DrainDamage(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("damage = %#v, want %#v", got, tc.want)
+			}
+			if got := e.DrainDamage(); len(got) != 0 {
+				t.Errorf("expected damage to be drained, got %#v", got)
+			}
+		})
+	}
+}
+
+func TestDamageCollapse(t *testing.T) {
+	e := NewEmulator(10, 4)
+	e.DrainDamage()
+	for range maxDamage + 1 {
+		_, _ = e.WriteString("\x1b[1;1Ha\x1b[2;1Hb")
+	}
+	want := []Damage{ScreenDamage{Width: 10, Height: 4}}
+	if got := e.DrainDamage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("damage = %#v, want %#v", got, want)
+	}
+}
@@ -1,6 +1,12 @@
 package vt
 
-import "github.com/charmbracelet/x/ansi"
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
 
 // handleDcs handles a DCS escape sequence.
 func (e *Emulator) handleDcs(cmd ansi.Cmd, params ansi.Params, data []byte) {
@@ -33,3 +39,52 @@ func (e *Emulator) handlePm(data []byte) {
 		e.logf("unhandled sequence: PM %q", data)
 	}
 }
+
+// handleRequestStatusString handles a DECRQSS request by replying with a
+// DECRPSS report built from the current terminal state.
+//
+//	DCS $ q Pt ST
+//
+// Where Pt is the control function to report. The reply is DCS 1 $ r Pt ST
+// for valid requests and DCS 0 $ r ST otherwise.
+func (e *Emulator) handleRequestStatusString(data []byte) {
+	var report string
+	switch string(data) {
+	case "m": // SGR
+		sgr := strings.TrimSuffix(strings.TrimPrefix(e.scr.cur.Pen.String(), "\x1b["), "m")
+		report = "0"
+		if sgr != "" && sgr != "0" {
+			report += ";" + sgr
+		}
+		report += "m"
+	case "r": // DECSTBM
+		scroll := e.scr.ScrollRegion()
+		report = fmt.Sprintf("%d;%dr", scroll.Min.Y+1, scroll.Max.Y)
+	case "s": // DECSLRM
+		scroll := e.scr.ScrollRegion()
+		report = fmt.Sprintf("%d;%ds", scroll.Min.X+1, scroll.Max.X)
+	case " q": // DECSCUSR
+		n := int(e.scr.cur.Style)*2 + 1 //nolint:mnd
+		if e.scr.cur.Steady {
+			n++
+		}
+		report = fmt.Sprintf("%d q", n)
+	case "\"q": // DECSCA
+		report = "0\"q"
+		if e.scr.cur.protected {
+			report = "1\"q"
+		}
+	case "t": // DECSLPP
+		report = fmt.Sprintf("%dt", e.Height())
+	case "$|": // DECSCPP
+		report = fmt.Sprintf("%d$|", e.Width())
+	case "*|": // DECSNLS
+		report = fmt.Sprintf("%d*|", e.Height())
+	default:
+		e.logf("unhandled DECRQSS request: %q", data)
+		_, _ = io.WriteString(e.pw, "\x1bP0$r\x1b\\")
+		return
+	}
+
+	_, _ = io.WriteString(e.pw, "\x1bP1$r"+report+"\x1b\\")
+}
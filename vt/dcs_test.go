@@ -0,0 +1,58 @@
+package vt
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestRequestStatusString(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup string
+		req   string
+		want  string
+	}{
+		{name: "default sgr", req: "m", want: "\x1bP1$r0m\x1b\\"},
+		{name: "sgr", setup: "\x1b[1;31m", req: "m", want: "\x1bP1$r0;1;31m\x1b\\"},
+		{name: "margins", setup: "\x1b[2;5r", req: "r", want: "\x1bP1$r2;5r\x1b\\"},
+		{name: "default margins", req: "r", want: "\x1bP1$r1;10r\x1b\\"},
+		{name: "cursor style", setup: "\x1b[6 q", req: " q", want: "\x1bP1$r6 q\x1b\\"},
+		{name: "default cursor style", req: " q", want: "\x1bP1$r1 q\x1b\\"},
+		{name: "protection", setup: "\x1b[1\"q", req: "\"q", want: "\x1bP1$r1\"q\x1b\\"},
+		{name: "invalid", req: "x", want: "\x1bP0$r\x1b\\"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(10, 10)
+			_, _ = e.WriteString(tc.setup)
+			got := readInput(t, e, func() {
+				_, _ = e.WriteString("\x1bP$q" + tc.req + "\x1b\\")
+			})
+			if got != tc.want {
+				t.Errorf("reply = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequestTermcap(t *testing.T) {
+	e := NewEmulator(10, 10)
+	got := readInput(t, e, func() {
+		_, _ = e.WriteString(ansi.XTGETTCAP("Tc", "Co", "foo"))
+	})
+	want := "\x1bP1+r5463\x1b\\" + "\x1bP1+r436F=323536\x1b\\" + "\x1bP0+r666F6F\x1b\\"
+	if got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+
+	e.SetCapabilities(map[string]string{"Co": "8"})
+	got = readInput(t, e, func() {
+		_, _ = e.WriteString(ansi.XTGETTCAP("Tc", "Co"))
+	})
+	want = "\x1bP0+r5463\x1b\\" + "\x1bP1+r436F=38\x1b\\"
+	if got != want {
+		t.Errorf("custom reply = %q, want %q", got, want)
+	}
+}
@@ -3,6 +3,9 @@ package vt
 import (
 	"image/color"
 	"io"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	uv "github.com/charmbracelet/ultraviolet"
 	"github.com/charmbracelet/ultraviolet/screen"
@@ -10,6 +13,13 @@ import (
 	"github.com/charmbracelet/x/ansi/parser"
 )
 
+// Default cell size in pixels. This is used to report pixel positions and
+// sizes when the host doesn't set one using [Emulator.SetCellSize].
+const (
+	DefaultCellWidth  = 10
+	DefaultCellHeight = 20
+)
+
 // Logger represents a logger interface.
 type Logger interface {
 	Printf(format string, v ...any)
@@ -21,6 +31,8 @@ type Emulator struct {
 
 	// The terminal's indexed 256 colors.
 	colors [256]color.Color
+	// The terminal's special colors, see [SpecialColorBold].
+	specialColors [5]color.Color
 
 	// Both main and alt screens and a pointer to the currently active screen.
 	scrs [2]Screen
@@ -50,9 +62,13 @@ type Emulator struct {
 	lastState parser.State
 
 	cb Callbacks
+	// events delivers the events to the subscriptions.
+	events eventHub
 
 	// The terminal's icon name and title.
 	iconName, title string
+	// titles is the title stack used by XTWINOPS.
+	titles []titleEntry
 	// The current reported working directory. This is not validated.
 	cwd string
 
@@ -67,8 +83,44 @@ type Emulator struct {
 	gl, gr  int
 	gsingle int // temporarily select GL or GR
 
-	// Indicates if the terminal is closed.
-	closed bool
+	// Indicates if the terminal is closed. It's read by [Emulator.Read]
+	// without holding the [SafeEmulator] lock.
+	closed atomic.Bool
+
+	// The size of a cell in pixels.
+	cellWidth, cellHeight int
+
+	// clipboard provides the host clipboard content for OSC 52 queries.
+	clipboard ClipboardProvider
+	// clipboardPolicy controls the access to the host clipboard.
+	clipboardPolicy ClipboardPolicy
+
+	// caps are the terminal capabilities reported to XTGETTCAP requests.
+	caps map[string]string
+
+	// kitty is the Kitty graphics protocol image store.
+	kitty kittyStore
+
+	// modifyOtherKeys is the XTerm modifyOtherKeys level set by
+	// [ansi.XTMODKEYS].
+	modifyOtherKeys int
+
+	// notifications are the OSC 99 notifications being received in chunks
+	// by their identifiers.
+	notifications map[string]*Notification
+
+	// rectExtent indicates if DECCARA and DECRARA apply to a rectangle
+	// instead of a stream of characters, see DECSACE.
+	rectExtent bool
+
+	// linkDetection indicates if plain text URLs and file references are
+	// reported as links.
+	linkDetection bool
+
+	// frame is the snapshot presented during a synchronized update, if any.
+	frame *syncFrame
+	// syncTimeout is the maximum duration of a synchronized update.
+	syncTimeout time.Duration
 
 	// atPhantom indicates if the cursor is out of bounds.
 	// When true, and a character is written, the cursor is moved to the next line.
@@ -85,6 +137,8 @@ func NewEmulator(w, h int) *Emulator {
 	t.scr = &t.scrs[0]
 	t.scrs[0].cb = &t.cb
 	t.scrs[1].cb = &t.cb
+	t.scrs[0].events = &t.events
+	t.scrs[1].events = &t.events
 	t.parser = ansi.NewParser()
 	t.parser.SetParamsSize(parser.MaxParamsSize)
 	t.parser.SetDataSize(1024 * 1024 * 4) // 4MB data buffer
@@ -104,6 +158,21 @@ func NewEmulator(w, h int) *Emulator {
 	t.tabstops = uv.DefaultTabStops(w)
 	t.registerDefaultHandlers()
 
+	// Default cell size
+	t.cellWidth, t.cellHeight = DefaultCellWidth, DefaultCellHeight
+
+	// Default synchronized update timeout
+	t.syncTimeout = DefaultSyncTimeout
+
+	// Default terminal capabilities
+	t.caps = DefaultCapabilities()
+
+	// Default clipboard policy
+	t.clipboardPolicy = DefaultClipboardPolicy
+
+	// Default Kitty graphics image store limit
+	t.kitty.limit = DefaultKittyImageLimit
+
 	// Default colors
 	t.defaultFg = color.White
 	t.defaultBg = color.Black
@@ -124,8 +193,12 @@ func (e *Emulator) SetCallbacks(cb Callbacks) {
 	e.scrs[1].cb = &e.cb
 }
 
-// Touched returns the touched lines in the current screen buffer.
+// Touched returns the touched lines in the current screen buffer. It returns
+// nil while a synchronized update is in progress.
 func (e *Emulator) Touched() []*uv.LineData {
+	if e.syncing() {
+		return nil
+	}
 	return e.scr.Touched()
 }
 
@@ -167,18 +240,29 @@ func (e *Emulator) WidthMethod() uv.WidthMethod {
 	return ansi.WcWidth
 }
 
-// Draw implements the [uv.Drawable] interface.
+// Draw implements the [uv.Drawable] interface. While a synchronized update is
+// in progress, it draws the screen as it was when the update started.
+//
+// The cells of lines that are not single width, see [LineSize], are followed
+// by blank cells so that they take twice their width.
 func (e *Emulator) Draw(scr uv.Screen, area uv.Rectangle) {
 	bg := uv.EmptyCell
 	bg.Style.Bg = e.BackgroundColor()
 	screen.FillArea(scr, &bg, area)
-	for y := range e.Touched() {
-		if y < 0 || y >= e.Height() {
-			continue
+	cellAt, lineSize := e.CellAt, e.LineSize
+	width, height := e.Width(), min(len(e.Touched()), e.Height())
+	if e.syncing() {
+		cellAt, lineSize = e.frame.buf.CellAt, e.frame.lineSize
+		width, height = e.frame.buf.Width(), e.frame.buf.Height()
+	}
+	for y := range height {
+		scale := 1
+		if lineSize(y) != LineSingle {
+			scale = 2
 		}
-		for x := 0; x < e.Width(); {
+		for x := 0; x*scale < width; {
 			w := 1
-			cell := e.CellAt(x, y)
+			cell := cellAt(x, y)
 			if cell != nil {
 				cell = cell.Clone()
 				if cell.Width > 1 {
@@ -190,13 +274,37 @@ func (e *Emulator) Draw(scr uv.Screen, area uv.Rectangle) {
 				if cell.Style.Fg == nil && e.fgColor != nil {
 					cell.Style.Fg = e.fgColor
 				}
-				scr.SetCell(x+area.Min.X, y+area.Min.Y, cell)
+				scr.SetCell(x*scale+area.Min.X, y+area.Min.Y, cell)
+				for i := w; i < w*scale; i++ {
+					pad := uv.EmptyCell
+					pad.Style.Bg = cell.Style.Bg
+					scr.SetCell(x*scale+i+area.Min.X, y+area.Min.Y, &pad)
+				}
 			}
 			x += w
 		}
 	}
 }
 
+// CellSize returns the size of a terminal cell in pixels.
+func (e *Emulator) CellSize() (width, height int) {
+	return e.cellWidth, e.cellHeight
+}
+
+// SetCellSize sets the size of a terminal cell in pixels. This is used to
+// report pixel positions and sizes, such as mouse events in
+// [ansi.ModeMouseExtSgrPixel]. Non-positive values reset the size to
+// [DefaultCellWidth] and [DefaultCellHeight].
+func (e *Emulator) SetCellSize(width, height int) {
+	if width <= 0 {
+		width = DefaultCellWidth
+	}
+	if height <= 0 {
+		height = DefaultCellHeight
+	}
+	e.cellWidth, e.cellHeight = width, height
+}
+
 // Height returns the height of the terminal.
 func (e *Emulator) Height() int {
 	return e.scr.Height()
@@ -215,33 +323,24 @@ func (e *Emulator) CursorPosition() uv.Position {
 
 // Resize resizes the terminal.
 func (e *Emulator) Resize(width int, height int) {
-	x, y := e.scr.CursorPosition()
-	if e.atPhantom {
-		if x < width-1 {
-			e.atPhantom = false
-			x++
-		}
-	}
+	// The main screen rewraps soft-wrapped lines to the new width while the
+	// alternate screen, which has no scrollback, is simply resized.
+	main, alt := &e.scrs[0], &e.scrs[1]
+	pending := main.reflow(width, height, e.atPhantom && e.scr == main)
 
-	if y < 0 {
-		y = 0
-	}
-	if y >= height {
-		y = height - 1
-	}
-	if x < 0 {
-		x = 0
-	}
-	if x >= width {
-		x = width - 1
+	x, y := alt.CursorPosition()
+	if e.atPhantom && e.scr == alt && x < width-1 {
+		x++
+		e.atPhantom = false
 	}
+	alt.Resize(width, height)
+	alt.setCursor(x, y, false)
 
-	e.scrs[0].Resize(width, height)
-	e.scrs[1].Resize(width, height)
+	if e.scr == main {
+		e.atPhantom = pending
+	}
 	e.tabstops = uv.DefaultTabStops(width)
 
-	e.setCursor(x, y)
-
 	if e.isModeSet(ansi.ModeInBandResize) {
 		_, _ = io.WriteString(e.pw, ansi.InBandResize(e.Height(), e.Width(), 0, 0))
 	}
@@ -249,7 +348,7 @@ func (e *Emulator) Resize(width int, height int) {
 
 // Read reads data from the terminal input buffer.
 func (e *Emulator) Read(p []byte) (n int, err error) {
-	if e.closed {
+	if e.closed.Load() {
 		return 0, io.EOF
 	}
 
@@ -258,21 +357,35 @@ func (e *Emulator) Read(p []byte) (n int, err error) {
 
 // Close closes the terminal.
 func (e *Emulator) Close() error {
-	if e.closed {
+	if !e.closed.CompareAndSwap(false, true) {
 		return nil
 	}
+	e.events.close()
 
-	e.closed = true
 	return e.pw.CloseWithError(io.EOF) //nolint:wrapcheck
 }
 
 // Write writes data to the terminal output buffer.
 func (e *Emulator) Write(p []byte) (n int, err error) {
-	if e.closed {
+	if e.closed.Load() {
 		return 0, io.ErrClosedPipe
 	}
 
-	for i := range p {
+	e.expireSync()
+	for i := 0; i < len(p); i++ {
+		// Print runs of text in bulk without going through the parser. A
+		// pending grapheme is flushed before ASCII characters like the
+		// parser does.
+		if e.parser.State() == parser.GroundState && (len(e.grapheme) == 0 || p[i] < utf8.RuneSelf) {
+			if n := printableLen(p[i:]); n > 0 {
+				e.flushGrapheme()
+				e.printText(string(p[i : i+n]))
+				e.lastState = parser.GroundState
+				i += n - 1
+				continue
+			}
+		}
+
 		e.parser.Advance(p[i])
 		state := e.parser.State()
 		// flush grapheme if we transitioned to a non-utf8 state or we have
@@ -341,6 +454,7 @@ func (e *Emulator) SetForegroundColor(c color.Color) {
 	if e.cb.ForegroundColor != nil {
 		e.cb.ForegroundColor(c)
 	}
+	emit(&e.events, ForegroundColorChanged{Color: c})
 }
 
 // SetDefaultForegroundColor sets the terminal's default foreground color.
@@ -370,6 +484,7 @@ func (e *Emulator) SetBackgroundColor(c color.Color) {
 	if e.cb.BackgroundColor != nil {
 		e.cb.BackgroundColor(c)
 	}
+	emit(&e.events, BackgroundColorChanged{Color: c})
 }
 
 // SetDefaultBackgroundColor sets the terminal's default background color.
@@ -398,6 +513,7 @@ func (e *Emulator) SetCursorColor(c color.Color) {
 	if e.cb.CursorColor != nil {
 		e.cb.CursorColor(c)
 	}
+	emit(&e.events, CursorColorChanged{Color: c})
 }
 
 // SetDefaultCursorColor sets the terminal's default cursor color.
@@ -432,6 +548,10 @@ func (e *Emulator) SetIndexedColor(i int, c color.Color) {
 	}
 
 	e.colors[i] = c
+	if e.cb.PaletteColor != nil {
+		e.cb.PaletteColor(i, c)
+	}
+	emit(&e.events, PaletteColorChanged{Index: i, Color: c})
 }
 
 // resetTabStops resets the terminal tab stops to the default set.
@@ -478,12 +598,20 @@ func (e *Emulator) SetScrollbackSize(maxLines int) {
 	e.scrs[0].SetScrollbackSize(maxLines)
 }
 
-// ClearScrollback clears the scrollback buffer.
-func (e *Emulator) ClearScrollback() {
+// SetScrollbackFile moves the scrollback lines beyond the most recent
+// memLines lines to the given file. Pass a nil file to keep all lines in
+// memory. See [Scrollback.SetFile].
+func (e *Emulator) SetScrollbackFile(f ScrollbackFile, memLines int) error {
 	sb := e.Scrollback()
-	if sb != nil {
-		sb.Clear()
+	if sb == nil {
+		return nil
 	}
+	return sb.SetFile(f, memLines)
+}
+
+// ClearScrollback clears the scrollback buffer.
+func (e *Emulator) ClearScrollback() {
+	e.scrs[0].ClearScrollback()
 }
 
 // IsAltScreen returns whether the terminal is in alternate screen mode.
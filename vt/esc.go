@@ -24,6 +24,7 @@ func (e *Emulator) handleEsc(cmd ansi.Cmd) {
 func (e *Emulator) fullReset() {
 	e.scrs[0].Reset()
 	e.scrs[1].Reset()
+	e.kitty.reset()
 	e.resetTabStops()
 
 	// XXX: Do we reset all modes here? Investigate.
@@ -33,6 +34,10 @@ func (e *Emulator) fullReset() {
 	e.gsingle = 0
 	e.charsets = [4]CharSet{}
 	e.atPhantom = false
+	e.modifyOtherKeys = 0
+	e.rectExtent = false
+	e.notifications = nil
+	e.titles = nil
 	e.grapheme = e.grapheme[:0]
 	e.lastChar = 0
 	e.lastState = parser.GroundState
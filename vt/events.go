@@ -0,0 +1,235 @@
+package vt
+
+import (
+	"image/color"
+	"iter"
+	"sync"
+	"sync/atomic"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Event is an event emitted by the emulator to its subscriptions, see
+// [Emulator.Subscribe]. Events mirror the [Callbacks]: an event is emitted
+// whenever the corresponding callback is called. Besides the event types
+// below, [WindowRequest], [Notification], [Progress], and [Attention] values
+// are emitted as events.
+type Event any
+
+// Bell is emitted when a bell character is received.
+type Bell struct{}
+
+// TitleChanged is emitted when the terminal title changes.
+type TitleChanged struct {
+	Title string
+}
+
+// IconNameChanged is emitted when the terminal icon name changes.
+type IconNameChanged struct {
+	IconName string
+}
+
+// AltScreen is emitted when the alternate screen is activated or
+// deactivated.
+type AltScreen struct {
+	Enabled bool
+}
+
+// CursorPositionChanged is emitted when the cursor position changes.
+type CursorPositionChanged struct {
+	Old, New uv.Position
+}
+
+// CursorVisibilityChanged is emitted when the cursor visibility changes.
+type CursorVisibilityChanged struct {
+	Visible bool
+}
+
+// CursorStyleChanged is emitted when the cursor style changes.
+type CursorStyleChanged struct {
+	Style CursorStyle
+	Blink bool
+}
+
+// CursorColorChanged is emitted when the cursor color changes. A nil color
+// indicates the default terminal color.
+type CursorColorChanged struct {
+	Color color.Color
+}
+
+// BackgroundColorChanged is emitted when the background color changes. A nil
+// color indicates the default terminal color.
+type BackgroundColorChanged struct {
+	Color color.Color
+}
+
+// ForegroundColorChanged is emitted when the foreground color changes. A nil
+// color indicates the default terminal color.
+type ForegroundColorChanged struct {
+	Color color.Color
+}
+
+// PaletteColorChanged is emitted when a palette color changes. See
+// [Callbacks.PaletteColor] for the indices.
+type PaletteColorChanged struct {
+	Index int
+	Color color.Color
+}
+
+// CwdChanged is emitted when the current working directory changes.
+type CwdChanged struct {
+	Dir string
+}
+
+// NotificationClosed is emitted when a program closes the OSC 99
+// notification with the given identifier.
+type NotificationClosed struct {
+	ID string
+}
+
+// ClipboardChanged is emitted when a program sets the content of a clipboard
+// selection using OSC 52. See [Callbacks.Clipboard] for the selections.
+type ClipboardChanged struct {
+	Selection byte
+	Content   string
+}
+
+// FrameComplete is emitted when a synchronized update ends.
+type FrameComplete struct{}
+
+// ModeChanged is emitted when a mode is enabled or disabled.
+type ModeChanged struct {
+	Mode    ansi.Mode
+	Enabled bool
+}
+
+// DefaultEventBufferSize is the default number of events buffered by a
+// subscription.
+const DefaultEventBufferSize = 256
+
+// Subscription is a subscription to the events of an emulator. Events are
+// buffered up to the subscription size. The emulator never waits for a
+// subscriber: when the buffer is full, new events are dropped and counted.
+type Subscription struct {
+	ch      chan Event
+	dropped atomic.Uint64
+	hub     *eventHub
+}
+
+// Events returns the channel the events are delivered to. It's closed when
+// the subscription or the emulator is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// All returns an iterator over the events. It stops when the subscription or
+// the emulator is closed.
+func (s *Subscription) All() iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		for ev := range s.ch {
+			if !yield(ev) {
+				return
+			}
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+// Subscribers can query the emulator state to catch up on dropped events.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription and closes its events channel.
+func (s *Subscription) Close() error {
+	s.hub.remove(s)
+	return nil
+}
+
+// eventHub delivers events to the subscriptions. It's safe for concurrent
+// use, and doesn't need the emulator to be locked.
+type eventHub struct {
+	// active is the number of subscriptions, it avoids creating events when
+	// there are none.
+	active atomic.Int32
+
+	mu     sync.Mutex
+	subs   []*Subscription
+	closed bool
+}
+
+// subscribe adds a subscription with a buffer of the given size.
+func (h *eventHub) subscribe(size int) *Subscription {
+	if size <= 0 {
+		size = DefaultEventBufferSize
+	}
+	s := &Subscription{ch: make(chan Event, size), hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(s.ch)
+		return s
+	}
+	h.subs = append(h.subs, s)
+	h.active.Add(1)
+	return s
+}
+
+// remove removes a subscription and closes its channel.
+func (h *eventHub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, sub := range h.subs {
+		if sub == s {
+			h.subs = append(h.subs[:i], h.subs[i+1:]...)
+			h.active.Add(-1)
+			close(s.ch)
+			return
+		}
+	}
+}
+
+// close removes all the subscriptions. Later subscriptions are closed right
+// away.
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range h.subs {
+		close(s.ch)
+	}
+	h.subs, h.closed = nil, true
+	h.active.Store(0)
+}
+
+// send delivers an event to the subscriptions without blocking.
+func (h *eventHub) send(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range h.subs {
+		select {
+		case s.ch <- ev:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// emit emits an event to the subscriptions of the hub, if any.
+func emit[T Event](h *eventHub, ev T) {
+	if h != nil && h.active.Load() > 0 {
+		h.send(ev)
+	}
+}
+
+// Subscribe returns a subscription to the emulator events with a buffer of
+// size events, or [DefaultEventBufferSize] if size isn't positive. Unlike
+// [Callbacks], which are called while the emulator processes its input,
+// events are consumed on the subscriber's own goroutine, which can safely
+// call back into a [SafeEmulator]. An emulator can have many subscriptions;
+// they are safe to create and close concurrently with the emulator use. The
+// subscriptions are closed when the emulator is closed.
+func (e *Emulator) Subscribe(size int) *Subscription {
+	return e.events.subscribe(size)
+}
@@ -0,0 +1,105 @@
+package vt
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// drain returns the buffered events of a subscription, skipping cursor
+// movements.
+func drain(s *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case ev, ok := <-s.Events():
+			if !ok {
+				return events
+			}
+			if _, ok := ev.(CursorPositionChanged); !ok {
+				events = append(events, ev)
+			}
+		default:
+			return events
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	e := NewEmulator(10, 2)
+	sub := e.Subscribe(0)
+	var bells int
+	e.SetCallbacks(Callbacks{Bell: func() { bells++ }})
+
+	e.WriteString("\x1b]2;hello\x07\a\x1b[?1049h\x1b]7;file://host/tmp\x07\x1b[?25l")
+
+	want := []Event{
+		TitleChanged{Title: "hello"},
+		Bell{},
+		AltScreen{Enabled: true},
+		CursorVisibilityChanged{Visible: true},
+		ModeChanged{Mode: ansi.ModeAltScreenSaveCursor, Enabled: true},
+		CwdChanged{Dir: "file://host/tmp"},
+		CursorVisibilityChanged{Visible: false},
+		ModeChanged{Mode: ansi.ModeTextCursorEnable},
+	}
+	if got := drain(sub); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %#v, want %#v", got, want)
+	}
+	if bells != 1 {
+		t.Errorf("bell callback called %d times, want 1", bells)
+	}
+
+	_ = sub.Close()
+	e.WriteString("\a")
+	if _, ok := <-sub.Events(); ok {
+		t.Errorf("received an event after closing the subscription")
+	}
+}
+
+func TestSubscribeDropped(t *testing.T) {
+	e := NewEmulator(10, 2)
+	sub := e.Subscribe(2)
+	e.WriteString("\a\a\a\a\a")
+	if got := len(drain(sub)); got != 2 {
+		t.Errorf("buffered events = %d, want 2", got)
+	}
+	if got := sub.Dropped(); got != 3 {
+		t.Errorf("dropped events = %d, want 3", got)
+	}
+}
+
+func TestSafeEmulatorSubscribers(t *testing.T) {
+	se := NewSafeEmulator(10, 2)
+	subs := []*Subscription{se.Subscribe(0), se.Subscribe(0)}
+
+	var wg sync.WaitGroup
+	titles := make([][]string, len(subs))
+	for i, sub := range subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ev := range sub.All() {
+				if ev, ok := ev.(TitleChanged); ok {
+					// Calling back into the emulator doesn't deadlock.
+					titles[i] = append(titles[i], ev.Title+" "+se.String())
+				}
+			}
+		}()
+	}
+
+	se.WriteString("text\x1b]2;title\x07")
+	_ = se.Close()
+	wg.Wait()
+
+	for i := range subs {
+		if !reflect.DeepEqual(titles[i], []string{"title text\n"}) {
+			t.Errorf("subscriber %d titles = %q, want %q", i, titles[i], []string{"title text\n"})
+		}
+	}
+	if _, ok := <-se.Subscribe(0).Events(); ok {
+		t.Errorf("subscribing to a closed emulator returned an open subscription")
+	}
+}
@@ -212,12 +212,21 @@ func (h *handlers) handleEsc(cmd int) bool {
 
 // registerDefaultHandlers registers the default escape sequence handlers.
 func (e *Emulator) registerDefaultHandlers() {
+	e.registerDefaultApcHandlers()
 	e.registerDefaultCcHandlers()
 	e.registerDefaultCsiHandlers()
+	e.registerDefaultDcsHandlers()
 	e.registerDefaultEscHandlers()
 	e.registerDefaultOscHandlers()
 }
 
+// registerDefaultApcHandlers registers the default APC escape sequence
+// handlers.
+func (e *Emulator) registerDefaultApcHandlers() {
+	// Kitty Graphics [ansi.KittyGraphics]
+	e.RegisterApcHandler(e.handleKittyGraphics)
+}
+
 // registerDefaultCcHandlers registers the default control character handlers.
 func (e *Emulator) registerDefaultCcHandlers() {
 	for i := byte(ansi.NUL); i <= ansi.US; i++ {
@@ -232,6 +241,7 @@ func (e *Emulator) registerDefaultCcHandlers() {
 				if e.cb.Bell != nil {
 					e.cb.Bell()
 				}
+				emit(&e.events, Bell{})
 				return true
 			})
 		case ansi.BS: // Backspace [ansi.BS]
@@ -327,6 +337,26 @@ func (e *Emulator) registerDefaultOscHandlers() {
 		return true
 	})
 
+	for _, cmd := range []int{
+		4, // Set/Query palette color
+		5, // Set/Query special color
+	} {
+		e.RegisterOscHandler(cmd, func(data []byte) bool {
+			e.handlePalette(cmd, data)
+			return true
+		})
+	}
+
+	for _, cmd := range []int{
+		104, // Reset palette color
+		105, // Reset special color
+	} {
+		e.RegisterOscHandler(cmd, func(data []byte) bool {
+			e.handleResetPalette(cmd, data)
+			return true
+		})
+	}
+
 	for _, cmd := range []int{
 		10,  // Set/Query foreground color
 		11,  // Set/Query background color
@@ -340,6 +370,42 @@ func (e *Emulator) registerDefaultOscHandlers() {
 			return true
 		})
 	}
+
+	e.RegisterOscHandler(9, func(data []byte) bool {
+		// Desktop Notification [ansi.Notify] and Progress Bar
+		// [ansi.SetProgressBar]
+		e.handleNotify(9, data)
+		return true
+	})
+
+	e.RegisterOscHandler(52, func(data []byte) bool {
+		// Set/Query Clipboard [ansi.SetClipboard]
+		e.handleClipboard(52, data)
+		return true
+	})
+
+	e.RegisterOscHandler(99, func(data []byte) bool {
+		// Desktop Notification [ansi.DesktopNotification]
+		e.handleDesktopNotification(99, data)
+		return true
+	})
+
+	e.RegisterOscHandler(133, func(data []byte) bool {
+		// Semantic Prompt [ansi.FinalTerm]
+		e.handleSemanticPrompt(133, data)
+		return true
+	})
+
+	e.RegisterOscHandler(777, func(data []byte) bool {
+		// rxvt Desktop Notification
+		e.handleNotifyRxvt(777, data)
+		return true
+	})
+
+	e.RegisterOscHandler(1337, func(data []byte) bool {
+		// iTerm2 Request Attention
+		return e.handleRequestAttention(1337, data)
+	})
 }
 
 // registerDefaultEscHandlers registers the default ESC escape sequence handlers.
@@ -400,6 +466,25 @@ func (e *Emulator) registerDefaultEscHandlers() {
 		})
 	}
 
+	for final, size := range map[byte]LineSize{
+		'3': LineDoubleHeightTop,    // DECDHL top half
+		'4': LineDoubleHeightBottom, // DECDHL bottom half
+		'5': LineSingle,             // DECSWL
+		'6': LineDoubleWidth,        // DECDWL
+	} {
+		e.RegisterEscHandler(ansi.Command(0, '#', final), func() bool {
+			// Line size attributes (DECDHL, DECSWL, and DECDWL)
+			e.setLineSize(size)
+			return true
+		})
+	}
+
+	e.RegisterEscHandler(ansi.Command(0, '#', '8'), func() bool {
+		// Screen Alignment Pattern (DECALN)
+		e.screenAlignment()
+		return true
+	})
+
 	e.RegisterEscHandler('D', func() bool {
 		// Index [ansi.IND]
 		e.index()
@@ -455,6 +540,28 @@ func (e *Emulator) registerDefaultEscHandlers() {
 	})
 }
 
+// registerDefaultDcsHandlers registers the default DCS escape sequence
+// handlers.
+func (e *Emulator) registerDefaultDcsHandlers() {
+	e.RegisterDcsHandler('q', func(_ ansi.Params, data []byte) bool {
+		// Sixel Graphics [ansi.SixelGraphics]
+		e.handleSixel(data)
+		return true
+	})
+
+	e.RegisterDcsHandler(ansi.Command(0, '$', 'q'), func(_ ansi.Params, data []byte) bool {
+		// Request Selection or Setting (DECRQSS)
+		e.handleRequestStatusString(data)
+		return true
+	})
+
+	e.RegisterDcsHandler(ansi.Command(0, '+', 'q'), func(_ ansi.Params, data []byte) bool {
+		// Request Termcap/Terminfo String [ansi.XTGETTCAP]
+		e.handleRequestTermcap(data)
+		return true
+	})
+}
+
 // registerDefaultCsiHandlers registers the default CSI escape sequence handlers.
 func (e *Emulator) registerDefaultCsiHandlers() {
 	e.RegisterCsiHandler('@', func(params ansi.Params) bool {
@@ -551,9 +658,11 @@ func (e *Emulator) registerDefaultCsiHandlers() {
 			rect2 := uv.Rect(0, y+1, width, height-y-1) // next line onwards
 			e.scr.FillArea(e.scr.blankCell(), rect1)
 			e.scr.FillArea(e.scr.blankCell(), rect2)
+			e.scr.resetLineSizes(y+1, height)
 		case 1: // Erase screen above (including cursor)
 			rect := uv.Rect(0, 0, width, y+1)
 			e.scr.FillArea(e.scr.blankCell(), rect)
+			e.scr.resetLineSizes(0, y)
 		case 2: // erase screen
 			// Save screen content to scrollback before clearing
 			e.scr.ClearWithScrollback()
@@ -561,9 +670,7 @@ func (e *Emulator) registerDefaultCsiHandlers() {
 			// For ED 3, we clear the screen but also clear scrollback
 			// This matches xterm behavior where ESC[3J clears scrollback
 			e.scr.Clear()
-			if sb := e.scr.Scrollback(); sb != nil {
-				sb.Clear()
-			}
+			e.scr.ClearScrollback()
 		default:
 			return false
 		}
@@ -593,6 +700,16 @@ func (e *Emulator) registerDefaultCsiHandlers() {
 		return true
 	})
 
+	e.RegisterCsiHandler(ansi.Command('?', 0, 'J'), func(params ansi.Params) bool {
+		// Selective Erase in Display (DECSED)
+		return e.selectiveEraseDisplay(params)
+	})
+
+	e.RegisterCsiHandler(ansi.Command('?', 0, 'K'), func(params ansi.Params) bool {
+		// Selective Erase in Line (DECSEL)
+		return e.selectiveEraseLine(params)
+	})
+
 	e.RegisterCsiHandler('L', func(params ansi.Params) bool {
 		// Insert Line [ansi.IL]
 		n, _, _ := params.Param(0, 1)
@@ -697,6 +814,7 @@ func (e *Emulator) registerDefaultCsiHandlers() {
 			1,  // 132 columns
 			6,  // Selective Erase
 			22, // ANSI color
+			28, // Rectangular editing
 		))
 		return true
 	})
@@ -831,6 +949,11 @@ func (e *Emulator) registerDefaultCsiHandlers() {
 		return true
 	})
 
+	e.RegisterCsiHandler(ansi.Command(0, 0, 't'), func(params ansi.Params) bool {
+		// Window Manipulation [ansi.XTWINOPS]
+		return e.handleWindowOp(params)
+	})
+
 	e.RegisterCsiHandler(ansi.Command(0, '$', 'p'), func(params ansi.Params) bool {
 		// Request Mode [ansi.DECRQM] - ANSI
 		e.handleRequestMode(params, true)
@@ -843,6 +966,61 @@ func (e *Emulator) registerDefaultCsiHandlers() {
 		return true
 	})
 
+	e.RegisterCsiHandler(ansi.Command(0, '"', 'q'), func(params ansi.Params) bool {
+		// Select Character Protection Attribute (DECSCA)
+		return e.setProtection(params)
+	})
+
+	e.RegisterCsiHandler(ansi.Command(0, '$', 'r'), func(params ansi.Params) bool {
+		// Change Attributes in Rectangular Area (DECCARA)
+		e.changeRectAttrs(params, false)
+		return true
+	})
+
+	e.RegisterCsiHandler(ansi.Command(0, '$', 't'), func(params ansi.Params) bool {
+		// Reverse Attributes in Rectangular Area (DECRARA)
+		e.changeRectAttrs(params, true)
+		return true
+	})
+
+	e.RegisterCsiHandler(ansi.Command(0, '$', 'v'), func(params ansi.Params) bool {
+		// Copy Rectangular Area (DECCRA)
+		e.copyRect(params)
+		return true
+	})
+
+	e.RegisterCsiHandler(ansi.Command(0, '$', 'x'), func(params ansi.Params) bool {
+		// Fill Rectangular Area (DECFRA)
+		e.fillRect(params)
+		return true
+	})
+
+	e.RegisterCsiHandler(ansi.Command(0, '$', 'z'), func(params ansi.Params) bool {
+		// Erase Rectangular Area (DECERA)
+		e.eraseRect(params)
+		return true
+	})
+
+	e.RegisterCsiHandler(ansi.Command(0, '$', '{'), func(params ansi.Params) bool {
+		// Selective Erase Rectangular Area (DECSERA)
+		e.selectiveEraseRect(params)
+		return true
+	})
+
+	e.RegisterCsiHandler(ansi.Command(0, '*', 'x'), func(params ansi.Params) bool {
+		// Select Attribute Change Extent (DECSACE)
+		n, _, _ := params.Param(0, 0)
+		switch n {
+		case 0, 1:
+			e.rectExtent = false
+		case 2:
+			e.rectExtent = true
+		default:
+			return false
+		}
+		return true
+	})
+
 	e.RegisterCsiHandler(ansi.Command(0, ' ', 'q'), func(params ansi.Params) bool {
 		// Set Cursor Style [ansi.DECSCUSR]
 		n := 1
@@ -858,6 +1036,16 @@ func (e *Emulator) registerDefaultCsiHandlers() {
 		return true
 	})
 
+	e.RegisterCsiHandler(ansi.Command('>', 0, 'm'), func(params ansi.Params) bool {
+		// Set/Reset Key Modifier Options [ansi.XTMODKEYS]
+		return e.handleModifyKeys(false, params)
+	})
+
+	e.RegisterCsiHandler(ansi.Command('?', 0, 'm'), func(params ansi.Params) bool {
+		// Query Key Modifier Options [ansi.XTQMODKEYS]
+		return e.handleModifyKeys(true, params)
+	})
+
 	e.RegisterCsiHandler('r', func(params ansi.Params) bool {
 		// Set Top and Bottom Margins [ansi.DECSTBM]
 		top, _, _ := params.Param(0, 1)
@@ -920,4 +1108,14 @@ func (e *Emulator) registerDefaultCsiHandlers() {
 
 		return true
 	})
+
+	for _, prefix := range []byte{'>', '<', '=', '?'} {
+		e.RegisterCsiHandler(ansi.Command(prefix, 0, 'u'), func(params ansi.Params) bool {
+			// Kitty Keyboard Protocol [ansi.PushKittyKeyboard],
+			// [ansi.PopKittyKeyboard], [ansi.KittyKeyboard], and
+			// [ansi.RequestKittyKeyboard].
+			e.handleKittyKeyboard(prefix, params)
+			return true
+		})
+	}
 }
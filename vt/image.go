@@ -0,0 +1,195 @@
+package vt
+
+import (
+	"image"
+	"image/draw"
+	"slices"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// ImagePlacement is an image placed on the screen. Placements are anchored to
+// the text and move along with it when the screen scrolls.
+type ImagePlacement struct {
+	// Image is the decoded image.
+	Image image.Image
+	// Bounds is the area of cells covered by the image. The Y coordinates are
+	// relative to the top of the screen, images that scrolled into the
+	// scrollback buffer have negative Y coordinates.
+	Bounds uv.Rectangle
+	// Offset is the offset in pixels of the image within the top-left cell.
+	Offset image.Point
+	// Z is the z-index of the image. Images with higher z-index values are
+	// drawn on top of the ones with lower values.
+	Z int
+	// ImageID and PlacementID identify Kitty graphics images and placements.
+	// They're zero for Sixel images.
+	ImageID, PlacementID int
+}
+
+// Limits of the images placed on a screen. When they're exceeded, the oldest
+// placements are removed. Kitty graphics images are accounted for by their
+// image store, so only Sixel images count toward the memory limit.
+const (
+	maxImagePlacements = 1024
+	maxSixelImageBytes = 320 * 1024 * 1024
+)
+
+// addImage places an image on the screen. Placements entirely covered by the
+// new image, and not drawn above it, are removed.
+func (s *Screen) addImage(p ImagePlacement) {
+	s.images = removeImages(s.images, func(q ImagePlacement) bool {
+		return q.Z <= p.Z && q.Bounds.In(p.Bounds)
+	})
+	s.images = append(s.images, p)
+	s.touchArea(p.Bounds.Intersect(s.Bounds()))
+
+	size := 0
+	for _, q := range s.images {
+		size += sixelImageBytes(q)
+	}
+	n := 0
+	for len(s.images)-n > maxImagePlacements || size > maxSixelImageBytes {
+		q := s.images[n]
+		size -= sixelImageBytes(q)
+		s.touchArea(q.Bounds.Intersect(s.Bounds()))
+		n++
+	}
+	s.images = slices.Delete(s.images, 0, n)
+}
+
+// sixelImageBytes returns the memory used by a Sixel image placement, or
+// zero for Kitty graphics placements.
+func sixelImageBytes(p ImagePlacement) int {
+	if p.ImageID != 0 {
+		return 0
+	}
+	return p.Image.Bounds().Dx() * p.Image.Bounds().Dy() * 4 //nolint:mnd
+}
+
+// Images returns the images placed on the screen and in its scrollback
+// buffer.
+func (s *Screen) Images() []ImagePlacement {
+	return s.images
+}
+
+// clearImages removes the images entirely covered by the given area.
+func (s *Screen) clearImages(area uv.Rectangle) {
+	s.images = removeImages(s.images, func(p ImagePlacement) bool {
+		return p.Bounds.In(area)
+	})
+}
+
+// insertImages moves the images down after n lines are inserted at y within
+// the given scroll region. Images pushed past the bottom margin are removed.
+func (s *Screen) insertImages(y, n int, scroll uv.Rectangle) {
+	s.images = removeImages(s.images, func(p ImagePlacement) bool {
+		if p.Bounds.Min.Y < y || p.Bounds.Min.Y >= scroll.Max.Y ||
+			p.Bounds.Min.X < scroll.Min.X || p.Bounds.Min.X >= scroll.Max.X {
+			return false
+		}
+		return p.Bounds.Min.Y+n >= scroll.Max.Y
+	})
+	for i, p := range s.images {
+		if p.Bounds.Min.Y >= y && p.Bounds.Min.Y < scroll.Max.Y &&
+			p.Bounds.Min.X >= scroll.Min.X && p.Bounds.Min.X < scroll.Max.X {
+			s.images[i].Bounds = p.Bounds.Add(uv.Pos(0, n))
+		}
+	}
+}
+
+// deleteImages moves the images up after n lines are deleted at y within the
+// given scroll region. When the deleted lines are saved to the scrollback
+// buffer, the images move along with them. Otherwise, images pushed past the
+// deleted lines are removed.
+func (s *Screen) deleteImages(y, n int, scroll uv.Rectangle, scrollback bool) {
+	for i, p := range s.images {
+		if scrollback {
+			s.images[i].Bounds = p.Bounds.Add(uv.Pos(0, savedRow(p.Bounds.Min.Y, y, n, scroll)-p.Bounds.Min.Y))
+			continue
+		}
+		if p.Bounds.Min.Y >= y && p.Bounds.Min.Y < scroll.Max.Y &&
+			p.Bounds.Min.X >= scroll.Min.X && p.Bounds.Min.X < scroll.Max.X {
+			s.images[i].Bounds = p.Bounds.Add(uv.Pos(0, -n))
+		}
+	}
+
+	top := y
+	if scrollback {
+		top = -s.scrollback.Len()
+	}
+	s.images = removeImages(s.images, func(p ImagePlacement) bool {
+		return p.Bounds.Max.Y <= top
+	})
+}
+
+// removeImages removes the image placements matching del.
+func removeImages(images []ImagePlacement, del func(ImagePlacement) bool) []ImagePlacement {
+	n := 0
+	for _, p := range images {
+		if !del(p) {
+			images[n] = p
+			n++
+		}
+	}
+	clear(images[n:])
+	return images[:n]
+}
+
+// Images returns the images placed on the active screen. Images that scrolled
+// into the scrollback buffer have negative Y coordinates.
+func (e *Emulator) Images() []ImagePlacement {
+	return slices.Clone(e.scr.Images())
+}
+
+// DrawImages composites the images visible on the screen onto dst. Each cell
+// is drawn as cellWidth by cellHeight pixels starting at the top-left corner
+// of dst. Images are scaled when the given cell size differs from the
+// emulator's cell size. Images are drawn in ascending z-index order, followed
+// by Kitty graphics Unicode placeholder cells.
+func (e *Emulator) DrawImages(dst draw.Image, cellWidth, cellHeight int) {
+	cw, ch := e.CellSize()
+	origin := dst.Bounds().Min
+	images := slices.Clone(e.scr.Images())
+	slices.SortStableFunc(images, func(a, b ImagePlacement) int {
+		return a.Z - b.Z
+	})
+	for _, p := range images {
+		if !p.Bounds.Overlaps(e.Bounds()) {
+			continue
+		}
+
+		img := p.Image
+		src := img.Bounds()
+		at := origin.Add(image.Pt(
+			p.Bounds.Min.X*cellWidth+p.Offset.X*cellWidth/cw,
+			p.Bounds.Min.Y*cellHeight+p.Offset.Y*cellHeight/ch,
+		))
+		if cw != cellWidth || ch != cellHeight {
+			img = scaleImage(img, src.Dx()*cellWidth/cw, src.Dy()*cellHeight/ch)
+			src = img.Bounds()
+		}
+		draw.Draw(dst, src.Sub(src.Min).Add(at), img, src.Min, draw.Over)
+	}
+
+	e.drawKittyPlaceholders(dst, cellWidth, cellHeight)
+}
+
+// scaleImage scales the image to the given size using nearest-neighbor
+// interpolation.
+func scaleImage(img image.Image, width, height int) image.Image {
+	src := img.Bounds()
+	if src.Dx() == width && src.Dy() == height {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if src.Empty() {
+		return dst
+	}
+	for y := range height {
+		for x := range width {
+			dst.Set(x, y, img.At(src.Min.X+x*src.Dx()/width, src.Min.Y+y*src.Dy()/height))
+		}
+	}
+	return dst
+}
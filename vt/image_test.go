@@ -0,0 +1,163 @@
+package vt
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/ansi/sixel"
+)
+
+// sixelImage returns a DCS sixel sequence of a solid red image with the given
+// size in pixels.
+func sixelImage(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 255, A: 255}}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	var enc sixel.Encoder
+	if err := enc.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode sixel image: %v", err)
+	}
+	return ansi.SixelGraphics(0, 1, 0, buf.Bytes())
+}
+
+func TestSixelPlacement(t *testing.T) {
+	t.Run("cursor moves below image", func(t *testing.T) {
+		e := NewEmulator(10, 10)
+		_, _ = e.WriteString("ab" + sixelImage(t, 25, 40))
+
+		images := e.Images()
+		if len(images) != 1 {
+			t.Fatalf("expected 1 image, got %d", len(images))
+		}
+		if want := uv.Rect(2, 0, 3, 2); images[0].Bounds != want {
+			t.Errorf("bounds = %v, want %v", images[0].Bounds, want)
+		}
+		if pos, want := e.CursorPosition(), uv.Pos(2, 2); pos != want {
+			t.Errorf("cursor = %v, want %v", pos, want)
+		}
+	})
+
+	t.Run("cursor right mode", func(t *testing.T) {
+		e := NewEmulator(10, 10)
+		_, _ = e.WriteString("\x1b[?8452h" + sixelImage(t, 25, 40))
+		if pos, want := e.CursorPosition(), uv.Pos(3, 1); pos != want {
+			t.Errorf("cursor = %v, want %v", pos, want)
+		}
+	})
+
+	t.Run("sixel display mode", func(t *testing.T) {
+		e := NewEmulator(10, 10)
+		_, _ = e.WriteString("\x1b[5;5H\x1b[?80h" + sixelImage(t, 10, 20))
+		images := e.Images()
+		if len(images) != 1 || images[0].Bounds != uv.Rect(0, 0, 1, 1) {
+			t.Errorf("images = %v, want one image at the origin", images)
+		}
+		if pos, want := e.CursorPosition(), uv.Pos(4, 4); pos != want {
+			t.Errorf("cursor = %v, want %v", pos, want)
+		}
+	})
+
+	t.Run("images scroll with text", func(t *testing.T) {
+		e := NewEmulator(10, 3)
+		_, _ = e.WriteString("\r\n\r\n" + sixelImage(t, 10, 40))
+		images := e.Images()
+		if len(images) != 1 {
+			t.Fatalf("expected 1 image, got %d", len(images))
+		}
+		// The image is 2 rows tall placed on the last row, the screen scrolls
+		// twice to fit it and move the cursor below it.
+		if want := uv.Rect(0, 0, 1, 2); images[0].Bounds != want {
+			t.Errorf("bounds = %v, want %v", images[0].Bounds, want)
+		}
+
+		_, _ = e.WriteString("\r\n\r\n")
+		if want := uv.Rect(0, -2, 1, 2); e.Images()[0].Bounds != want {
+			t.Errorf("bounds = %v, want %v", e.Images()[0].Bounds, want)
+		}
+
+		e.SetScrollbackSize(1)
+		_, _ = e.WriteString("\r\n\r\n")
+		if images := e.Images(); len(images) != 0 {
+			t.Errorf("expected images past the scrollback to be removed, got %v", images)
+		}
+	})
+
+	t.Run("erase display removes images", func(t *testing.T) {
+		e := NewEmulator(10, 10)
+		_, _ = e.WriteString(sixelImage(t, 10, 20) + "\x1b[2J")
+		if images := e.Images(); len(images) != 0 {
+			t.Errorf("expected no images, got %v", images)
+		}
+	})
+
+	t.Run("covered images are removed", func(t *testing.T) {
+		e := NewEmulator(10, 10)
+		for range 100 {
+			_, _ = e.WriteString("\x1b[H" + sixelImage(t, 10, 20))
+		}
+		_, _ = e.WriteString("\x1b[H" + sixelImage(t, 30, 40))
+		images := e.Images()
+		if len(images) != 1 || images[0].Bounds != uv.Rect(0, 0, 3, 2) {
+			t.Errorf("images = %v, want the last image only", images)
+		}
+	})
+
+	t.Run("placements are limited", func(t *testing.T) {
+		e := NewEmulator(40, 30)
+		_, _ = e.WriteString("\x1b[?8452h")
+		img := sixelImage(t, 1, 1)
+		for i := range maxImagePlacements + 10 {
+			_, _ = fmt.Fprintf(e, "\x1b[%d;%dH%s", i/40+1, i%40+1, img)
+		}
+		images := e.Images()
+		if len(images) != maxImagePlacements {
+			t.Fatalf("got %d images, want %d", len(images), maxImagePlacements)
+		}
+		if want := uv.Rect(10, 0, 1, 1); images[0].Bounds != want {
+			t.Errorf("oldest image bounds = %v, want %v", images[0].Bounds, want)
+		}
+	})
+}
+
+func TestSixelTooLarge(t *testing.T) {
+	for _, data := range []string{
+		"\"1;1;2000000000;2000000000#0~",
+		"\"1;1;99999999999999999999999;99999999999999999999999#0~",
+		"#0!2000000000~-!5~",
+		"#0~" + strings.Repeat("-~", 3000) + "$!5000~",
+	} {
+		e := NewEmulator(10, 10)
+		_, _ = e.WriteString(ansi.SixelGraphics(0, 1, 0, []byte(data)))
+		if images := e.Images(); len(images) != 0 {
+			t.Errorf("%q: expected the image to be dropped, got %v", data, images)
+		}
+	}
+}
+
+func TestDrawImages(t *testing.T) {
+	e := NewEmulator(4, 4)
+	_, _ = e.WriteString("\x1b[2;2H" + sixelImage(t, 10, 20))
+
+	dst := image.NewRGBA(image.Rect(0, 0, 4*5, 4*10))
+	e.DrawImages(dst, 5, 10)
+
+	red := color.RGBA{R: 255, A: 255}
+	if c := dst.RGBAAt(5, 10); c != red {
+		t.Errorf("pixel at image origin = %v, want %v", c, red)
+	}
+	if c := dst.RGBAAt(9, 19); c != red {
+		t.Errorf("pixel at image end = %v, want %v", c, red)
+	}
+	if c := dst.RGBAAt(10, 20); c == red {
+		t.Errorf("pixel outside the image is drawn")
+	}
+}
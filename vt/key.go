@@ -2,6 +2,9 @@ package vt
 
 import (
 	"io"
+	"strconv"
+	"strings"
+	"unicode"
 
 	uv "github.com/charmbracelet/ultraviolet"
 	"github.com/charmbracelet/x/ansi"
@@ -12,295 +15,644 @@ type KeyMod = uv.KeyMod
 
 // Modifier keys.
 const (
-	ModShift = uv.ModShift
-	ModAlt   = uv.ModAlt
-	ModCtrl  = uv.ModCtrl
-	ModMeta  = uv.ModMeta
+	ModShift      = uv.ModShift
+	ModAlt        = uv.ModAlt
+	ModCtrl       = uv.ModCtrl
+	ModMeta       = uv.ModMeta
+	ModHyper      = uv.ModHyper
+	ModSuper      = uv.ModSuper
+	ModCapsLock   = uv.ModCapsLock
+	ModNumLock    = uv.ModNumLock
+	ModScrollLock = uv.ModScrollLock
 )
 
 // KeyPressEvent represents a key press event.
 type KeyPressEvent = uv.KeyPressEvent
 
-// SendKey returns the default key map.
+// KeyReleaseEvent represents a key release event.
+type KeyReleaseEvent = uv.KeyReleaseEvent
+
+// SendKey sends a key event to the terminal. The key is encoded using the
+// Kitty keyboard protocol when any of its flags are enabled. Otherwise, it is
+// encoded using the legacy encoding, and XTerm modifyOtherKeys when enabled.
 func (e *Emulator) SendKey(k uv.KeyEvent) {
 	var seq string
+	if flags := e.scr.kittyKeyboardFlags(); flags != 0 {
+		seq = e.encodeKittyKey(k, flags)
+	} else if key, ok := k.(KeyPressEvent); ok {
+		seq = e.encodeLegacyKey(key)
+	}
+
+	if seq != "" {
+		io.WriteString(e.pw, seq) //nolint:errcheck,gosec
+	}
+}
+
+// encodeLegacyKey encodes a key press using the legacy XTerm encoding. When
+// XTerm modifyOtherKeys is enabled, modified keys are encoded as
+// CSI 27 ; modifiers ; code ~ according to the modifyOtherKeys level.
+func (e *Emulator) encodeLegacyKey(key KeyPressEvent) string {
+	var seq string
 
 	ack := e.isModeSet(ansi.ModeCursorKeys)    // Application cursor keys mode
 	akk := e.isModeSet(ansi.ModeNumericKeypad) // Application keypad keys mode
 
-	//nolint:godox
-	// TODO: Support Kitty, CSI u, and XTerm modifyOtherKeys.
-	switch key := k.(type) {
-	case KeyPressEvent:
-		if key.Mod&ModAlt != 0 {
-			// Handle alt-modified keys
-			seq = "\x1b" + seq
-			key.Mod &^= ModAlt // Remove the Alt modifier for easier matching
-		}
-
-		//nolint:godox
-		// FIXME: We remove any Base and Shifted codes to properly handle
-		// comparison. This is a workaround for the fact that we don't support
-		// extended keys yet.
-		key.BaseCode = 0
-		key.ShiftedCode = 0
-
-		switch key {
-		// Control keys
-		case KeyPressEvent{Code: KeySpace, Mod: ModCtrl}:
-			seq += "\x00"
-		case KeyPressEvent{Code: 'a', Mod: ModCtrl}:
-			seq += "\x01"
-		case KeyPressEvent{Code: 'b', Mod: ModCtrl}:
-			seq += "\x02"
-		case KeyPressEvent{Code: 'c', Mod: ModCtrl}:
-			seq += "\x03"
-		case KeyPressEvent{Code: 'd', Mod: ModCtrl}:
-			seq += "\x04"
-		case KeyPressEvent{Code: 'e', Mod: ModCtrl}:
-			seq += "\x05"
-		case KeyPressEvent{Code: 'f', Mod: ModCtrl}:
-			seq += "\x06"
-		case KeyPressEvent{Code: 'g', Mod: ModCtrl}:
-			seq += "\x07"
-		case KeyPressEvent{Code: 'h', Mod: ModCtrl}:
-			seq += "\x08"
-		case KeyPressEvent{Code: 'i', Mod: ModCtrl}:
-			seq += "\x09"
-		case KeyPressEvent{Code: 'j', Mod: ModCtrl}:
-			seq += "\x0a"
-		case KeyPressEvent{Code: 'k', Mod: ModCtrl}:
-			seq += "\x0b"
-		case KeyPressEvent{Code: 'l', Mod: ModCtrl}:
-			seq += "\x0c"
-		case KeyPressEvent{Code: 'm', Mod: ModCtrl}:
-			seq += "\x0d"
-		case KeyPressEvent{Code: 'n', Mod: ModCtrl}:
-			seq += "\x0e"
-		case KeyPressEvent{Code: 'o', Mod: ModCtrl}:
-			seq += "\x0f"
-		case KeyPressEvent{Code: 'p', Mod: ModCtrl}:
-			seq += "\x10"
-		case KeyPressEvent{Code: 'q', Mod: ModCtrl}:
-			seq += "\x11"
-		case KeyPressEvent{Code: 'r', Mod: ModCtrl}:
-			seq += "\x12"
-		case KeyPressEvent{Code: 's', Mod: ModCtrl}:
-			seq += "\x13"
-		case KeyPressEvent{Code: 't', Mod: ModCtrl}:
-			seq += "\x14"
-		case KeyPressEvent{Code: 'u', Mod: ModCtrl}:
-			seq += "\x15"
-		case KeyPressEvent{Code: 'v', Mod: ModCtrl}:
-			seq += "\x16"
-		case KeyPressEvent{Code: 'w', Mod: ModCtrl}:
-			seq += "\x17"
-		case KeyPressEvent{Code: 'x', Mod: ModCtrl}:
-			seq += "\x18"
-		case KeyPressEvent{Code: 'y', Mod: ModCtrl}:
-			seq += "\x19"
-		case KeyPressEvent{Code: 'z', Mod: ModCtrl}:
-			seq += "\x1a"
-		case KeyPressEvent{Code: '[', Mod: ModCtrl}:
-			seq += "\x1b"
-		case KeyPressEvent{Code: '\\', Mod: ModCtrl}:
-			seq += "\x1c"
-		case KeyPressEvent{Code: ']', Mod: ModCtrl}:
-			seq += "\x1d"
-		case KeyPressEvent{Code: '^', Mod: ModCtrl}:
-			seq += "\x1e"
-		case KeyPressEvent{Code: '_', Mod: ModCtrl}:
-			seq += "\x1f"
-
-		case KeyPressEvent{Code: KeyEnter}:
+	// Lock modifiers don't affect the legacy encoding.
+	key.Mod &^= ModCapsLock | ModNumLock | ModScrollLock
+
+	if lk, ok := legacyKeys[key.Code]; ok {
+		if mod := xtermMod(key.Mod); mod != 0 {
+			// Modified special keys are encoded as CSI 1 ; modifiers final
+			// or CSI number ; modifiers ~.
+			return csiKey(lk.num, lk.final, mod, 1)
+		}
+	}
+
+	if e.modifyOtherKeys > 0 {
+		if seq, ok := encodeModifyOtherKeys(key, e.modifyOtherKeys); ok {
+			return seq
+		}
+	}
+
+	if key.Mod&ModAlt != 0 {
+		// Handle alt-modified keys
+		seq = "\x1b" + seq
+		key.Mod &^= ModAlt // Remove the Alt modifier for easier matching
+	}
+
+	// We only match against the key code and modifiers.
+	text := key.Text
+	key.Text = ""
+	key.BaseCode = 0
+	key.ShiftedCode = 0
+	key.IsRepeat = false
+
+	switch key {
+	// Control keys
+	case KeyPressEvent{Code: KeySpace, Mod: ModCtrl}:
+		seq += "\x00"
+	case KeyPressEvent{Code: 'a', Mod: ModCtrl}:
+		seq += "\x01"
+	case KeyPressEvent{Code: 'b', Mod: ModCtrl}:
+		seq += "\x02"
+	case KeyPressEvent{Code: 'c', Mod: ModCtrl}:
+		seq += "\x03"
+	case KeyPressEvent{Code: 'd', Mod: ModCtrl}:
+		seq += "\x04"
+	case KeyPressEvent{Code: 'e', Mod: ModCtrl}:
+		seq += "\x05"
+	case KeyPressEvent{Code: 'f', Mod: ModCtrl}:
+		seq += "\x06"
+	case KeyPressEvent{Code: 'g', Mod: ModCtrl}:
+		seq += "\x07"
+	case KeyPressEvent{Code: 'h', Mod: ModCtrl}:
+		seq += "\x08"
+	case KeyPressEvent{Code: 'i', Mod: ModCtrl}:
+		seq += "\x09"
+	case KeyPressEvent{Code: 'j', Mod: ModCtrl}:
+		seq += "\x0a"
+	case KeyPressEvent{Code: 'k', Mod: ModCtrl}:
+		seq += "\x0b"
+	case KeyPressEvent{Code: 'l', Mod: ModCtrl}:
+		seq += "\x0c"
+	case KeyPressEvent{Code: 'm', Mod: ModCtrl}:
+		seq += "\x0d"
+	case KeyPressEvent{Code: 'n', Mod: ModCtrl}:
+		seq += "\x0e"
+	case KeyPressEvent{Code: 'o', Mod: ModCtrl}:
+		seq += "\x0f"
+	case KeyPressEvent{Code: 'p', Mod: ModCtrl}:
+		seq += "\x10"
+	case KeyPressEvent{Code: 'q', Mod: ModCtrl}:
+		seq += "\x11"
+	case KeyPressEvent{Code: 'r', Mod: ModCtrl}:
+		seq += "\x12"
+	case KeyPressEvent{Code: 's', Mod: ModCtrl}:
+		seq += "\x13"
+	case KeyPressEvent{Code: 't', Mod: ModCtrl}:
+		seq += "\x14"
+	case KeyPressEvent{Code: 'u', Mod: ModCtrl}:
+		seq += "\x15"
+	case KeyPressEvent{Code: 'v', Mod: ModCtrl}:
+		seq += "\x16"
+	case KeyPressEvent{Code: 'w', Mod: ModCtrl}:
+		seq += "\x17"
+	case KeyPressEvent{Code: 'x', Mod: ModCtrl}:
+		seq += "\x18"
+	case KeyPressEvent{Code: 'y', Mod: ModCtrl}:
+		seq += "\x19"
+	case KeyPressEvent{Code: 'z', Mod: ModCtrl}:
+		seq += "\x1a"
+	case KeyPressEvent{Code: '[', Mod: ModCtrl}:
+		seq += "\x1b"
+	case KeyPressEvent{Code: '\\', Mod: ModCtrl}:
+		seq += "\x1c"
+	case KeyPressEvent{Code: ']', Mod: ModCtrl}:
+		seq += "\x1d"
+	case KeyPressEvent{Code: '^', Mod: ModCtrl}:
+		seq += "\x1e"
+	case KeyPressEvent{Code: '_', Mod: ModCtrl}:
+		seq += "\x1f"
+
+	case KeyPressEvent{Code: KeyEnter}:
+		seq += "\r"
+	case KeyPressEvent{Code: KeyTab}:
+		seq += "\t"
+	case KeyPressEvent{Code: KeyBackspace}:
+		seq += "\x7f"
+	case KeyPressEvent{Code: KeyEscape}:
+		seq += "\x1b"
+
+	case KeyPressEvent{Code: KeyUp}:
+		if ack {
+			seq += "\x1bOA"
+		} else {
+			seq += "\x1b[A"
+		}
+	case KeyPressEvent{Code: KeyDown}:
+		if ack {
+			seq += "\x1bOB"
+		} else {
+			seq += "\x1b[B"
+		}
+	case KeyPressEvent{Code: KeyRight}:
+		if ack {
+			seq += "\x1bOC"
+		} else {
+			seq += "\x1b[C"
+		}
+	case KeyPressEvent{Code: KeyLeft}:
+		if ack {
+			seq += "\x1bOD"
+		} else {
+			seq += "\x1b[D"
+		}
+
+	case KeyPressEvent{Code: KeyInsert}:
+		seq += "\x1b[2~"
+	case KeyPressEvent{Code: KeyDelete}:
+		seq += "\x1b[3~"
+	case KeyPressEvent{Code: KeyHome}:
+		seq += "\x1b[H"
+	case KeyPressEvent{Code: KeyEnd}:
+		seq += "\x1b[F"
+	case KeyPressEvent{Code: KeyPgUp}:
+		seq += "\x1b[5~"
+	case KeyPressEvent{Code: KeyPgDown}:
+		seq += "\x1b[6~"
+
+	case KeyPressEvent{Code: KeyF1}:
+		seq += "\x1bOP"
+	case KeyPressEvent{Code: KeyF2}:
+		seq += "\x1bOQ"
+	case KeyPressEvent{Code: KeyF3}:
+		seq += "\x1bOR"
+	case KeyPressEvent{Code: KeyF4}:
+		seq += "\x1bOS"
+	case KeyPressEvent{Code: KeyF5}:
+		seq += "\x1b[15~"
+	case KeyPressEvent{Code: KeyF6}:
+		seq += "\x1b[17~"
+	case KeyPressEvent{Code: KeyF7}:
+		seq += "\x1b[18~"
+	case KeyPressEvent{Code: KeyF8}:
+		seq += "\x1b[19~"
+	case KeyPressEvent{Code: KeyF9}:
+		seq += "\x1b[20~"
+	case KeyPressEvent{Code: KeyF10}:
+		seq += "\x1b[21~"
+	case KeyPressEvent{Code: KeyF11}:
+		seq += "\x1b[23~"
+	case KeyPressEvent{Code: KeyF12}:
+		seq += "\x1b[24~"
+
+	case KeyPressEvent{Code: KeyKp0}:
+		if akk {
+			seq += "\x1bOp"
+		} else {
+			seq += "0"
+		}
+	case KeyPressEvent{Code: KeyKp1}:
+		if akk {
+			seq += "\x1bOq"
+		} else {
+			seq += "1"
+		}
+	case KeyPressEvent{Code: KeyKp2}:
+		if akk {
+			seq += "\x1bOr"
+		} else {
+			seq += "2"
+		}
+	case KeyPressEvent{Code: KeyKp3}:
+		if akk {
+			seq += "\x1bOs"
+		} else {
+			seq += "3"
+		}
+	case KeyPressEvent{Code: KeyKp4}:
+		if akk {
+			seq += "\x1bOt"
+		} else {
+			seq += "4"
+		}
+	case KeyPressEvent{Code: KeyKp5}:
+		if akk {
+			seq += "\x1bOu"
+		} else {
+			seq += "5"
+		}
+	case KeyPressEvent{Code: KeyKp6}:
+		if akk {
+			seq += "\x1bOv"
+		} else {
+			seq += "6"
+		}
+	case KeyPressEvent{Code: KeyKp7}:
+		if akk {
+			seq += "\x1bOw"
+		} else {
+			seq += "7"
+		}
+	case KeyPressEvent{Code: KeyKp8}:
+		if akk {
+			seq += "\x1bOx"
+		} else {
+			seq += "8"
+		}
+	case KeyPressEvent{Code: KeyKp9}:
+		if akk {
+			seq += "\x1bOy"
+		} else {
+			seq += "9"
+		}
+	case KeyPressEvent{Code: KeyKpEnter}:
+		if akk {
+			seq += "\x1bOM"
+		} else {
 			seq += "\r"
-		case KeyPressEvent{Code: KeyTab}:
-			seq += "\t"
-		case KeyPressEvent{Code: KeyBackspace}:
-			seq += "\x7f"
-		case KeyPressEvent{Code: KeyEscape}:
-			seq += "\x1b"
-
-		case KeyPressEvent{Code: KeyUp}:
-			if ack {
-				seq += "\x1bOA"
-			} else {
-				seq += "\x1b[A"
-			}
-		case KeyPressEvent{Code: KeyDown}:
-			if ack {
-				seq += "\x1bOB"
-			} else {
-				seq += "\x1b[B"
-			}
-		case KeyPressEvent{Code: KeyRight}:
-			if ack {
-				seq += "\x1bOC"
-			} else {
-				seq += "\x1b[C"
-			}
-		case KeyPressEvent{Code: KeyLeft}:
-			if ack {
-				seq += "\x1bOD"
-			} else {
-				seq += "\x1b[D"
-			}
+		}
+	case KeyPressEvent{Code: KeyKpEqual}:
+		if akk {
+			seq += "\x1bOX"
+		} else {
+			seq += "="
+		}
+	case KeyPressEvent{Code: KeyKpMultiply}:
+		if akk {
+			seq += "\x1bOj"
+		} else {
+			seq += "*"
+		}
+	case KeyPressEvent{Code: KeyKpPlus}:
+		if akk {
+			seq += "\x1bOk"
+		} else {
+			seq += "+"
+		}
+	case KeyPressEvent{Code: KeyKpComma}:
+		if akk {
+			seq += "\x1bOl"
+		} else {
+			seq += ","
+		}
+	case KeyPressEvent{Code: KeyKpMinus}:
+		if akk {
+			seq += "\x1bOm"
+		} else {
+			seq += "-"
+		}
+	case KeyPressEvent{Code: KeyKpDecimal}:
+		if akk {
+			seq += "\x1bOn"
+		} else {
+			seq += "."
+		}
 
-		case KeyPressEvent{Code: KeyInsert}:
-			seq += "\x1b[2~"
-		case KeyPressEvent{Code: KeyDelete}:
-			seq += "\x1b[3~"
-		case KeyPressEvent{Code: KeyHome}:
-			seq += "\x1b[H"
-		case KeyPressEvent{Code: KeyEnd}:
-			seq += "\x1b[F"
-		case KeyPressEvent{Code: KeyPgUp}:
-			seq += "\x1b[5~"
-		case KeyPressEvent{Code: KeyPgDown}:
-			seq += "\x1b[6~"
-
-		case KeyPressEvent{Code: KeyF1}:
-			seq += "\x1bOP"
-		case KeyPressEvent{Code: KeyF2}:
-			seq += "\x1bOQ"
-		case KeyPressEvent{Code: KeyF3}:
-			seq += "\x1bOR"
-		case KeyPressEvent{Code: KeyF4}:
-			seq += "\x1bOS"
-		case KeyPressEvent{Code: KeyF5}:
-			seq += "\x1b[15~"
-		case KeyPressEvent{Code: KeyF6}:
-			seq += "\x1b[17~"
-		case KeyPressEvent{Code: KeyF7}:
-			seq += "\x1b[18~"
-		case KeyPressEvent{Code: KeyF8}:
-			seq += "\x1b[19~"
-		case KeyPressEvent{Code: KeyF9}:
-			seq += "\x1b[20~"
-		case KeyPressEvent{Code: KeyF10}:
-			seq += "\x1b[21~"
-		case KeyPressEvent{Code: KeyF11}:
-			seq += "\x1b[23~"
-		case KeyPressEvent{Code: KeyF12}:
-			seq += "\x1b[24~"
-
-		case KeyPressEvent{Code: KeyKp0}:
-			if akk {
-				seq += "\x1bOp"
-			} else {
-				seq += "0"
-			}
-		case KeyPressEvent{Code: KeyKp1}:
-			if akk {
-				seq += "\x1bOq"
-			} else {
-				seq += "1"
-			}
-		case KeyPressEvent{Code: KeyKp2}:
-			if akk {
-				seq += "\x1bOr"
-			} else {
-				seq += "2"
-			}
-		case KeyPressEvent{Code: KeyKp3}:
-			if akk {
-				seq += "\x1bOs"
-			} else {
-				seq += "3"
-			}
-		case KeyPressEvent{Code: KeyKp4}:
-			if akk {
-				seq += "\x1bOt"
-			} else {
-				seq += "4"
-			}
-		case KeyPressEvent{Code: KeyKp5}:
-			if akk {
-				seq += "\x1bOu"
-			} else {
-				seq += "5"
-			}
-		case KeyPressEvent{Code: KeyKp6}:
-			if akk {
-				seq += "\x1bOv"
-			} else {
-				seq += "6"
-			}
-		case KeyPressEvent{Code: KeyKp7}:
-			if akk {
-				seq += "\x1bOw"
-			} else {
-				seq += "7"
-			}
-		case KeyPressEvent{Code: KeyKp8}:
-			if akk {
-				seq += "\x1bOx"
-			} else {
-				seq = "8"
-			}
-		case KeyPressEvent{Code: KeyKp9}:
-			if akk {
-				seq += "\x1bOy"
-			} else {
-				seq += "9"
-			}
-		case KeyPressEvent{Code: KeyKpEnter}:
-			if akk {
-				seq += "\x1bOM"
-			} else {
-				seq += "\r"
-			}
-		case KeyPressEvent{Code: KeyKpEqual}:
-			if akk {
-				seq += "\x1bOX"
-			} else {
-				seq += "="
+	case KeyPressEvent{Code: KeyTab, Mod: ModShift}:
+		seq += "\x1b[Z"
+
+	default:
+		// Handle the rest of the keys.
+		if text != "" && key.Mod&^ModShift == 0 {
+			seq += text
+		} else if key.Mod == 0 {
+			seq += string(key.Code)
+		}
+	}
+
+	return seq
+}
+
+// encodeKittyKey encodes a key event using the Kitty keyboard protocol with
+// the given progressive enhancement flags.
+//
+//	CSI unicode-key-code:alternate-key-codes ; modifiers:event-type ; text-as-codepoints u
+//
+// See: https://sw.kovidgoyal.net/kitty/keyboard-protocol/
+func (e *Emulator) encodeKittyKey(k uv.KeyEvent, flags int) string {
+	key := k.Key()
+
+	event := kittyEventPress
+	if _, ok := k.(KeyReleaseEvent); ok {
+		event = kittyEventRelease
+	} else if key.IsRepeat {
+		event = kittyEventRepeat
+	}
+	if flags&ansi.KittyReportEventTypes == 0 {
+		if event == kittyEventRelease {
+			return ""
+		}
+		event = kittyEventPress
+	}
+
+	allKeys := flags&ansi.KittyReportAllKeysAsEscapeCodes != 0
+	mods := key.Mod &^ ModScrollLock // Kitty doesn't support scroll lock
+	if !allKeys {
+		// Lock modifiers are only reported with all keys as escape codes.
+		mods &^= ModCapsLock | ModNumLock
+	}
+
+	text := key.Text
+	if text == "" && unicode.IsPrint(key.Code) {
+		text = string(key.Code)
+		if key.ShiftedCode != 0 && mods&ModShift != 0 {
+			text = string(key.ShiftedCode)
+		} else if mods&ModShift != 0 {
+			text = string(unicode.ToUpper(key.Code))
+		}
+	}
+	// Only unmodified and shifted keys produce text.
+	isText := text != "" && mods&^(ModShift|ModCapsLock|ModNumLock) == 0
+
+	if !allKeys {
+		switch {
+		case key.Code >= KeyLeftShift && key.Code <= KeyIsoLevel5Shift:
+			// Modifier keys are only reported with all keys as escape codes.
+			return ""
+		case key.Code == KeyEnter || key.Code == KeyTab || key.Code == KeyBackspace:
+			// Enter, Tab, and Backspace keep their legacy encoding when
+			// unmodified so that a shell is still usable when a program
+			// exits without resetting the keyboard protocol.
+			if mods == 0 {
+				if event == kittyEventRelease {
+					return ""
+				}
+				return e.encodeLegacyKey(KeyPressEvent(key))
 			}
-		case KeyPressEvent{Code: KeyKpMultiply}:
-			if akk {
-				seq += "\x1bOj"
-			} else {
-				seq += "*"
+		case isText && key.Code != KeyEscape:
+			if event == kittyEventRelease {
+				break
 			}
-		case KeyPressEvent{Code: KeyKpPlus}:
-			if akk {
-				seq += "\x1bOk"
-			} else {
-				seq += "+"
+			return text
+		}
+
+		if _, ok := legacyKeys[key.Code]; ok && mods == 0 && event == kittyEventPress {
+			return e.encodeLegacyKey(KeyPressEvent(key))
+		}
+	}
+
+	mod := kittyMod(mods)
+	if lk, ok := kittyLegacyKeys[key.Code]; ok {
+		return csiKey(lk.num, lk.final, mod, event)
+	}
+
+	code, ok := kittyKeyCodes[key.Code]
+	if !ok {
+		if key.Code == KeyExtended {
+			// Keys with multiple runes don't have a key code.
+			return key.Text
+		}
+		code = int(key.Code)
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[")
+	b.WriteString(strconv.Itoa(code))
+	if flags&ansi.KittyReportAlternateKeys != 0 {
+		shifted := mods&ModShift != 0 && key.ShiftedCode != 0 && key.ShiftedCode != key.Code
+		base := key.BaseCode != 0 && key.BaseCode != key.Code
+		if shifted || base {
+			b.WriteByte(':')
+			if shifted {
+				b.WriteString(strconv.Itoa(int(key.ShiftedCode)))
 			}
-		case KeyPressEvent{Code: KeyKpComma}:
-			if akk {
-				seq += "\x1bOl"
-			} else {
-				seq += ","
+			if base {
+				b.WriteByte(':')
+				b.WriteString(strconv.Itoa(int(key.BaseCode)))
 			}
-		case KeyPressEvent{Code: KeyKpMinus}:
-			if akk {
-				seq += "\x1bOm"
-			} else {
-				seq += "-"
+		}
+	}
+
+	withText := allKeys && flags&ansi.KittyReportAssociatedKeys != 0 &&
+		isText && event != kittyEventRelease
+	if mod != 0 || event != kittyEventPress || withText {
+		b.WriteByte(';')
+		if mod != 0 || event != kittyEventPress {
+			b.WriteString(strconv.Itoa(mod + 1))
+			if event != kittyEventPress {
+				b.WriteByte(':')
+				b.WriteString(strconv.Itoa(event))
 			}
-		case KeyPressEvent{Code: KeyKpDecimal}:
-			if akk {
-				seq += "\x1bOn"
-			} else {
-				seq += "."
+		}
+		if withText {
+			b.WriteByte(';')
+			for i, r := range text {
+				if i > 0 {
+					b.WriteByte(':')
+				}
+				b.WriteString(strconv.Itoa(int(r)))
 			}
+		}
+	}
+	b.WriteByte('u')
 
-		case KeyPressEvent{Code: KeyTab, Mod: ModShift}:
-			seq += "\x1b[Z"
+	return b.String()
+}
 
+// encodeModifyOtherKeys encodes a key press using the XTerm modifyOtherKeys
+// encoding. It reports false if the key should use the legacy encoding.
+//
+//	CSI 27 ; modifiers ; code ~
+//
+// With level 1, only modified keys that don't have a well-known legacy
+// encoding are reported. With level 2, all modified keys are reported, except
+// for shifted text keys.
+func encodeModifyOtherKeys(key KeyPressEvent, level int) (string, bool) {
+	mod := xtermMod(key.Mod)
+	if mod == 0 || key.Code == KeyExtended || key.Code > unicode.MaxRune {
+		return "", false
+	}
+	if mod == int(ModShift) && unicode.IsPrint(key.Code) {
+		// Shifted text keys produce text.
+		return "", false
+	}
+
+	if level < 2 {
+		ctrl := key.Mod&ModCtrl != 0
+		shift := key.Mod&ModShift != 0
+		switch key.Code {
+		case KeyEnter, KeyBackspace, KeyEscape:
+		case KeyTab:
+			if mod == int(ModShift) {
+				// Shift+Tab is [ansi.CBT].
+				return "", false
+			}
 		default:
-			// Handle the rest of the keys.
-			if key.Mod == 0 {
-				seq += string(key.Code)
+			// Control characters with a C0 representation.
+			isC0 := ctrl && (key.Code == KeySpace || (key.Code >= 'a' && key.Code <= 'z') ||
+				(key.Code >= '[' && key.Code <= '_'))
+			if !shift && (isC0 || !ctrl) {
+				return "", false
 			}
 		}
+	}
 
-		io.WriteString(e.pw, seq) //nolint:errcheck,gosec
+	return "\x1b[27;" + strconv.Itoa(mod+1) + ";" + strconv.Itoa(int(key.Code)) + "~", true
+}
+
+// csiKey returns a key sequence in the form of CSI number ; modifiers final.
+// The number is omitted when it's 1 and there are no modifiers nor an event
+// type.
+func csiKey(num int, final byte, mod, event int) string {
+	var b strings.Builder
+	b.WriteString("\x1b[")
+	if mod == 0 && event == kittyEventPress {
+		if num != 1 || final == '~' {
+			b.WriteString(strconv.Itoa(num))
+		}
+	} else {
+		b.WriteString(strconv.Itoa(num))
+		b.WriteByte(';')
+		b.WriteString(strconv.Itoa(mod + 1))
+		if event != kittyEventPress {
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(event))
+		}
+	}
+	b.WriteByte(final)
+	return b.String()
+}
+
+// xtermMod returns the XTerm modifiers bitmask of the given key modifiers.
+func xtermMod(m KeyMod) int {
+	return int(m & (ModShift | ModAlt | ModCtrl | ModMeta))
+}
+
+// kittyMod returns the Kitty keyboard protocol modifiers bitmask of the given
+// key modifiers. Note that Meta and Super are swapped in the Kitty protocol.
+func kittyMod(m KeyMod) int {
+	var mod int
+	for _, km := range []struct {
+		m   KeyMod
+		bit int
+	}{
+		{ModShift, 1},
+		{ModAlt, 2},
+		{ModCtrl, 4},
+		{ModSuper, 8},
+		{ModHyper, 16},
+		{ModMeta, 32},
+		{ModCapsLock, 64},
+		{ModNumLock, 128},
+	} {
+		if m&km.m != 0 {
+			mod |= km.bit
+		}
 	}
+	return mod
 }
 
+// Kitty keyboard protocol event types.
+const (
+	kittyEventPress   = 1
+	kittyEventRepeat  = 2
+	kittyEventRelease = 3
+)
+
+// csiKeySeq represents a key encoded as CSI number ; modifiers final.
+type csiKeySeq struct {
+	num   int
+	final byte
+}
+
+// legacyKeys are the special keys that have a legacy CSI encoding.
+var legacyKeys = map[rune]csiKeySeq{
+	KeyUp:     {1, 'A'},
+	KeyDown:   {1, 'B'},
+	KeyRight:  {1, 'C'},
+	KeyLeft:   {1, 'D'},
+	KeyBegin:  {1, 'E'},
+	KeyEnd:    {1, 'F'},
+	KeyHome:   {1, 'H'},
+	KeyInsert: {2, '~'},
+	KeyDelete: {3, '~'},
+	KeyPgUp:   {5, '~'},
+	KeyPgDown: {6, '~'},
+	KeyF1:     {1, 'P'},
+	KeyF2:     {1, 'Q'},
+	KeyF3:     {1, 'R'},
+	KeyF4:     {1, 'S'},
+	KeyF5:     {15, '~'},
+	KeyF6:     {17, '~'},
+	KeyF7:     {18, '~'},
+	KeyF8:     {19, '~'},
+	KeyF9:     {20, '~'},
+	KeyF10:    {21, '~'},
+	KeyF11:    {23, '~'},
+	KeyF12:    {24, '~'},
+}
+
+// kittyLegacyKeys are the special keys that keep their legacy CSI encoding in
+// the Kitty keyboard protocol. F3 is encoded as CSI 13 ~ to avoid conflicting
+// with [ansi.CPR].
+var kittyLegacyKeys = func() map[rune]csiKeySeq {
+	m := make(map[rune]csiKeySeq, len(legacyKeys))
+	for k, v := range legacyKeys {
+		m[k] = v
+	}
+	m[KeyF3] = csiKeySeq{13, '~'}
+	return m
+}()
+
+// kittyKeyCodes maps keys to their Kitty keyboard protocol key codes. Keys
+// that aren't in this map use their Unicode codepoint.
+var kittyKeyCodes = func() map[rune]int {
+	m := map[rune]int{
+		KeyEscape:    27,
+		KeyEnter:     13,
+		KeyTab:       9,
+		KeyBackspace: 127,
+	}
+	// Functional keys are encoded using the Unicode Private Use Area.
+	for i, k := range []rune{
+		KeyCapsLock, KeyScrollLock, KeyNumLock, KeyPrintScreen, KeyPause, KeyMenu,
+	} {
+		m[k] = 57358 + i
+	}
+	for k := KeyF13; k <= KeyF35; k++ {
+		m[k] = 57376 + int(k-KeyF13)
+	}
+	for k := KeyKp0; k <= KeyKp9; k++ {
+		m[k] = 57399 + int(k-KeyKp0)
+	}
+	for i, k := range []rune{
+		KeyKpDecimal, KeyKpDivide, KeyKpMultiply, KeyKpMinus, KeyKpPlus,
+		KeyKpEnter, KeyKpEqual, KeyKpSep, KeyKpLeft, KeyKpRight, KeyKpUp,
+		KeyKpDown, KeyKpPgUp, KeyKpPgDown, KeyKpHome, KeyKpEnd, KeyKpInsert,
+		KeyKpDelete, KeyKpBegin, KeyMediaPlay, KeyMediaPause,
+		KeyMediaPlayPause, KeyMediaReverse, KeyMediaStop,
+		KeyMediaFastForward, KeyMediaRewind, KeyMediaNext, KeyMediaPrev,
+		KeyMediaRecord, KeyLowerVol, KeyRaiseVol, KeyMute, KeyLeftShift,
+		KeyLeftCtrl, KeyLeftAlt, KeyLeftSuper, KeyLeftHyper, KeyLeftMeta,
+		KeyRightShift, KeyRightCtrl, KeyRightAlt, KeyRightSuper,
+		KeyRightHyper, KeyRightMeta, KeyIsoLevel3Shift, KeyIsoLevel5Shift,
+	} {
+		m[k] = 57409 + i
+	}
+	return m
+}()
+
 // Key codes.
 const (
 	KeyExtended         = uv.KeyExtended
@@ -0,0 +1,105 @@
+package vt
+
+import (
+	"io"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// readInput returns the data written to the emulator input pipe by fn.
+func readInput(t testing.TB, e *Emulator, fn func()) string {
+	t.Helper()
+	go func() {
+		fn()
+		// Write a sentinel to mark the end of the input.
+		_, _ = io.WriteString(e.InputPipe(), "\x00")
+	}()
+	var got []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := e.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil || (len(got) > 0 && got[len(got)-1] == 0) {
+			break
+		}
+	}
+	if len(got) > 0 && got[len(got)-1] == 0 {
+		got = got[:len(got)-1]
+	}
+	return string(got)
+}
+
+func TestSendKey(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup string
+		key   uv.KeyEvent
+		want  string
+	}{
+		{"legacy text", "", KeyPressEvent{Code: 'a', Text: "a"}, "a"},
+		{"legacy shift text", "", KeyPressEvent{Code: 'a', Text: "A", Mod: ModShift}, "A"},
+		{"legacy ctrl", "", KeyPressEvent{Code: 'c', Mod: ModCtrl}, "\x03"},
+		{"legacy alt", "", KeyPressEvent{Code: 'a', Mod: ModAlt}, "\x1ba"},
+		{"legacy ctrl up", "", KeyPressEvent{Code: KeyUp, Mod: ModCtrl}, "\x1b[1;5A"},
+		{"legacy shift delete", "", KeyPressEvent{Code: KeyDelete, Mod: ModShift}, "\x1b[3;2~"},
+		{"legacy release", "", KeyReleaseEvent{Code: 'a'}, ""},
+		{"modifyOtherKeys 1 ctrl", "\x1b[>4;1m", KeyPressEvent{Code: 'a', Mod: ModCtrl}, "\x01"},
+		{"modifyOtherKeys 1 ctrl shift", "\x1b[>4;1m", KeyPressEvent{Code: 'a', Mod: ModCtrl | ModShift}, "\x1b[27;6;97~"},
+		{"modifyOtherKeys 1 ctrl enter", "\x1b[>4;1m", KeyPressEvent{Code: KeyEnter, Mod: ModCtrl}, "\x1b[27;5;13~"},
+		{"modifyOtherKeys 2 ctrl", "\x1b[>4;2m", KeyPressEvent{Code: 'i', Mod: ModCtrl}, "\x1b[27;5;105~"},
+		{"modifyOtherKeys 2 shift text", "\x1b[>4;2m", KeyPressEvent{Code: 'a', Text: "A", Mod: ModShift}, "A"},
+		{"modifyOtherKeys reset", "\x1b[>4;2m\x1b[>4m", KeyPressEvent{Code: 'a', Mod: ModCtrl}, "\x01"},
+		{"kitty text", "\x1b[>1u", KeyPressEvent{Code: 'a', Text: "a"}, "a"},
+		{"kitty ctrl i", "\x1b[>1u", KeyPressEvent{Code: 'i', Mod: ModCtrl}, "\x1b[105;5u"},
+		{"kitty tab", "\x1b[>1u", KeyPressEvent{Code: KeyTab}, "\t"},
+		{"kitty ctrl shift a", "\x1b[>1u", KeyPressEvent{Code: 'a', Mod: ModCtrl | ModShift}, "\x1b[97;6u"},
+		{"kitty escape", "\x1b[>1u", KeyPressEvent{Code: KeyEscape}, "\x1b[27u"},
+		{"kitty up", "\x1b[>1u", KeyPressEvent{Code: KeyUp}, "\x1b[A"},
+		{"kitty ctrl F3", "\x1b[>1u", KeyPressEvent{Code: KeyF3, Mod: ModCtrl}, "\x1b[13;5~"},
+		{"kitty super", "\x1b[>1u", KeyPressEvent{Code: 'a', Mod: ModSuper}, "\x1b[97;9u"},
+		{"kitty release ignored", "\x1b[>1u", KeyReleaseEvent{Code: 'a'}, ""},
+		{"kitty release", "\x1b[>3u", KeyReleaseEvent{Code: 'a'}, "\x1b[97;1:3u"},
+		{"kitty repeat", "\x1b[>3u", KeyPressEvent{Code: 'a', Mod: ModCtrl, IsRepeat: true}, "\x1b[97;5:2u"},
+		{"kitty release up", "\x1b[>3u", KeyReleaseEvent{Code: KeyUp}, "\x1b[1;1:3A"},
+		{"kitty alternate keys", "\x1b[>5u", KeyPressEvent{Code: 'a', ShiftedCode: 'A', Mod: ModShift | ModCtrl}, "\x1b[97:65;6u"},
+		{"kitty all keys", "\x1b[>8u", KeyPressEvent{Code: 'a', Text: "a"}, "\x1b[97u"},
+		{"kitty all keys enter", "\x1b[>8u", KeyPressEvent{Code: KeyEnter}, "\x1b[13u"},
+		{"kitty all keys modifier", "\x1b[>8u", KeyPressEvent{Code: KeyLeftShift, Mod: ModShift}, "\x1b[57441;2u"},
+		{"kitty associated text", "\x1b[>24u", KeyPressEvent{Code: 'a', Text: "A", Mod: ModShift}, "\x1b[97;2;65u"},
+		{"kitty keypad", "\x1b[>1u", KeyPressEvent{Code: KeyKpEnter}, "\x1b[57414u"},
+		{"kitty set mode", "\x1b[=1;1u\x1b[=8;2u", KeyPressEvent{Code: 'a', Text: "a"}, "\x1b[97u"},
+		{"kitty pop", "\x1b[>1u\x1b[>8u\x1b[<u", KeyPressEvent{Code: 'a', Text: "a"}, "a"},
+		{"kitty pop all", "\x1b[>1u\x1b[<5u", KeyPressEvent{Code: KeyEscape}, "\x1b"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestTerminal(t, 10, 2)
+			_, _ = e.WriteString(tc.setup)
+			got := readInput(t, e, func() { e.SendKey(tc.key) })
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestKittyKeyboardQuery(t *testing.T) {
+	e := newTestTerminal(t, 10, 2)
+	got := readInput(t, e, func() { _, _ = e.WriteString("\x1b[>5u\x1b[=2;2u\x1b[?u") })
+	if want := "\x1b[?7u"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// The alternate screen has its own stack.
+	got = readInput(t, e, func() { _, _ = e.WriteString("\x1b[?1049h\x1b[?u") })
+	if want := "\x1b[?0u"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	got = readInput(t, e, func() { _, _ = e.WriteString("\x1b[>4;2m\x1b[?4m") })
+	if want := "\x1b[>4;2m"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,758 @@
+package vt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/ansi/kitty"
+)
+
+// DefaultKittyImageLimit is the default memory limit in bytes of the Kitty
+// graphics image store.
+const DefaultKittyImageLimit = 320 * 1024 * 1024
+
+// kittyImage is an image transmitted using the Kitty graphics protocol.
+type kittyImage struct {
+	id, number int
+	img        image.Image
+	// size is the memory used by the image in bytes.
+	size int
+	// virtual are the virtual placements of the image used with Unicode
+	// placeholders, keyed by placement ID.
+	virtual map[int]ImagePlacement
+}
+
+// kittyChunk is a pending chunked transmission.
+type kittyChunk struct {
+	opts kitty.Options
+	data []byte
+	// dropped indicates the transmission exceeded the store limit, its
+	// remaining chunks are ignored.
+	dropped bool
+}
+
+// kittyStore is the Kitty graphics protocol image store.
+type kittyStore struct {
+	// images are the stored images, oldest first.
+	images []*kittyImage
+	// size is the memory used by the stored images in bytes.
+	size int
+	// limit is the maximum memory used by the stored images in bytes.
+	limit int
+	// lastID is the last image ID assigned by the terminal.
+	lastID int
+	// chunk is the pending chunked transmission, if any.
+	chunk *kittyChunk
+	// files indicates whether images can be transmitted using files and
+	// temporary files.
+	files bool
+}
+
+// image returns the image with the given ID.
+func (s *kittyStore) image(id int) *kittyImage {
+	for _, img := range s.images {
+		if img.id == id {
+			return img
+		}
+	}
+	return nil
+}
+
+// imageNumber returns the newest image with the given number.
+func (s *kittyStore) imageNumber(number int) *kittyImage {
+	for i := len(s.images) - 1; i >= 0; i-- {
+		if s.images[i].number == number {
+			return s.images[i]
+		}
+	}
+	return nil
+}
+
+// nextID returns an unused image ID.
+func (s *kittyStore) nextID() int {
+	for {
+		s.lastID++
+		if s.lastID > 1<<32-1 {
+			s.lastID = 1
+		}
+		if s.image(s.lastID) == nil {
+			return s.lastID
+		}
+	}
+}
+
+// remove removes the image with the given ID from the store.
+func (s *kittyStore) remove(id int) {
+	for i, img := range s.images {
+		if img.id == id {
+			s.size -= img.size
+			s.images = append(s.images[:i], s.images[i+1:]...)
+			return
+		}
+	}
+}
+
+// reset removes all the images from the store.
+func (s *kittyStore) reset() {
+	s.images = nil
+	s.size = 0
+	s.chunk = nil
+}
+
+// KittyImage returns the image with the given ID transmitted using the Kitty
+// graphics protocol.
+func (e *Emulator) KittyImage(id int) (image.Image, bool) {
+	if img := e.kitty.image(id); img != nil {
+		return img.img, true
+	}
+	return nil, false
+}
+
+// KittyVirtualPlacements returns the Kitty graphics virtual placements. These
+// placements are displayed using Unicode placeholder cells, their bounds
+// are the number of columns and rows of the placement.
+func (e *Emulator) KittyVirtualPlacements() []ImagePlacement {
+	var placements []ImagePlacement
+	for _, img := range e.kitty.images {
+		for _, p := range img.virtual {
+			placements = append(placements, p)
+		}
+	}
+	return placements
+}
+
+// SetKittyImageLimit sets the maximum memory in bytes used by the Kitty
+// graphics image store. When the limit is exceeded, the oldest images are
+// evicted. A non-positive value resets the limit to
+// [DefaultKittyImageLimit].
+func (e *Emulator) SetKittyImageLimit(limit int) {
+	if limit <= 0 {
+		limit = DefaultKittyImageLimit
+	}
+	e.kitty.limit = limit
+	e.evictKittyImages()
+}
+
+// SetKittyFileTransmission sets whether programs can transmit Kitty graphics
+// images using files and temporary files. It's disabled by default as it lets
+// any program writing to the terminal read the files the host can read.
+func (e *Emulator) SetKittyFileTransmission(enabled bool) {
+	e.kitty.files = enabled
+}
+
+// evictKittyImages removes the oldest images from the store until the memory
+// used is within the limit. Images without placements are evicted first.
+func (e *Emulator) evictKittyImages() {
+	for _, placed := range []bool{false, true} {
+		for i := 0; i < len(e.kitty.images) && e.kitty.size > e.kitty.limit; {
+			img := e.kitty.images[i]
+			if !placed && e.hasKittyPlacements(img.id) {
+				i++
+				continue
+			}
+			e.deleteKittyImage(img.id)
+		}
+	}
+}
+
+// hasKittyPlacements reports whether the image with the given ID has any
+// placements on the screens.
+func (e *Emulator) hasKittyPlacements(id int) bool {
+	for i := range e.scrs {
+		for _, p := range e.scrs[i].images {
+			if p.ImageID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deleteKittyImage removes the image with the given ID from the store along
+// with all of its placements.
+func (e *Emulator) deleteKittyImage(id int) {
+	e.kitty.remove(id)
+	for i := range e.scrs {
+		e.scrs[i].images = removeImages(e.scrs[i].images, func(p ImagePlacement) bool {
+			return p.ImageID == id
+		})
+	}
+}
+
+// handleKittyGraphics handles a Kitty graphics protocol APC sequence. It
+// returns false if the sequence is not a Kitty graphics command.
+//
+//	APC G [comma separated options] ; [base64 encoded payload] ST
+func (e *Emulator) handleKittyGraphics(data []byte) bool {
+	if len(data) == 0 || data[0] != 'G' {
+		return false
+	}
+
+	control, payload, _ := bytes.Cut(data[1:], []byte{';'})
+	var opts kitty.Options
+	_ = opts.UnmarshalText(control)
+
+	// The m and C keys are not handled by [kitty.Options.UnmarshalText].
+	var more bool
+	for _, kv := range bytes.Split(control, []byte{','}) {
+		switch string(kv) {
+		case "m=1":
+			more = true
+		case "C=1":
+			opts.DoNotMoveCursor = true
+		}
+	}
+
+	c := e.kitty.chunk
+	if c == nil {
+		if !more {
+			e.handleKittyCommand(&opts, payload)
+			return true
+		}
+		c = &kittyChunk{opts: opts}
+		e.kitty.chunk = c
+	}
+
+	// Chunked transmissions are limited to the store size.
+	if !c.dropped {
+		if len(c.data)+len(payload) > e.kitty.limit {
+			c.data, c.dropped = nil, true
+			e.kittyReply(&c.opts, errKittyTooLarge)
+		} else {
+			c.data = append(c.data, payload...)
+		}
+	}
+	if more {
+		return true
+	}
+	e.kitty.chunk = nil
+	if !c.dropped {
+		e.handleKittyCommand(&c.opts, c.data)
+	}
+	return true
+}
+
+// handleKittyCommand handles a complete Kitty graphics command.
+func (e *Emulator) handleKittyCommand(opts *kitty.Options, payload []byte) {
+	switch opts.Action {
+	case 0, kitty.Transmit, kitty.TransmitAndPut, kitty.Query:
+		img, err := e.decodeKittyImage(opts, payload)
+		if opts.Action == kitty.Query {
+			e.kittyReply(opts, err)
+			return
+		}
+		if err != nil {
+			e.kittyReply(opts, err)
+			return
+		}
+
+		ki := &kittyImage{
+			id:     opts.ID,
+			number: opts.Number,
+			img:    img,
+			size:   img.Bounds().Dx() * img.Bounds().Dy() * 4, //nolint:mnd
+		}
+		if ki.id == 0 {
+			ki.id = e.kitty.nextID()
+		} else if old := e.kitty.image(ki.id); old != nil {
+			// Transmitting an image with an existing ID replaces it.
+			e.deleteKittyImage(ki.id)
+		}
+		e.kitty.images = append(e.kitty.images, ki)
+		e.kitty.size += ki.size
+		if opts.Number > 0 {
+			// Replies must include the assigned ID.
+			opts.ID = ki.id
+		}
+
+		if opts.Action == kitty.TransmitAndPut {
+			err = e.putKittyImage(opts, ki)
+		}
+		e.evictKittyImages()
+		e.kittyReply(opts, err)
+
+	case kitty.Put:
+		var ki *kittyImage
+		if opts.ID > 0 {
+			ki = e.kitty.image(opts.ID)
+		} else if opts.Number > 0 {
+			ki = e.kitty.imageNumber(opts.Number)
+		}
+		if ki == nil {
+			e.kittyReply(opts, errKittyNoEntry)
+			return
+		}
+		opts.ID = ki.id
+		e.kittyReply(opts, e.putKittyImage(opts, ki))
+
+	case kitty.Delete:
+		e.deleteKittyPlacements(opts)
+
+	default:
+		e.kittyReply(opts, fmt.Errorf("EINVAL:unsupported action %q", opts.Action))
+	}
+}
+
+// Kitty graphics errors.
+var (
+	// errKittyNoEntry is returned when a Kitty graphics image is not found.
+	errKittyNoEntry = errors.New("ENOENT:image not found")
+	// errKittyTooLarge is returned when a Kitty graphics transmission
+	// exceeds the image store limit.
+	errKittyTooLarge = errors.New("EFBIG:image too large")
+)
+
+// kittyReply writes a Kitty graphics protocol reply to the input pipe. A nil
+// error replies with OK. Replies are only sent for commands that specify an
+// image ID or number, and respect the quiet mode of the command.
+func (e *Emulator) kittyReply(opts *kitty.Options, err error) {
+	if opts.ID == 0 && opts.Number == 0 {
+		return
+	}
+	if opts.Quite >= 2 || (err == nil && opts.Quite == 1) { //nolint:mnd
+		return
+	}
+
+	keys := []string{fmt.Sprintf("i=%d", opts.ID)}
+	if opts.Number > 0 {
+		keys = append(keys, fmt.Sprintf("I=%d", opts.Number))
+	}
+	if opts.PlacementID > 0 {
+		keys = append(keys, fmt.Sprintf("p=%d", opts.PlacementID))
+	}
+
+	msg := "OK"
+	if err != nil {
+		msg = err.Error()
+		if !strings.HasPrefix(msg, "E") || !strings.Contains(msg, ":") {
+			msg = "EINVAL:" + msg
+		}
+	}
+	_, _ = io.WriteString(e.pw, ansi.KittyGraphics([]byte(msg), keys...))
+}
+
+// decodeKittyImage decodes the image data of a transmission command.
+func (e *Emulator) decodeKittyImage(opts *kitty.Options, payload []byte) (image.Image, error) {
+	raw, err := base64.StdEncoding.DecodeString(string(payload))
+	if err != nil {
+		raw, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(string(payload), "="))
+		if err != nil {
+			return nil, errors.New("EINVAL:invalid base64 data")
+		}
+	}
+
+	switch opts.Transmission {
+	case 0, kitty.Direct:
+	case kitty.File, kitty.TempFile:
+		if !e.kitty.files {
+			return nil, fmt.Errorf("EINVAL:unsupported transmission medium %q", opts.Transmission)
+		}
+		path := string(raw)
+		temp := opts.Transmission == kitty.TempFile
+		if temp && !isKittyTempFile(path) {
+			return nil, errKittyBadFile
+		}
+		raw, err = readKittyFile(path, opts.Offset, opts.Size, e.kitty.limit)
+		if temp {
+			_ = os.Remove(filepath.Clean(path))
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("EINVAL:unsupported transmission medium %q", opts.Transmission)
+	}
+
+	if opts.Compression == kitty.Zlib {
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("EBADF:%w", err)
+		}
+		raw, err = io.ReadAll(io.LimitReader(zr, int64(e.kitty.limit)+1))
+		if err != nil {
+			return nil, fmt.Errorf("EBADF:%w", err)
+		}
+		if len(raw) > e.kitty.limit {
+			return nil, errKittyTooLarge
+		}
+	}
+
+	format := opts.Format
+	if format == 0 {
+		format = kitty.RGBA
+	}
+	width, height := opts.ImageWidth, opts.ImageHeight
+	switch format {
+	case kitty.PNG:
+		cfg, err := png.DecodeConfig(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("EBADF:%w", err)
+		}
+		width, height = cfg.Width, cfg.Height
+	case kitty.RGB, kitty.RGBA:
+		if width <= 0 || height <= 0 {
+			return nil, errors.New("EINVAL:missing image dimensions")
+		}
+	}
+	// Decoded images use 4 bytes per pixel.
+	if width <= 0 || height <= 0 || width > e.kitty.limit/4/height { //nolint:mnd
+		return nil, errors.New("EINVAL:invalid image dimensions")
+	}
+	if format != kitty.PNG {
+		bpp := 4 //nolint:mnd
+		if format == kitty.RGB {
+			bpp = 3 //nolint:mnd
+		}
+		if len(raw) != width*height*bpp {
+			return nil, errors.New("EINVAL:image data size mismatch")
+		}
+	}
+
+	dec := kitty.Decoder{
+		Format: format,
+		Width:  width,
+		Height: height,
+	}
+	img, err := dec.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("EBADF:%w", err)
+	}
+	return img, nil
+}
+
+// errKittyBadFile is returned when a Kitty graphics image file can't be read.
+var errKittyBadFile = errors.New("EBADF:cannot read file")
+
+// kittyTempFilePrefix is the prefix of the temporary files used to transmit
+// Kitty graphics images.
+const kittyTempFilePrefix = "tty-graphics-protocol"
+
+// isKittyTempFile reports whether path is a temporary file that can be used
+// to transmit a Kitty graphics image, one directly inside the temporary
+// directory with the [kittyTempFilePrefix] prefix. These are deleted once
+// read.
+func isKittyTempFile(path string) bool {
+	path = filepath.Clean(path)
+	return filepath.Dir(path) == filepath.Clean(os.TempDir()) &&
+		strings.HasPrefix(filepath.Base(path), kittyTempFilePrefix)
+}
+
+// readKittyFile reads size bytes of the file at path starting at offset. A
+// zero size reads the whole file. Files larger than limit bytes aren't read.
+func readKittyFile(path string, offset, size, limit int) ([]byte, error) {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil, errKittyBadFile
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errKittyBadFile
+	}
+	defer f.Close() //nolint:errcheck
+
+	var r io.Reader = io.NewSectionReader(f, int64(offset), fi.Size()-int64(offset))
+	if size > 0 {
+		r = io.LimitReader(r, int64(size))
+	}
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, errKittyBadFile
+	}
+	if len(data) > limit {
+		return nil, errKittyTooLarge
+	}
+	return data, nil
+}
+
+// putKittyImage places an image at the cursor position. The requested
+// columns and rows are limited to the screen size, and the scaled image to
+// the image store limit.
+func (e *Emulator) putKittyImage(opts *kitty.Options, ki *kittyImage) error {
+	img := ki.img
+	src := img.Bounds()
+	if opts.X > 0 || opts.Y > 0 || opts.Width > 0 || opts.Height > 0 {
+		r := image.Rect(src.Min.X+max(opts.X, 0), src.Min.Y+max(opts.Y, 0), src.Max.X, src.Max.Y)
+		if opts.Width > 0 {
+			r.Max.X = r.Min.X + opts.Width
+		}
+		if opts.Height > 0 {
+			r.Max.Y = r.Min.Y + opts.Height
+		}
+		src = r.Intersect(src)
+		if sub, ok := img.(interface {
+			SubImage(r image.Rectangle) image.Image
+		}); ok {
+			img = sub.SubImage(src)
+		}
+	}
+	if src.Empty() {
+		return nil
+	}
+
+	cw, ch := e.CellSize()
+	offset := image.Pt(min(max(opts.OffsetX, 0), cw-1), min(max(opts.OffsetY, 0), ch-1))
+	cols, rows := min(max(opts.Columns, 0), e.Width()), min(max(opts.Rows, 0), e.Height())
+	switch {
+	case cols == 0 && rows == 0:
+		cols = (offset.X + src.Dx() + cw - 1) / cw
+		rows = (offset.Y + src.Dy() + ch - 1) / ch
+	case cols == 0:
+		cols = min(max(1, (rows*ch*src.Dx()/src.Dy()+cw-1)/cw), e.Width())
+	case rows == 0:
+		rows = min(max(1, (cols*cw*src.Dy()/src.Dx()+ch-1)/ch), e.Height())
+	}
+	if cols > 0 && rows > 0 && (opts.Columns > 0 || opts.Rows > 0) {
+		// The image is scaled to fill the requested cells.
+		w, h := cols*cw-offset.X, rows*ch-offset.Y
+		if w > e.kitty.limit/4/h { //nolint:mnd
+			return errKittyTooLarge
+		}
+		img = scaleImage(img, w, h)
+	}
+
+	p := ImagePlacement{
+		Image:       img,
+		Bounds:      uv.Rect(0, 0, cols, rows),
+		Offset:      offset,
+		Z:           opts.Z,
+		ImageID:     ki.id,
+		PlacementID: opts.PlacementID,
+	}
+
+	if opts.VirtualPlacement {
+		if ki.virtual == nil {
+			ki.virtual = make(map[int]ImagePlacement)
+		}
+		ki.virtual[opts.PlacementID] = p
+		return nil
+	}
+
+	if opts.PlacementID > 0 {
+		// Placing an image with an existing placement ID replaces it.
+		for i := range e.scrs {
+			e.scrs[i].images = removeImages(e.scrs[i].images, func(q ImagePlacement) bool {
+				return q.ImageID == ki.id && q.PlacementID == opts.PlacementID
+			})
+		}
+	}
+
+	x, y := e.scr.CursorPosition()
+	p.Bounds = p.Bounds.Add(uv.Pos(x, y))
+	e.scr.addImage(p)
+
+	if !opts.DoNotMoveCursor {
+		// Move the cursor to the right of the image on its last row.
+		for range min(rows, e.Height()) - 1 {
+			e.index()
+		}
+		e.scr.setCursorX(x+cols, false)
+		e.atPhantom = false
+	}
+	return nil
+}
+
+// deleteKittyPlacements handles the Kitty graphics delete command. Lowercase
+// delete keys only remove placements while uppercase ones also free the
+// images that are no longer used.
+func (e *Emulator) deleteKittyPlacements(opts *kitty.Options) {
+	cx, cy := e.scr.CursorPosition()
+	cell := uv.Pos(opts.X-1, opts.Y-1)
+	at := func(p ImagePlacement, pos uv.Position) bool {
+		return pos.In(p.Bounds)
+	}
+
+	var match func(p ImagePlacement) bool
+	switch opts.Delete {
+	case 0, kitty.DeleteAll:
+		match = func(p ImagePlacement) bool { return p.Bounds.Overlaps(e.scr.Bounds()) }
+	case kitty.DeleteID, kitty.DeleteNumber:
+		id := opts.ID
+		if opts.Delete == kitty.DeleteNumber {
+			ki := e.kitty.imageNumber(opts.Number)
+			if ki == nil {
+				return
+			}
+			id = ki.id
+		}
+		match = func(p ImagePlacement) bool {
+			return p.ImageID == id && (opts.PlacementID == 0 || p.PlacementID == opts.PlacementID)
+		}
+		if ki := e.kitty.image(id); ki != nil {
+			if opts.PlacementID == 0 {
+				ki.virtual = nil
+			} else {
+				delete(ki.virtual, opts.PlacementID)
+			}
+		}
+	case kitty.DeleteCursor:
+		match = func(p ImagePlacement) bool { return at(p, uv.Pos(cx, cy)) }
+	case kitty.DeleteCell:
+		match = func(p ImagePlacement) bool { return at(p, cell) }
+	case kitty.DeleteCellZ:
+		match = func(p ImagePlacement) bool { return at(p, cell) && p.Z == opts.Z }
+	case kitty.DeleteRange:
+		match = func(p ImagePlacement) bool { return p.ImageID >= opts.X && p.ImageID <= opts.Y }
+	case kitty.DeleteColumn:
+		match = func(p ImagePlacement) bool { return cell.X >= p.Bounds.Min.X && cell.X < p.Bounds.Max.X }
+	case kitty.DeleteRow:
+		match = func(p ImagePlacement) bool { return cell.Y >= p.Bounds.Min.Y && cell.Y < p.Bounds.Max.Y }
+	case kitty.DeleteZ:
+		match = func(p ImagePlacement) bool { return p.Z == opts.Z }
+	default:
+		// Animation frames are not supported.
+		return
+	}
+
+	deleted := map[int]bool{}
+	e.scr.images = removeImages(e.scr.images, func(p ImagePlacement) bool {
+		if p.ImageID == 0 || !match(p) {
+			return false
+		}
+		deleted[p.ImageID] = true
+		e.scr.touchArea(p.Bounds.Intersect(e.scr.Bounds()))
+		return true
+	})
+	if opts.Delete == kitty.DeleteID || opts.Delete == kitty.DeleteRange {
+		for _, ki := range e.kitty.images {
+			if match(ImagePlacement{ImageID: ki.id, PlacementID: opts.PlacementID}) {
+				deleted[ki.id] = true
+			}
+		}
+	}
+
+	if !opts.DeleteResources {
+		return
+	}
+	for id := range deleted {
+		ki := e.kitty.image(id)
+		if ki != nil && len(ki.virtual) == 0 && !e.hasKittyPlacements(id) {
+			e.kitty.remove(id)
+		}
+	}
+}
+
+// kittyDiacritics maps the Kitty graphics Unicode placeholder diacritics to
+// their row and column values.
+var kittyDiacritics = sync.OnceValue(func() map[rune]int {
+	m := make(map[rune]int)
+	first := kitty.Diacritic(0)
+	m[first] = 0
+	for i := 1; ; i++ {
+		r := kitty.Diacritic(i)
+		if r == first {
+			break
+		}
+		m[r] = i
+	}
+	return m
+})
+
+// kittyColorID returns the image or placement ID encoded in a Unicode
+// placeholder cell color.
+func kittyColorID(c color.Color) int {
+	switch c := c.(type) {
+	case nil:
+		return 0
+	case ansi.BasicColor:
+		return int(c)
+	case ansi.IndexedColor:
+		return int(c)
+	default:
+		r, g, b, _ := c.RGBA()
+		return int(r>>8)<<16 | int(g>>8)<<8 | int(b>>8) //nolint:mnd
+	}
+}
+
+// drawKittyPlaceholders draws the Kitty graphics virtual placements displayed
+// using Unicode placeholder cells.
+//
+// The image ID is encoded in the foreground color of the cell and the
+// placement ID in the underline color. The row, column, and the most
+// significant byte of the image ID are encoded in diacritics following the
+// placeholder character. Missing diacritics are inferred from the cell on
+// the left.
+func (e *Emulator) drawKittyPlaceholders(dst draw.Image, cellWidth, cellHeight int) {
+	if len(e.kitty.images) == 0 {
+		return
+	}
+
+	type key struct{ id, pid int }
+	scaled := map[key]image.Image{}
+	origin := dst.Bounds().Min
+	for y := range e.Height() {
+		prev := key{-1, -1}
+		prevRow, prevCol := 0, -1
+		for x := range e.Width() {
+			c := e.scr.CellAt(x, y)
+			if c == nil || !strings.HasPrefix(c.Content, string(kitty.Placeholder)) {
+				prev = key{-1, -1}
+				continue
+			}
+
+			k := key{kittyColorID(c.Style.Fg), kittyColorID(c.Style.UnderlineColor)}
+			var marks []int
+			for _, r := range c.Content[utf8.RuneLen(kitty.Placeholder):] {
+				if v, ok := kittyDiacritics()[r]; ok {
+					marks = append(marks, v)
+				}
+			}
+			if len(marks) > 2 { //nolint:mnd
+				k.id |= marks[2] << 24 //nolint:mnd
+			}
+
+			row, col := 0, 0
+			switch {
+			case len(marks) >= 2: //nolint:mnd
+				row, col = marks[0], marks[1]
+			case len(marks) == 1:
+				row = marks[0]
+				if k == prev && row == prevRow {
+					col = prevCol + 1
+				}
+			case k == prev:
+				row, col = prevRow, prevCol+1
+			}
+			prev, prevRow, prevCol = k, row, col
+
+			ki := e.kitty.image(k.id)
+			if ki == nil {
+				continue
+			}
+			p, ok := ki.virtual[k.pid]
+			if !ok && k.pid == 0 {
+				for _, vp := range ki.virtual {
+					p, ok = vp, true
+					break
+				}
+			}
+			if !ok || row >= p.Bounds.Dy() || col >= p.Bounds.Dx() {
+				continue
+			}
+
+			img, ok := scaled[k]
+			if !ok {
+				img = scaleImage(p.Image, p.Bounds.Dx()*cellWidth, p.Bounds.Dy()*cellHeight)
+				scaled[k] = img
+			}
+			r := image.Rect(0, 0, cellWidth, cellHeight).Add(origin.Add(image.Pt(x*cellWidth, y*cellHeight)))
+			sp := img.Bounds().Min.Add(image.Pt(col*cellWidth, row*cellHeight))
+			draw.Draw(dst, r, img, sp, draw.Over)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package vt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi/kitty"
+)
+
+// kittyRGBA returns the base64 encoded RGBA data of a solid image with the
+// given size.
+func kittyRGBA(w, h int, c color.RGBA) string {
+	data := make([]byte, 0, w*h*4)
+	for range w * h {
+		data = append(data, c.R, c.G, c.B, c.A)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestKittyGraphics(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "transmit",
+			input: "\x1b_Gi=1,s=2,v=2;" + kittyRGBA(2, 2, red) + "\x1b\\",
+			want:  "\x1b_Gi=1;OK\x1b\\",
+		},
+		{
+			name:  "transmit with number",
+			input: "\x1b_GI=7,s=2,v=2;" + kittyRGBA(2, 2, red) + "\x1b\\",
+			want:  "\x1b_Gi=1,I=7;OK\x1b\\",
+		},
+		{
+			name:  "quiet transmit",
+			input: "\x1b_Gi=1,q=1,s=2,v=2;" + kittyRGBA(2, 2, red) + "\x1b\\",
+			want:  "",
+		},
+		{
+			name:  "missing dimensions",
+			input: "\x1b_Gi=1;" + kittyRGBA(2, 2, red) + "\x1b\\",
+			want:  "\x1b_Gi=1;EINVAL:missing image dimensions\x1b\\",
+		},
+		{
+			name:  "huge dimensions",
+			input: "\x1b_Ga=T,i=1,f=32,s=2000000000,v=2000000000;AAAA\x1b\\",
+			want:  "\x1b_Gi=1;EINVAL:invalid image dimensions\x1b\\",
+		},
+		{
+			name:  "data size mismatch",
+			input: "\x1b_Gi=1,s=3,v=2;" + kittyRGBA(2, 2, red) + "\x1b\\",
+			want:  "\x1b_Gi=1;EINVAL:image data size mismatch\x1b\\",
+		},
+		{
+			name:  "file transmission disabled",
+			input: "\x1b_Gi=1,t=f,s=1,v=1;" + base64.StdEncoding.EncodeToString([]byte("/etc/passwd")) + "\x1b\\",
+			want:  "\x1b_Gi=1;EINVAL:unsupported transmission medium 'f'\x1b\\",
+		},
+		{
+			name:  "query",
+			input: "\x1b_Gi=31,a=q,s=1,v=1;" + kittyRGBA(1, 1, red) + "\x1b\\",
+			want:  "\x1b_Gi=31;OK\x1b\\",
+		},
+		{
+			name:  "put missing image",
+			input: "\x1b_Gi=5,p=2,a=p\x1b\\",
+			want:  "\x1b_Gi=5,p=2;ENOENT:image not found\x1b\\",
+		},
+		{
+			name: "chunked transmit",
+			input: func() string {
+				data := kittyRGBA(2, 2, red)
+				return "\x1b_Gi=3,s=2,v=2,m=1;" + data[:8] + "\x1b\\" +
+					"\x1b_Gm=1;" + data[8:16] + "\x1b\\" +
+					"\x1b_Gm=0;" + data[16:] + "\x1b\\"
+			}(),
+			want: "\x1b_Gi=3;OK\x1b\\",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(10, 10)
+			got := readInput(t, e, func() {
+				_, _ = e.WriteString(tc.input)
+			})
+			if got != tc.want {
+				t.Errorf("reply = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKittyGraphicsPlacement(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	e := NewEmulator(10, 10)
+	readInput(t, e, func() {
+		_, _ = e.WriteString("\x1b[2;3H\x1b_Ga=T,i=1,p=4,z=-1,s=15,v=30;" + kittyRGBA(15, 30, red) + "\x1b\\")
+	})
+
+	images := e.Images()
+	if len(images) != 1 {
+		t.Fatalf("expected 1 image, got %d", len(images))
+	}
+	p := images[0]
+	if want := uv.Rect(2, 1, 2, 2); p.Bounds != want {
+		t.Errorf("bounds = %v, want %v", p.Bounds, want)
+	}
+	if p.ImageID != 1 || p.PlacementID != 4 || p.Z != -1 {
+		t.Errorf("placement = %d/%d z=%d, want 1/4 z=-1", p.ImageID, p.PlacementID, p.Z)
+	}
+	if pos, want := e.CursorPosition(), uv.Pos(4, 2); pos != want {
+		t.Errorf("cursor = %v, want %v", pos, want)
+	}
+
+	// Placing with the same placement ID replaces the placement.
+	readInput(t, e, func() {
+		_, _ = e.WriteString("\x1b[1;1H\x1b_Ga=p,i=1,p=4,c=3,r=1,C=1\x1b\\")
+	})
+	images = e.Images()
+	if len(images) != 1 || images[0].Bounds != uv.Rect(0, 0, 3, 1) {
+		t.Errorf("images = %v, want one image at the origin", images)
+	}
+	if pos, want := e.CursorPosition(), uv.Pos(0, 0); pos != want {
+		t.Errorf("cursor = %v, want %v", pos, want)
+	}
+
+	// Deleting the placement keeps the image data unless uppercase is used.
+	_, _ = e.WriteString("\x1b_Ga=d,d=i,i=1\x1b\\")
+	if images := e.Images(); len(images) != 0 {
+		t.Errorf("expected placements to be deleted, got %v", images)
+	}
+	if _, ok := e.KittyImage(1); !ok {
+		t.Errorf("expected image data to be kept")
+	}
+	_, _ = e.WriteString("\x1b_Ga=d,d=I,i=1\x1b\\")
+	if _, ok := e.KittyImage(1); ok {
+		t.Errorf("expected image data to be deleted")
+	}
+}
+
+func TestKittyGraphicsPlacementSize(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	e := NewEmulator(10, 5)
+	readInput(t, e, func() {
+		_, _ = e.WriteString("\x1b_Ga=T,i=1,f=32,s=1,v=1,c=60000,r=60000,X=-5,Y=-5;" + kittyRGBA(1, 1, red) + "\x1b\\")
+		_, _ = e.WriteString("\x1b_Ga=p,i=1,p=2,U=1,c=60000,r=60000\x1b\\")
+	})
+
+	// The requested cells are limited to the screen size.
+	images := e.Images()
+	if len(images) != 1 || images[0].Bounds != uv.Rect(0, 0, 10, 5) {
+		t.Fatalf("images = %v, want one image filling the screen", images)
+	}
+	cw, ch := e.CellSize()
+	if got, want := images[0].Image.Bounds(), image.Rect(0, 0, 10*cw, 5*ch); got != want {
+		t.Errorf("image bounds = %v, want %v", got, want)
+	}
+	if pos, want := e.CursorPosition(), uv.Pos(9, 4); pos != want {
+		t.Errorf("cursor = %v, want %v", pos, want)
+	}
+	if p := e.kitty.image(1).virtual[2]; p.Bounds != uv.Rect(0, 0, 10, 5) {
+		t.Errorf("virtual placement bounds = %v, want the screen size", p.Bounds)
+	}
+
+	// Scaled images are limited to the image store limit.
+	e.SetKittyImageLimit(1024)
+	got := readInput(t, e, func() {
+		_, _ = e.WriteString("\x1b_Ga=p,i=1,p=3,c=10,r=5\x1b\\")
+	})
+	if want := "\x1b_Gi=1,p=3;EFBIG:image too large\x1b\\"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
+
+func TestKittyGraphicsLimit(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	e := NewEmulator(10, 10)
+	e.SetKittyImageLimit(2 * 2 * 4 * 2)
+	for i := 1; i <= 3; i++ {
+		_, _ = e.WriteString(fmt.Sprintf("\x1b_Gi=%d,q=2,s=2,v=2;%s\x1b\\", i, kittyRGBA(2, 2, red)))
+	}
+	if _, ok := e.KittyImage(1); ok {
+		t.Errorf("expected the oldest image to be evicted")
+	}
+	for _, id := range []int{2, 3} {
+		if _, ok := e.KittyImage(id); !ok {
+			t.Errorf("expected image %d to be kept", id)
+		}
+	}
+}
+
+func TestKittyGraphicsChunkLimit(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	e := NewEmulator(10, 10)
+	e.SetKittyImageLimit(64)
+	data := kittyRGBA(4, 4, red)
+	got := readInput(t, e, func() {
+		_, _ = e.WriteString("\x1b_Gi=1,s=4,v=4,m=1;" + data[:40] + "\x1b\\" +
+			"\x1b_Gm=1;" + data[40:] + "\x1b\\" +
+			"\x1b_Gm=1;" + data + "\x1b\\" +
+			"\x1b_Gm=0;\x1b\\")
+	})
+	if want := "\x1b_Gi=1;EFBIG:image too large\x1b\\"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+	if _, ok := e.KittyImage(1); ok {
+		t.Errorf("expected the transmission to be dropped")
+	}
+
+	got = readInput(t, e, func() {
+		_, _ = e.WriteString("\x1b_Gi=2,s=2,v=2;" + kittyRGBA(2, 2, red) + "\x1b\\")
+	})
+	if want := "\x1b_Gi=2;OK\x1b\\"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
+
+func TestKittyGraphicsFiles(t *testing.T) {
+	rgba := make([]byte, 2*2*4)
+	path := func(p string) string {
+		return base64.StdEncoding.EncodeToString([]byte(p))
+	}
+	transmit := func(e *Emulator, medium, p string) string {
+		return readInput(t, e, func() {
+			_, _ = e.WriteString("\x1b_Gi=1,t=" + medium + ",s=2,v=2;" + path(p) + "\x1b\\")
+		})
+	}
+
+	file := filepath.Join(t.TempDir(), "image.rgba")
+	if err := os.WriteFile(file, rgba, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEmulator(10, 10)
+	e.SetKittyFileTransmission(true)
+	if got, want := transmit(e, "f", file), "\x1b_Gi=1;OK\x1b\\"; got != want {
+		t.Errorf("file: reply = %q, want %q", got, want)
+	}
+
+	e.SetKittyImageLimit(8)
+	if got, want := transmit(e, "f", file), "\x1b_Gi=1;EFBIG:image too large\x1b\\"; got != want {
+		t.Errorf("large file: reply = %q, want %q", got, want)
+	}
+	e.SetKittyImageLimit(0)
+
+	temp, err := os.CreateTemp("", "tty-graphics-protocol-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = temp.Write(rgba)
+	_ = temp.Close()
+	if got, want := transmit(e, "t", temp.Name()), "\x1b_Gi=1;OK\x1b\\"; got != want {
+		t.Errorf("temp file: reply = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(temp.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be removed")
+	}
+
+	// Temporary files outside the temporary directory are neither read nor
+	// removed.
+	dir := filepath.Join(t.TempDir(), "tty-graphics-protocol")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := transmit(e, "t", filepath.Join(dir, "..", "image.rgba")), "\x1b_Gi=1;EBADF:cannot read file\x1b\\"; got != want {
+		t.Errorf("outside temp file: reply = %q, want %q", got, want)
+	}
+	if got, want := transmit(e, "t", file), "\x1b_Gi=1;EBADF:cannot read file\x1b\\"; got != want {
+		t.Errorf("outside temp file: reply = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("expected the file to be kept: %v", err)
+	}
+}
+
+func TestKittyGraphicsPlaceholder(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	e := NewEmulator(4, 2)
+	_, _ = e.WriteString("\x1b_Ga=T,U=1,i=42,c=2,r=1,q=2,s=10,v=10;" + kittyRGBA(10, 10, red) + "\x1b\\")
+
+	if images := e.Images(); len(images) != 0 {
+		t.Errorf("expected no screen placements, got %v", images)
+	}
+	virtual := e.KittyVirtualPlacements()
+	if len(virtual) != 1 || virtual[0].Bounds != uv.Rect(0, 0, 2, 1) {
+		t.Fatalf("virtual placements = %v, want one 2x1 placement", virtual)
+	}
+
+	// The second placeholder infers its row and column from the first one.
+	placeholder := string(kitty.Placeholder)
+	_, _ = e.WriteString("\x1b[38;5;42m" + placeholder + string(kitty.Diacritic(0)) +
+		string(kitty.Diacritic(0)) + placeholder + "\x1b[m")
+
+	dst := image.NewRGBA(image.Rect(0, 0, 4*10, 2*20))
+	e.DrawImages(dst, 10, 20)
+	for _, pt := range []image.Point{{0, 0}, {19, 19}} {
+		if c := dst.RGBAAt(pt.X, pt.Y); c != red {
+			t.Errorf("pixel at %v = %v, want %v", pt, c, red)
+		}
+	}
+	if c := dst.RGBAAt(20, 0); c == red {
+		t.Errorf("pixel outside the placeholders is drawn")
+	}
+	if !strings.HasPrefix(e.CellAt(0, 0).Content, placeholder) {
+		t.Errorf("expected placeholder cell, got %q", e.CellAt(0, 0).Content)
+	}
+}
@@ -0,0 +1,128 @@
+package vt
+
+import uv "github.com/charmbracelet/ultraviolet"
+
+// LineSize is the size attribute of a line set using DECSWL, DECDWL, and
+// DECDHL. Lines that are not single width only use the left half of the
+// screen width, and each of their cells is displayed twice as wide.
+type LineSize uint8
+
+// Line sizes.
+const (
+	// LineSingle is a single-width and single-height line (DECSWL).
+	LineSingle LineSize = iota
+	// LineDoubleWidth is a double-width and single-height line (DECDWL).
+	LineDoubleWidth
+	// LineDoubleHeightTop is the top half of a double-width and
+	// double-height line (DECDHL).
+	LineDoubleHeightTop
+	// LineDoubleHeightBottom is the bottom half of a double-width and
+	// double-height line (DECDHL).
+	LineDoubleHeightBottom
+)
+
+// LineSize returns the size attribute of the line at the given y position on
+// the active screen.
+func (e *Emulator) LineSize(y int) LineSize {
+	return e.scr.LineSize(y)
+}
+
+// LineSize returns the size attribute of the line at the given y position.
+func (s *Screen) LineSize(y int) LineSize {
+	if y < 0 || y >= len(s.lineSizes) {
+		return LineSingle
+	}
+	return s.lineSizes[y]
+}
+
+// setLineSize sets the size attribute of the cursor line. The cells that
+// don't fit in a double-width line are erased and the cursor is kept within
+// the line.
+func (e *Emulator) setLineSize(size LineSize) {
+	s := e.scr
+	y := s.cur.Y
+	if s.LineSize(y) == size {
+		return
+	}
+	if s.lineSizes == nil {
+		if size == LineSingle {
+			return
+		}
+		s.lineSizes = make([]LineSize, s.buf.Height())
+	}
+	s.lineSizes[y] = size
+	if size != LineSingle {
+		w := s.lineWidth(y)
+		s.FillArea(nil, uv.Rect(w, y, s.buf.Width()-w, 1))
+		if s.cur.X >= w {
+			s.setCursorX(w-1, false)
+			e.atPhantom = false
+		}
+	}
+	s.touchArea(uv.Rect(0, y, s.buf.Width(), 1))
+}
+
+// lineWidth returns the number of columns usable on the line at the given y
+// position.
+func (s *Screen) lineWidth(y int) int {
+	if s.LineSize(y) == LineSingle {
+		return s.buf.Width()
+	}
+	return max(1, s.buf.Width()/2) //nolint:mnd
+}
+
+// resizeLineSizes resizes the line sizes to the given height.
+func (s *Screen) resizeLineSizes(height int) {
+	if s.lineSizes == nil {
+		return
+	}
+	if height <= len(s.lineSizes) {
+		s.lineSizes = s.lineSizes[:height]
+		return
+	}
+	s.lineSizes = append(s.lineSizes, make([]LineSize, height-len(s.lineSizes))...)
+}
+
+// resetLineSizes makes the lines from top to bottom, exclusive, single
+// width.
+func (s *Screen) resetLineSizes(top, bottom int) {
+	if s.lineSizes == nil {
+		return
+	}
+	clear(s.lineSizes[max(top, 0):min(bottom, len(s.lineSizes))])
+}
+
+// insertLineSizes shifts the line sizes down after n lines are inserted at y
+// within the given scroll region.
+func (s *Screen) insertLineSizes(y, n int, scroll uv.Rectangle) {
+	bottom := min(scroll.Max.Y, len(s.lineSizes))
+	if y < 0 || y >= bottom || !s.fullWidth(scroll) {
+		return
+	}
+	n = min(n, bottom-y)
+	copy(s.lineSizes[y+n:bottom], s.lineSizes[y:bottom-n])
+	clear(s.lineSizes[y : y+n])
+}
+
+// deleteLineSizes shifts the line sizes up after n lines are deleted at y
+// within the given scroll region.
+func (s *Screen) deleteLineSizes(y, n int, scroll uv.Rectangle) {
+	bottom := min(scroll.Max.Y, len(s.lineSizes))
+	if y < 0 || y >= bottom || !s.fullWidth(scroll) {
+		return
+	}
+	n = min(n, bottom-y)
+	copy(s.lineSizes[y:bottom-n], s.lineSizes[y+n:bottom])
+	clear(s.lineSizes[bottom-n : bottom])
+}
+
+// screenAlignment fills the screen with 'E' characters, resets the margins
+// and line sizes, and moves the cursor to the top left corner. This performs
+// DECALN.
+func (e *Emulator) screenAlignment() {
+	s := e.scr
+	s.lineSizes = nil
+	s.scroll = s.Bounds()
+	s.FillArea(&uv.Cell{Content: "E", Width: 1}, s.Bounds())
+	e.setCursorPosition(0, 0)
+}
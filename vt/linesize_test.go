@@ -0,0 +1,108 @@
+package vt
+
+import (
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+func TestLineSize(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		want   string
+		cursor uv.Position
+		sizes  []LineSize
+	}{
+		{
+			name:   "double width wraps at half width",
+			input:  "\x1b#6abcdefg",
+			want:   "abcde\nfg\n",
+			cursor: uv.Pos(2, 1),
+			sizes:  []LineSize{LineDoubleWidth, LineSingle},
+		},
+		{
+			name:   "cursor stays within half width",
+			input:  "\x1b#3\x1b[1;9H",
+			want:   "\n\n",
+			cursor: uv.Pos(4, 0),
+			sizes:  []LineSize{LineDoubleHeightTop},
+		},
+		{
+			name:  "right half is erased",
+			input: "0123456789\x1b[1;1H\x1b#4",
+			want:  "01234\n\n",
+			sizes: []LineSize{LineDoubleHeightBottom},
+		},
+		{
+			name:   "single width",
+			input:  "\x1b#6\x1b#5abcdefg",
+			want:   "abcdefg\n\n",
+			cursor: uv.Pos(7, 0),
+			sizes:  []LineSize{LineSingle},
+		},
+		{
+			name:  "moves with inserted lines",
+			input: "\x1b#6a\x1b[L",
+			want:  "\na\n",
+			sizes: []LineSize{LineSingle, LineDoubleWidth},
+		},
+		{
+			name:   "erase display",
+			input:  "\x1b#6a\x1b[2J",
+			want:   "\n\n",
+			cursor: uv.Pos(1, 0),
+			sizes:  []LineSize{LineSingle},
+		},
+		{
+			name:  "screen alignment",
+			input: "\x1b#6\x1b#8",
+			want:  "EEEEEEEEEE\nEEEEEEEEEE\nEEEEEEEEEE",
+			sizes: []LineSize{LineSingle},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(10, 3)
+			_, _ = e.WriteString(tc.input)
+			if got := e.String(); got != tc.want {
+				t.Errorf("screen = %q, want %q", got, tc.want)
+			}
+			if got := e.CursorPosition(); got != tc.cursor {
+				t.Errorf("cursor = %v, want %v", got, tc.cursor)
+			}
+			for y, want := range tc.sizes {
+				if got := e.LineSize(y); got != want {
+					t.Errorf("line %d size = %d, want %d", y, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLineSizeDraw(t *testing.T) {
+	e := NewEmulator(6, 2)
+	_, _ = e.WriteString("\x1b#6a世\r\ncd")
+
+	scr := uv.NewScreenBuffer(6, 2)
+	e.Draw(scr, scr.Bounds())
+	for _, tc := range []struct {
+		x, y int
+		want string
+	}{
+		{0, 0, "a"}, {1, 0, " "}, {2, 0, "世"}, {4, 0, " "}, {5, 0, " "},
+		{0, 1, "c"}, {1, 1, "d"},
+	} {
+		if got := scr.CellAt(tc.x, tc.y).Content; got != tc.want {
+			t.Errorf("cell (%d, %d) = %q, want %q", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	// Line sizes are replayed.
+	replay := NewEmulator(6, 2)
+	_, _ = replay.Write(e.ReplayANSI())
+	if replay.LineSize(0) != LineDoubleWidth || replay.String() != e.String() {
+		t.Errorf("replay = %q with line size %d", replay.String(), replay.LineSize(0))
+	}
+}
@@ -0,0 +1,235 @@
+package vt
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// LinkKind is the kind of a link on the screen.
+type LinkKind uint8
+
+// Link kinds.
+const (
+	// LinkHyperlink is a hyperlink set by a program using OSC 8.
+	LinkHyperlink LinkKind = iota
+	// LinkURL is a URL detected in plain text.
+	LinkURL
+	// LinkFile is a file reference with a line number, such as
+	// "main.go:42:7", detected in plain text.
+	LinkFile
+)
+
+// LinkSpan is a link on the screen.
+//
+// Positions are relative to the top of the screen, positions in the
+// scrollback buffer have negative Y coordinates. Both ends are inclusive and
+// a span follows soft-wrapped lines, so it can end on a later row than it
+// starts. A hyperlink that is interrupted by other text or a hard line break
+// is made of several spans that share the same URL and ID.
+type LinkSpan struct {
+	Kind LinkKind
+	// ID is the "id" parameter of a hyperlink, if any.
+	ID string
+	// URL is the target of the link. It's the path of file references.
+	URL string
+	// Params are the parameters of a hyperlink.
+	Params string
+	// Text is the text of the link on the screen.
+	Text string
+	// Line and Column are the 1-based line and column of file references.
+	// Column is zero when it's not given.
+	Line, Column int
+	Start, End   uv.Position
+}
+
+var (
+	// urlPattern matches URLs in plain text.
+	urlPattern = regexp.MustCompile(`\b(?:(?:https?|ftp|file)://|mailto:)[^\s<>"'` + "`" + `]+`)
+	// filePattern matches file references with a line number and an
+	// optional column in plain text.
+	filePattern = regexp.MustCompile(`(?:^|[\s"'(\[<=])((?:~|\.{1,2})?/?(?:[\w.+@-]+/)*[\w+@-][\w.+@-]*\.[A-Za-z][A-Za-z0-9]*):(\d+)(?::(\d+))?`)
+)
+
+// SetLinkDetection sets whether [Emulator.Links] and [Emulator.LinkAt] also
+// report the URLs and file references found in plain text. Hosts should
+// check that file references exist before opening them.
+func (e *Emulator) SetLinkDetection(enabled bool) {
+	e.linkDetection = enabled
+}
+
+// Links returns the links that intersect the rows from top to bottom
+// inclusive, in reading order. Negative rows are in the scrollback buffer.
+func (e *Emulator) Links(top, bottom int) []LinkSpan {
+	s := e.scr
+	top = max(top, -s.scrollback.Len())
+	bottom = min(bottom, s.buf.Height()-1)
+	var links []LinkSpan
+	for y := s.lineStart(top); y <= bottom; {
+		line, next := s.searchLine(y)
+		for _, l := range e.lineLinks(line) {
+			if l.End.Y >= top && l.Start.Y <= bottom {
+				links = append(links, l)
+			}
+		}
+		y = next
+	}
+	return links
+}
+
+// LinkAt returns the link at the given position. Negative rows are in the
+// scrollback buffer. It returns false if there's no link at the position.
+func (e *Emulator) LinkAt(x, y int) (LinkSpan, bool) {
+	s := e.scr
+	if y < -s.scrollback.Len() || y >= s.buf.Height() {
+		return LinkSpan{}, false
+	}
+	p := uv.Pos(x, y)
+	line, _ := s.searchLine(s.lineStart(y))
+	for _, l := range e.lineLinks(line) {
+		if !posBefore(p, l.Start) && !posBefore(l.End, p) {
+			return l, true
+		}
+	}
+	return LinkSpan{}, false
+}
+
+// lineLinks returns the links of a logical line in reading order.
+func (e *Emulator) lineLinks(line searchLine) []LinkSpan {
+	links := line.hyperlinks()
+	if !e.linkDetection {
+		return links
+	}
+	n := len(links)
+	links = append(links, line.detectLinks()...)
+	if len(links) > n {
+		sort.SliceStable(links, func(i, j int) bool {
+			return posBefore(links[i].Start, links[j].Start)
+		})
+	}
+	return links
+}
+
+// hyperlinks returns the spans of the cells sharing the same hyperlink in
+// the line.
+func (l searchLine) hyperlinks() []LinkSpan {
+	var links []LinkSpan
+	for i := 0; i < len(l.links); {
+		link := l.links[i]
+		j := i + 1
+		for j < len(l.links) && l.links[j] == link {
+			j++
+		}
+		if link.URL != "" {
+			start, end := l.offsets[i], len(l.text)
+			if j < len(l.offsets) {
+				end = l.offsets[j]
+			}
+			m := l.span(start, end)
+			links = append(links, LinkSpan{
+				Kind:   LinkHyperlink,
+				ID:     linkID(link.Params),
+				URL:    link.URL,
+				Params: link.Params,
+				Text:   l.text[start:end],
+				Start:  m.Start,
+				End:    m.End,
+			})
+		}
+		i = j
+	}
+	return links
+}
+
+// detectLinks returns the URLs and file references found in the plain text
+// of the line. Text that is part of a hyperlink is ignored.
+func (l searchLine) detectLinks() []LinkSpan {
+	var links []LinkSpan
+	var urls [][]int
+	for _, loc := range urlPattern.FindAllStringIndex(l.text, -1) {
+		text := trimURL(l.text[loc[0]:loc[1]])
+		loc[1] = loc[0] + len(text)
+		if l.hasHyperlink(loc[0], loc[1]) {
+			continue
+		}
+		urls = append(urls, loc)
+		m := l.span(loc[0], loc[1])
+		links = append(links, LinkSpan{Kind: LinkURL, URL: text, Text: text, Start: m.Start, End: m.End})
+	}
+
+	for _, loc := range filePattern.FindAllStringSubmatchIndex(l.text, -1) {
+		start, end := loc[2], loc[1]
+		if l.hasHyperlink(start, end) || overlaps(urls, start, end) {
+			continue
+		}
+		m := l.span(start, end)
+		link := LinkSpan{
+			Kind:  LinkFile,
+			URL:   l.text[loc[2]:loc[3]],
+			Text:  l.text[start:end],
+			Start: m.Start,
+			End:   m.End,
+		}
+		link.Line, _ = strconv.Atoi(l.text[loc[4]:loc[5]])
+		if loc[6] >= 0 {
+			link.Column, _ = strconv.Atoi(l.text[loc[6]:loc[7]])
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// hasHyperlink reports whether any of the cells spanned by the text between
+// the start and end byte offsets has a hyperlink.
+func (l searchLine) hasHyperlink(start, end int) bool {
+	for i := l.cellIndex(start); i <= l.cellIndex(end-1); i++ {
+		if l.links[i].URL != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps reports whether the range from start to end overlaps any of the
+// given ranges.
+func overlaps(ranges [][]int, start, end int) bool {
+	for _, r := range ranges {
+		if start < r[1] && r[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// trimURL removes the trailing punctuation that is likely not part of a URL
+// found in plain text, such as a period ending a sentence or the closing
+// parenthesis around the URL.
+func trimURL(s string) string {
+	for len(s) > 0 {
+		switch c := s[len(s)-1]; c {
+		case '.', ',', ';', ':', '!', '?':
+		case ')', ']', '}':
+			open := map[byte]string{')': "(", ']': "[", '}': "{"}[c]
+			if strings.Count(s, open) >= strings.Count(s, string(c)) {
+				return s
+			}
+		default:
+			return s
+		}
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// linkID returns the "id" parameter of the given hyperlink parameters.
+func linkID(params string) string {
+	for _, p := range strings.Split(params, ":") {
+		if id, ok := strings.CutPrefix(p, "id="); ok {
+			return id
+		}
+	}
+	return ""
+}
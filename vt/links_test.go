@@ -0,0 +1,105 @@
+package vt
+
+import (
+	"reflect"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestLinks(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		detect bool
+		want   []LinkSpan
+	}{
+		{
+			name:  "hyperlink",
+			input: "see " + ansi.SetHyperlink("https://a.dev", "id=1") + "docs" + ansi.ResetHyperlink() + " now",
+			want: []LinkSpan{{
+				Kind: LinkHyperlink, ID: "1", URL: "https://a.dev", Params: "id=1", Text: "docs",
+				Start: uv.Pos(4, 0), End: uv.Pos(7, 0),
+			}},
+		},
+		{
+			name:  "hyperlink soft wrap",
+			input: "xxxxxxx" + ansi.SetHyperlink("https://a.dev") + "abcde" + ansi.ResetHyperlink(),
+			want: []LinkSpan{{
+				Kind: LinkHyperlink, URL: "https://a.dev", Text: "abcde",
+				Start: uv.Pos(7, 0), End: uv.Pos(1, 1),
+			}},
+		},
+		{
+			name:  "hyperlink hard break",
+			input: ansi.SetHyperlink("https://a.dev", "id=x") + "ab\r\ncd" + ansi.ResetHyperlink(),
+			want: []LinkSpan{
+				{Kind: LinkHyperlink, ID: "x", URL: "https://a.dev", Params: "id=x", Text: "ab", Start: uv.Pos(0, 0), End: uv.Pos(1, 0)},
+				{Kind: LinkHyperlink, ID: "x", URL: "https://a.dev", Params: "id=x", Text: "cd", Start: uv.Pos(0, 1), End: uv.Pos(1, 1)},
+			},
+		},
+		{
+			name:  "no detection",
+			input: "https://a.dev",
+		},
+		{
+			name:   "url",
+			input:  "(https://a.dev/x_(y)).",
+			detect: true,
+			want: []LinkSpan{{
+				Kind: LinkURL, URL: "https://a.dev/x_(y)", Text: "https://a.dev/x_(y)",
+				Start: uv.Pos(1, 0), End: uv.Pos(9, 1),
+			}},
+		},
+		{
+			name:   "file",
+			input:  "err: ./vt/a.go:12:3 bad",
+			detect: true,
+			want: []LinkSpan{{
+				Kind: LinkFile, URL: "./vt/a.go", Text: "./vt/a.go:12:3", Line: 12, Column: 3,
+				Start: uv.Pos(5, 0), End: uv.Pos(8, 1),
+			}},
+		},
+		{
+			name:   "mixed",
+			input:  ansi.SetHyperlink("https://a.dev") + "x.go:1" + ansi.ResetHyperlink() + " y.go:2",
+			detect: true,
+			want: []LinkSpan{
+				{Kind: LinkHyperlink, URL: "https://a.dev", Text: "x.go:1", Start: uv.Pos(0, 0), End: uv.Pos(5, 0)},
+				{Kind: LinkFile, URL: "y.go", Text: "y.go:2", Line: 2, Start: uv.Pos(7, 0), End: uv.Pos(2, 1)},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(10, 3)
+			e.SetLinkDetection(tc.detect)
+			_, _ = e.WriteString(tc.input)
+			if got := e.Links(0, 2); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("links = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLinksScrollback(t *testing.T) {
+	e := NewEmulator(10, 2)
+	e.SetLinkDetection(true)
+	_, _ = e.WriteString("a.go:1\r\nb\r\nc\r\nd")
+
+	want := []LinkSpan{{Kind: LinkFile, URL: "a.go", Text: "a.go:1", Line: 1, Start: uv.Pos(0, -2), End: uv.Pos(5, -2)}}
+	if got := e.Links(-2, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %+v, want %+v", got, want)
+	}
+	if got := e.Links(-1, 1); got != nil {
+		t.Errorf("links = %+v, want none", got)
+	}
+	if got, ok := e.LinkAt(3, -2); !ok || !reflect.DeepEqual(got, want[0]) {
+		t.Errorf("LinkAt = %+v, %v, want %+v", got, ok, want[0])
+	}
+	if _, ok := e.LinkAt(6, -2); ok {
+		t.Errorf("LinkAt after the link found a link")
+	}
+}
@@ -2,9 +2,9 @@ package vt
 
 import "github.com/charmbracelet/x/ansi"
 
-// resetModes resets all modes to their default values.
-func (e *Emulator) resetModes() {
-	e.modes = ansi.Modes{
+// defaultModes returns the recognized modes and their default values.
+func defaultModes() ansi.Modes {
+	return ansi.Modes{
 		// Recognized modes and their default values.
 		ansi.ModeCursorKeys:          ansi.ModeReset, // ?1
 		ansi.ModeOrigin:              ansi.ModeReset, // ?6
@@ -14,17 +14,28 @@ func (e *Emulator) resetModes() {
 		ansi.ModeTextCursorEnable:    ansi.ModeSet,   // ?25
 		ansi.ModeNumericKeypad:       ansi.ModeReset, // ?66
 		ansi.ModeLeftRightMargin:     ansi.ModeReset, // ?69
+		ModeSixelDisplay:             ansi.ModeReset, // ?80
 		ansi.ModeMouseNormal:         ansi.ModeReset, // ?1000
 		ansi.ModeMouseHighlight:      ansi.ModeReset, // ?1001
 		ansi.ModeMouseButtonEvent:    ansi.ModeReset, // ?1002
 		ansi.ModeMouseAnyEvent:       ansi.ModeReset, // ?1003
 		ansi.ModeFocusEvent:          ansi.ModeReset, // ?1004
+		ansi.ModeMouseExtUtf8:        ansi.ModeReset, // ?1005
 		ansi.ModeMouseExtSgr:         ansi.ModeReset, // ?1006
+		ansi.ModeMouseExtUrxvt:       ansi.ModeReset, // ?1015
+		ansi.ModeMouseExtSgrPixel:    ansi.ModeReset, // ?1016
 		ansi.ModeAltScreen:           ansi.ModeReset, // ?1047
 		ansi.ModeSaveCursor:          ansi.ModeReset, // ?1048
 		ansi.ModeAltScreenSaveCursor: ansi.ModeReset, // ?1049
 		ansi.ModeBracketedPaste:      ansi.ModeReset, // ?2004
+		ansi.ModeSynchronizedOutput:  ansi.ModeReset, // ?2026
+		ModeSixelCursorRight:         ansi.ModeReset, // ?8452
 	}
+}
+
+// resetModes resets all modes to their default values.
+func (e *Emulator) resetModes() {
+	e.modes = defaultModes()
 
 	// Set mode effects.
 	for mode, setting := range e.modes {
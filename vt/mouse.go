@@ -2,6 +2,8 @@ package vt
 
 import (
 	"io"
+	"strconv"
+	"strings"
 
 	uv "github.com/charmbracelet/ultraviolet"
 	"github.com/charmbracelet/x/ansi"
@@ -59,9 +61,12 @@ type MouseMotion = uv.MouseMotionEvent
 
 // SendMouse sends a mouse event to the terminal. This can be any kind of mouse
 // events such as [MouseClick], [MouseRelease], [MouseWheel], or [MouseMotion].
+//
+// The event is only reported when one of the mouse tracking modes is enabled,
+// and it's encoded according to the active mouse encoding mode. For
+// [ansi.ModeMouseExtSgrPixel], the position is converted to pixels using the
+// terminal cell size, see [Emulator.SetCellSize].
 func (e *Emulator) SendMouse(m Mouse) {
-	// XXX: Support [Utf8ExtMouseMode], [UrxvtExtMouseMode], and
-	// [SgrPixelExtMouseMode].
 	var (
 		enc  ansi.Mode
 		mode ansi.Mode
@@ -84,32 +89,119 @@ func (e *Emulator) SendMouse(m Mouse) {
 	}
 
 	for _, mm := range []ansi.DECMode{
-		// ansi.Utf8ExtMouseMode,
+		ansi.ModeMouseExtUtf8,
 		ansi.ModeMouseExtSgr,
-		// ansi.UrxvtExtMouseMode,
-		// ansi.SgrPixelExtMouseMode,
+		ansi.ModeMouseExtUrxvt,
+		ansi.ModeMouseExtSgrPixel,
 	} {
 		if e.isModeSet(mm) {
 			enc = mm
 		}
 	}
 
-	// Encode button
 	mouse := m.Mouse()
 	_, isMotion := m.(MouseMotion)
 	_, isRelease := m.(MouseRelease)
-	b := ansi.EncodeMouseButton(mouse.Button, isMotion,
+
+	// Filter out events that the tracking mode doesn't report.
+	switch mode {
+	case ansi.ModeMouseX10:
+		if isMotion || isRelease {
+			return
+		}
+		// X10 mode doesn't report modifiers.
+		mouse.Mod = 0
+	case ansi.ModeMouseNormal, ansi.ModeMouseHighlight:
+		if isMotion {
+			return
+		}
+	case ansi.ModeMouseButtonEvent:
+		if isMotion && mouse.Button == MouseNone {
+			return
+		}
+	}
+
+	button := mouse.Button
+	if isRelease && enc != ansi.ModeMouseExtSgr && enc != ansi.ModeMouseExtSgrPixel {
+		if button >= MouseWheelUp && button <= MouseWheelRight {
+			// Wheel buttons don't have release events.
+			return
+		}
+		// Only SGR encodings report which button was released.
+		button = MouseNone
+	}
+
+	// Encode button
+	b := ansi.EncodeMouseButton(button, isMotion,
 		mouse.Mod.Contains(ModShift),
 		mouse.Mod.Contains(ModAlt),
 		mouse.Mod.Contains(ModCtrl))
 
+	// XXX: Support [ansi.ModeMouseHighlight] tracking.
+	var seq string
 	switch enc {
-	// XXX: Support [ansi.HighlightMouseMode].
-	// XXX: Support [ansi.Utf8ExtMouseMode], [ansi.UrxvtExtMouseMode], and
-	// [ansi.SgrPixelExtMouseMode].
 	case nil: // X10 mouse encoding
-		_, _ = io.WriteString(e.pw, ansi.MouseX10(b, mouse.X, mouse.Y))
+		seq = mouseX10(b, mouse.X, mouse.Y)
+	case ansi.ModeMouseExtUtf8: // UTF-8 mouse encoding
+		seq = mouseUtf8(b, mouse.X, mouse.Y)
 	case ansi.ModeMouseExtSgr: // SGR mouse encoding
-		_, _ = io.WriteString(e.pw, ansi.MouseSgr(b, mouse.X, mouse.Y, isRelease))
+		seq = ansi.MouseSgr(b, mouse.X, mouse.Y, isRelease)
+	case ansi.ModeMouseExtUrxvt: // urxvt mouse encoding
+		seq = mouseUrxvt(b, mouse.X, mouse.Y)
+	case ansi.ModeMouseExtSgrPixel: // SGR-Pixel mouse encoding
+		cw, ch := e.CellSize()
+		seq = ansi.MouseSgr(b, mouse.X*cw, mouse.Y*ch, isRelease)
+	}
+
+	if seq != "" {
+		_, _ = io.WriteString(e.pw, seq)
 	}
 }
+
+// Mouse encodings coordinate limits.
+const (
+	// x10MaxCoord is the maximum coordinate that can be encoded in a single
+	// byte with the X10 encoding.
+	x10MaxCoord = 0xff - 32 - 1
+	// utf8MaxCoord is the maximum coordinate that can be encoded in a two
+	// byte UTF-8 character with the UTF-8 encoding.
+	utf8MaxCoord = 0x7ff - 32 - 1
+)
+
+// mouseX10 returns the X10 mouse encoding of the given button and position.
+// It returns an empty string if the position can't be encoded.
+//
+//	CSI M Cb Cx Cy
+func mouseX10(b byte, x, y int) string {
+	if x < 0 || y < 0 || x > x10MaxCoord || y > x10MaxCoord {
+		return ""
+	}
+	return ansi.MouseX10(b, x, y)
+}
+
+// mouseUtf8 returns the UTF-8 extended mouse encoding of the given button and
+// position. It's the same as the X10 encoding, except that values above 127
+// are encoded as UTF-8 characters. It returns an empty string if the position
+// can't be encoded.
+//
+//	CSI M Cb Cx Cy
+func mouseUtf8(b byte, x, y int) string {
+	if x < 0 || y < 0 || x > utf8MaxCoord || y > utf8MaxCoord {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\x1b[M")
+	sb.WriteRune(rune(b) + 32)
+	sb.WriteRune(rune(x) + 32 + 1)
+	sb.WriteRune(rune(y) + 32 + 1)
+	return sb.String()
+}
+
+// mouseUrxvt returns the urxvt extended mouse encoding of the given button and
+// position.
+//
+//	CSI Cb ; Cx ; Cy M
+func mouseUrxvt(b byte, x, y int) string {
+	return "\x1b[" + strconv.Itoa(int(b)+32) + ";" + strconv.Itoa(max(x, 0)+1) + ";" +
+		strconv.Itoa(max(y, 0)+1) + "M"
+}
@@ -0,0 +1,47 @@
+package vt
+
+import "testing"
+
+func TestSendMouse(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup string
+		mouse Mouse
+		want  string
+	}{
+		{"no tracking", "", MouseClick{Button: MouseLeft}, ""},
+		{"x10", "\x1b[?1000h", MouseClick{X: 1, Y: 2, Button: MouseLeft}, "\x1b[M \"#"},
+		{"x10 release", "\x1b[?1000h", MouseRelease{X: 1, Y: 2, Button: MouseLeft}, "\x1b[M#\"#"},
+		{"x10 out of range", "\x1b[?1000h", MouseClick{X: 300, Y: 2, Button: MouseLeft}, ""},
+		{"x10 motion filtered", "\x1b[?1000h", MouseMotion{X: 1, Y: 2, Button: MouseLeft}, ""},
+		{"button event motion", "\x1b[?1002h", MouseMotion{X: 1, Y: 2, Button: MouseLeft}, "\x1b[M@\"#"},
+		{"utf8", "\x1b[?1000h\x1b[?1005h", MouseClick{X: 300, Y: 2, Button: MouseLeft}, "\x1b[M ō#"},
+		{"utf8 release", "\x1b[?1000h\x1b[?1005h", MouseRelease{X: 1, Y: 2, Button: MouseRight}, "\x1b[M#\"#"},
+		{"sgr", "\x1b[?1000h\x1b[?1006h", MouseClick{X: 300, Y: 2, Button: MouseLeft}, "\x1b[<0;301;3M"},
+		{"sgr release", "\x1b[?1000h\x1b[?1006h", MouseRelease{X: 1, Y: 2, Button: MouseRight}, "\x1b[<2;2;3m"},
+		{"urxvt", "\x1b[?1000h\x1b[?1015h", MouseClick{X: 300, Y: 2, Button: MouseLeft, Mod: ModCtrl}, "\x1b[48;301;3M"},
+		{"urxvt release", "\x1b[?1000h\x1b[?1015h", MouseRelease{X: 1, Y: 2, Button: MouseLeft}, "\x1b[35;2;3M"},
+		{"sgr pixel", "\x1b[?1000h\x1b[?1016h", MouseClick{X: 3, Y: 2, Button: MouseLeft}, "\x1b[<0;31;41M"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestTerminal(t, 10, 2)
+			_, _ = e.WriteString(tc.setup)
+			got := readInput(t, e, func() { e.SendMouse(tc.mouse) })
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("sgr pixel cell size", func(t *testing.T) {
+		e := newTestTerminal(t, 10, 2)
+		e.SetCellSize(8, 16)
+		_, _ = e.WriteString("\x1b[?1003h\x1b[?1016h")
+		got := readInput(t, e, func() { e.SendMouse(MouseMotion{X: 3, Y: 2}) })
+		if want := "\x1b[<35;25;33M"; got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	})
+}
@@ -0,0 +1,416 @@
+// Package mux provides a terminal multiplexer that composes the emulators of
+// several panes on a single [uv.Screen].
+//
+// Panes are arranged in a tree of horizontal and vertical splits. Each pane
+// is backed by a [vt.SafeEmulator] and an [xpty.Pty], the multiplexer copies
+// the PTY output to the emulator and sends the keyboard and mouse input to
+// the focused pane.
+package mux
+
+import (
+	"errors"
+	"sync"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/vt"
+	"github.com/charmbracelet/x/xpty"
+)
+
+// Separator cells drawn between split panes.
+var (
+	HorizontalSeparator = uv.Cell{Content: "│", Width: 1}
+	VerticalSeparator   = uv.Cell{Content: "─", Width: 1}
+)
+
+// Mux is a terminal multiplexer. It's safe for concurrent use.
+type Mux struct {
+	mu sync.Mutex
+
+	root          *node
+	width, height int
+	nextID        int
+
+	// focus is the pane receiving input, zoom is the pane filling the
+	// multiplexer, if any.
+	focus, zoom *Pane
+	// grab is the pane that received the last mouse click. It receives the
+	// mouse events until the button is released.
+	grab *Pane
+}
+
+// New creates a new multiplexer of the given size without panes.
+func New(width, height int) *Mux {
+	return &Mux{width: width, height: height}
+}
+
+// Bounds returns the bounds of the multiplexer.
+func (m *Mux) Bounds() uv.Rectangle {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return uv.Rect(0, 0, m.width, m.height)
+}
+
+// Split splits the focused pane in the given direction and runs the new
+// pane on the given PTY. The new pane is placed after the focused pane and
+// gets the focus. The first pane fills the multiplexer.
+//
+// The PTY is resized to the pane size, the program should be started after
+// it's split so that it sees the right size.
+func (m *Mux) Split(dir Direction, pty xpty.Pty) (*Pane, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	var leaf *node
+	if m.root == nil {
+		m.root = &node{}
+		leaf = m.root
+		leaf.pane = newPane(m.nextID, &m.mu, pty, m.width, m.height)
+	} else {
+		leaf = m.root.find(m.focus).split(dir, nil)
+		leaf.pane = newPane(m.nextID, &m.mu, pty, leaf.parent.area.Dx(), leaf.parent.area.Dy())
+	}
+
+	p := leaf.pane
+	m.zoom = nil
+	err := m.layout()
+	m.setFocus(p)
+	return p, err
+}
+
+// Remove removes the pane from the multiplexer and closes it. The sibling of
+// the pane takes its place.
+func (m *Mux) Remove(p *Pane) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	leaf := m.root.find(p)
+	if leaf == nil {
+		return nil
+	}
+
+	var next *Pane
+	if parent := leaf.parent; parent == nil {
+		m.root = nil
+	} else {
+		sibling := parent.children[1-leaf.index()]
+		sibling.parent = parent.parent
+		if parent.parent == nil {
+			m.root = sibling
+		} else {
+			parent.parent.children[parent.index()] = sibling
+		}
+		next = sibling.first()
+	}
+
+	if m.zoom == p {
+		m.zoom = nil
+	}
+	if m.grab == p {
+		m.grab = nil
+	}
+	if m.focus == p {
+		m.focus = nil
+		if next != nil {
+			m.setFocus(next)
+		}
+	}
+	return errors.Join(m.layout(), p.close())
+}
+
+// Close closes all the panes and removes them from the multiplexer.
+func (m *Mux) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, p := range m.root.panes(nil) {
+		errs = append(errs, p.close())
+	}
+	m.root, m.focus, m.zoom, m.grab = nil, nil, nil, nil
+	return errors.Join(errs...)
+}
+
+// Panes returns the panes in layout order, from left to right and top to
+// bottom.
+func (m *Mux) Panes() []*Pane {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.root.panes(nil)
+}
+
+// PaneAt returns the visible pane at the given position, or nil if there's
+// none.
+func (m *Mux) PaneAt(x, y int) *Pane {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.paneAt(x, y)
+}
+
+func (m *Mux) paneAt(x, y int) *Pane {
+	pos := uv.Pos(x, y)
+	if m.zoom != nil {
+		if pos.In(m.zoom.area) {
+			return m.zoom
+		}
+		return nil
+	}
+	for _, p := range m.root.panes(nil) {
+		if pos.In(p.area) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Focused returns the focused pane, or nil if there are no panes.
+func (m *Mux) Focused() *Pane {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.focus
+}
+
+// Focus focuses the given pane. The emulators report the focus change to
+// the programs that enabled [ansi.ModeFocusEvent]. Focusing a pane other
+// than the zoomed one unzooms it.
+func (m *Mux) Focus(p *Pane) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.root.find(p) == nil {
+		return
+	}
+	if m.zoom != nil && m.zoom != p {
+		m.zoom = nil
+		_ = m.layout()
+	}
+	m.setFocus(p)
+}
+
+// FocusNext focuses the pane after the focused one in layout order,
+// wrapping around.
+func (m *Mux) FocusNext() {
+	m.cycleFocus(1)
+}
+
+// FocusPrev focuses the pane before the focused one in layout order,
+// wrapping around.
+func (m *Mux) FocusPrev() {
+	m.cycleFocus(-1)
+}
+
+func (m *Mux) cycleFocus(delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.zoom != nil {
+		return
+	}
+	panes := m.root.panes(nil)
+	for i, p := range panes {
+		if p == m.focus {
+			m.setFocus(panes[(i+delta+len(panes))%len(panes)])
+			return
+		}
+	}
+}
+
+// setFocus moves the focus to the given pane.
+func (m *Mux) setFocus(p *Pane) {
+	if m.focus == p {
+		return
+	}
+	if m.focus != nil {
+		m.focus.emu.Blur()
+	}
+	m.focus = p
+	p.emu.Focus()
+}
+
+// Zoom makes the given pane fill the multiplexer and focuses it. A nil pane
+// restores the layout.
+func (m *Mux) Zoom(p *Pane) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p != nil && m.root.find(p) == nil {
+		return nil
+	}
+	m.zoom = p
+	if p != nil {
+		m.setFocus(p)
+	}
+	return m.layout()
+}
+
+// Zoomed returns the zoomed pane, or nil if no pane is zoomed.
+func (m *Mux) Zoomed() *Pane {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.zoom
+}
+
+// Resize resizes the multiplexer. The panes keep their relative sizes, and
+// their emulators and PTYs are resized along with them.
+func (m *Mux) Resize(width, height int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.width, m.height = width, height
+	return m.layout()
+}
+
+// ResizePane grows the pane by n cells in the given direction by moving the
+// nearest separator in that direction. Negative values shrink it. Each pane
+// keeps at least one cell.
+func (m *Mux) ResizePane(p *Pane, dir Direction, n int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n0 := m.root.find(p)
+	for n0 != nil && n0.parent != nil && n0.parent.dir != dir {
+		n0 = n0.parent
+	}
+	if n0 == nil || n0.parent == nil {
+		return nil
+	}
+
+	split := n0.parent
+	if n0.index() == 1 {
+		n = -n
+	}
+	avail := split.size() - 1
+	if avail < 2 { //nolint:mnd
+		return nil
+	}
+	first := min(max(splitSize(split.size(), split.ratio)+n, 1), avail-1)
+	split.ratio = float64(first) / float64(avail)
+	return m.layout()
+}
+
+// layout sets the areas of the panes.
+func (m *Mux) layout() error {
+	if m.root == nil {
+		return nil
+	}
+	var errs []error
+	m.root.layout(uv.Rect(0, 0, m.width, m.height), func(p *Pane, area uv.Rectangle) {
+		if p == m.zoom {
+			area = uv.Rect(0, 0, m.width, m.height)
+		}
+		errs = append(errs, p.setArea(area))
+	})
+	return errors.Join(errs...)
+}
+
+// SendKey sends a key event to the focused pane.
+func (m *Mux) SendKey(k uv.KeyEvent) {
+	if p := m.Focused(); p != nil {
+		p.emu.SendKey(k)
+	}
+}
+
+// SendText sends text to the focused pane.
+func (m *Mux) SendText(text string) {
+	if p := m.Focused(); p != nil {
+		p.emu.SendText(text)
+	}
+}
+
+// Paste pastes text into the focused pane.
+func (m *Mux) Paste(text string) {
+	if p := m.Focused(); p != nil {
+		p.emu.Paste(text)
+	}
+}
+
+// SendMouse sends a mouse event to the pane under the mouse, in the pane
+// coordinates. Clicking a pane focuses it, and the pane that received the
+// click gets the mouse events until the button is released even when the
+// mouse leaves it. Events over separators are ignored.
+func (m *Mux) SendMouse(ev vt.Mouse) {
+	m.mu.Lock()
+	mouse := ev.Mouse()
+	p := m.grab
+	if p == nil {
+		p = m.paneAt(mouse.X, mouse.Y)
+	}
+	switch ev.(type) {
+	case vt.MouseClick:
+		if p != nil {
+			m.grab = p
+			m.setFocus(p)
+		}
+	case vt.MouseRelease:
+		m.grab = nil
+	}
+	var area uv.Rectangle
+	if p != nil {
+		area = p.area
+	}
+	m.mu.Unlock()
+	if p == nil || area.Empty() {
+		return
+	}
+
+	mouse.X = min(max(mouse.X, area.Min.X), area.Max.X-1) - area.Min.X
+	mouse.Y = min(max(mouse.Y, area.Min.Y), area.Max.Y-1) - area.Min.Y
+	switch ev.(type) {
+	case vt.MouseClick:
+		ev = vt.MouseClick(mouse)
+	case vt.MouseRelease:
+		ev = vt.MouseRelease(mouse)
+	case vt.MouseWheel:
+		ev = vt.MouseWheel(mouse)
+	case vt.MouseMotion:
+		ev = vt.MouseMotion(mouse)
+	default:
+		return
+	}
+	p.emu.SendMouse(ev)
+}
+
+// CursorPosition returns the cursor position of the focused pane relative
+// to the multiplexer. It returns false if there are no panes.
+func (m *Mux) CursorPosition() (uv.Position, bool) {
+	p := m.Focused()
+	if p == nil {
+		return uv.Position{}, false
+	}
+	m.mu.Lock()
+	area := p.area
+	m.mu.Unlock()
+	pos := p.emu.CursorPosition()
+	return pos.Add(area.Min), true
+}
+
+// Draw draws the panes and the separators between them on the given area of
+// the screen. The area should have the size of the multiplexer, see
+// [Mux.Resize].
+func (m *Mux) Draw(scr uv.Screen, area uv.Rectangle) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	draw := func(p *Pane) {
+		a := p.area.Add(area.Min).Intersect(area)
+		if !a.Empty() {
+			p.emu.Draw(scr, a)
+		}
+	}
+	if m.zoom != nil {
+		draw(m.zoom)
+		return
+	}
+
+	for _, p := range m.root.panes(nil) {
+		draw(p)
+	}
+	m.root.walk(func(n *node) {
+		c := &HorizontalSeparator
+		if n.dir == Vertical {
+			c = &VerticalSeparator
+		}
+		sep := n.separator().Add(area.Min).Intersect(area)
+		for y := sep.Min.Y; y < sep.Max.Y; y++ {
+			for x := sep.Min.X; x < sep.Max.X; x++ {
+				scr.SetCell(x, y, c)
+			}
+		}
+	})
+}
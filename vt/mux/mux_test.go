@@ -0,0 +1,246 @@
+package mux
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/vt"
+)
+
+// fakePty is a PTY whose output is written by the test and whose input is
+// recorded.
+type fakePty struct {
+	out *io.PipeReader
+	pw  *io.PipeWriter
+
+	mu            sync.Mutex
+	in            bytes.Buffer
+	width, height int
+}
+
+func newFakePty() *fakePty {
+	pr, pw := io.Pipe()
+	return &fakePty{out: pr, pw: pw}
+}
+
+func (p *fakePty) Read(b []byte) (int, error) { return p.out.Read(b) } //nolint:wrapcheck
+
+func (p *fakePty) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.in.Write(b) //nolint:wrapcheck
+}
+
+func (p *fakePty) Close() error          { return p.pw.Close() } //nolint:wrapcheck
+func (p *fakePty) Fd() uintptr           { return 0 }
+func (p *fakePty) Name() string          { return "fake" }
+func (p *fakePty) Start(*exec.Cmd) error { return nil }
+
+func (p *fakePty) Resize(width, height int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.width, p.height = width, height
+	return nil
+}
+
+func (p *fakePty) Size() (int, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.width, p.height, nil
+}
+
+// input returns the input the pane sent to the program.
+func (p *fakePty) input() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.in.String()
+}
+
+// eventually fails the test if cond doesn't become true in time.
+func eventually(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSplitLayout(t *testing.T) {
+	m := New(21, 10)
+	defer m.Close() //nolint:errcheck
+
+	ptys := []*fakePty{newFakePty(), newFakePty(), newFakePty()}
+	a, _ := m.Split(Horizontal, ptys[0])
+	b, _ := m.Split(Horizontal, ptys[1])
+	c, _ := m.Split(Vertical, ptys[2])
+
+	want := map[*Pane]uv.Rectangle{
+		a: uv.Rect(0, 0, 10, 10),
+		b: uv.Rect(11, 0, 10, 5),
+		c: uv.Rect(11, 6, 10, 4),
+	}
+	for p, area := range want {
+		if p.Bounds() != area {
+			t.Errorf("pane %d bounds = %v, want %v", p.ID(), p.Bounds(), area)
+		}
+	}
+	if w, h, _ := ptys[2].Size(); w != 10 || h != 4 {
+		t.Errorf("pty size = %dx%d, want 10x4", w, h)
+	}
+	if e := c.Emulator(); e.Width() != 10 || e.Height() != 4 {
+		t.Errorf("emulator size = %dx%d, want 10x4", e.Width(), e.Height())
+	}
+	if got := m.Panes(); len(got) != 3 || got[0] != a || got[1] != b || got[2] != c {
+		t.Errorf("panes are not in layout order")
+	}
+	if m.Focused() != c {
+		t.Errorf("focused pane = %d, want %d", m.Focused().ID(), c.ID())
+	}
+	if m.PaneAt(10, 3) != nil || m.PaneAt(12, 7) != c {
+		t.Errorf("unexpected panes at separator or inside pane")
+	}
+
+	if err := m.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bounds() != uv.Rect(11, 0, 10, 10) {
+		t.Errorf("bounds after remove = %v, want %v", c.Bounds(), uv.Rect(11, 0, 10, 10))
+	}
+}
+
+func TestResize(t *testing.T) {
+	m := New(21, 10)
+	defer m.Close() //nolint:errcheck
+
+	a, _ := m.Split(Horizontal, newFakePty())
+	bp := newFakePty()
+	b, _ := m.Split(Horizontal, bp)
+
+	if err := m.ResizePane(b, Horizontal, 4); err != nil {
+		t.Fatal(err)
+	}
+	if a.Bounds().Dx() != 6 || b.Bounds().Dx() != 14 {
+		t.Errorf("widths = %d, %d, want 6, 14", a.Bounds().Dx(), b.Bounds().Dx())
+	}
+	if err := m.ResizePane(b, Vertical, 4); err != nil {
+		t.Fatal(err)
+	}
+	if b.Bounds().Dy() != 10 {
+		t.Errorf("resizing without a vertical split changed the height")
+	}
+
+	if err := m.Resize(41, 20); err != nil {
+		t.Fatal(err)
+	}
+	if w, h, _ := bp.Size(); w != 28 || h != 20 {
+		t.Errorf("pty size = %dx%d, want 28x20", w, h)
+	}
+}
+
+func TestBoundsConcurrent(t *testing.T) {
+	m := New(21, 10)
+	defer m.Close() //nolint:errcheck
+
+	a, _ := m.Split(Horizontal, newFakePty())
+	_, _ = m.Split(Horizontal, newFakePty())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			_ = m.Resize(21+i%10, 10)
+		}
+		_ = m.Resize(21, 10)
+	}()
+	for {
+		select {
+		case <-done:
+			if got := a.Bounds(); got != uv.Rect(0, 0, 10, 10) {
+				t.Errorf("bounds = %v, want %v", got, uv.Rect(0, 0, 10, 10))
+			}
+			return
+		default:
+			_ = a.Bounds()
+		}
+	}
+}
+
+func TestZoom(t *testing.T) {
+	m := New(21, 10)
+	defer m.Close() //nolint:errcheck
+
+	a, _ := m.Split(Horizontal, newFakePty())
+	b, _ := m.Split(Horizontal, newFakePty())
+	if err := m.Zoom(a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Bounds() != m.Bounds() || m.Focused() != a {
+		t.Errorf("zoomed pane bounds = %v, want %v", a.Bounds(), m.Bounds())
+	}
+	if m.PaneAt(15, 0) != a {
+		t.Errorf("hidden pane is visible while zoomed")
+	}
+
+	m.Focus(b)
+	if m.Zoomed() != nil || a.Bounds() != uv.Rect(0, 0, 10, 10) {
+		t.Errorf("focusing another pane didn't unzoom")
+	}
+}
+
+func TestInput(t *testing.T) {
+	m := New(21, 10)
+	defer m.Close() //nolint:errcheck
+
+	ap, bp := newFakePty(), newFakePty()
+	a, _ := m.Split(Horizontal, ap)
+	_, _ = m.Split(Horizontal, bp)
+
+	m.SendText("b")
+	eventually(t, func() bool { return bp.input() == "b" }, "text was not sent to the focused pane")
+
+	// Enable SGR mouse tracking in the first pane.
+	_, _ = io.WriteString(ap.pw, "\x1b[?1000h\x1b[?1006h")
+	eventually(t, func() bool {
+		m.SendMouse(vt.MouseClick{X: 2, Y: 3, Button: vt.MouseLeft})
+		m.SendMouse(vt.MouseRelease{X: 15, Y: 3, Button: vt.MouseLeft})
+		return ap.input() != ""
+	}, "mouse was not sent to the pane under the mouse")
+	if got, want := ap.input(), "\x1b[<0;3;4M\x1b[<0;10;4m"; got[:len(want)] != want {
+		t.Errorf("mouse input = %q, want %q", got, want)
+	}
+	if m.Focused() != a {
+		t.Errorf("clicking a pane didn't focus it")
+	}
+}
+
+func TestDraw(t *testing.T) {
+	m := New(5, 3)
+	defer m.Close() //nolint:errcheck
+
+	ap, bp := newFakePty(), newFakePty()
+	a, _ := m.Split(Horizontal, ap)
+	b, _ := m.Split(Horizontal, bp)
+	_, _ = io.WriteString(ap.pw, "ab")
+	_, _ = io.WriteString(bp.pw, "\x1b[2;1Hc")
+	eventually(t, func() bool {
+		return strings.Contains(a.Emulator().Render(), "ab") && strings.Contains(b.Emulator().Render(), "c")
+	}, "output was not written to the panes")
+
+	scr := uv.NewScreenBuffer(7, 4)
+	m.Draw(scr, uv.Rect(1, 1, 5, 3))
+	if got, want := scr.Render(), "\n ab│\n   │c\n   │"; got != want {
+		t.Errorf("drawn screen = %q, want %q", got, want)
+	}
+	if pos, _ := m.CursorPosition(); pos != uv.Pos(4, 1) {
+		t.Errorf("cursor position = %v, want %v", pos, uv.Pos(4, 1))
+	}
+}
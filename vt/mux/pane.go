@@ -0,0 +1,99 @@
+package mux
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/vt"
+	"github.com/charmbracelet/x/xpty"
+)
+
+// Pane is a pane of a [Mux]. It's backed by an emulator that displays the
+// output of a PTY and sends the pane input to it.
+type Pane struct {
+	id  int
+	emu *vt.SafeEmulator
+	pty xpty.Pty
+
+	// mu is the lock of the multiplexer, it guards the area.
+	mu *sync.Mutex
+	// area is the area of the pane relative to the multiplexer.
+	area uv.Rectangle
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newPane creates a pane of the given size and starts copying data between
+// the PTY and the emulator. The mutex is the lock of the multiplexer.
+func newPane(id int, mu *sync.Mutex, pty xpty.Pty, width, height int) *Pane {
+	p := &Pane{
+		id:   id,
+		emu:  vt.NewSafeEmulator(max(width, 1), max(height, 1)),
+		pty:  pty,
+		mu:   mu,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		_, _ = io.Copy(p.emu, pty)
+	}()
+	go func() {
+		// Input and replies to the program.
+		_, _ = io.Copy(pty, p.emu)
+	}()
+	return p
+}
+
+// ID returns the identifier of the pane. Identifiers are unique within a
+// multiplexer.
+func (p *Pane) ID() int {
+	return p.id
+}
+
+// Emulator returns the emulator of the pane.
+func (p *Pane) Emulator() *vt.SafeEmulator {
+	return p.emu
+}
+
+// Pty returns the PTY of the pane.
+func (p *Pane) Pty() xpty.Pty {
+	return p.pty
+}
+
+// Bounds returns the area of the pane relative to the multiplexer. It's
+// empty when the pane doesn't fit in the multiplexer.
+func (p *Pane) Bounds() uv.Rectangle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.area
+}
+
+// Done returns a channel that's closed when the PTY output ends, usually
+// because the program running in the pane exited.
+func (p *Pane) Done() <-chan struct{} {
+	return p.done
+}
+
+// setArea sets the area of the pane and resizes the emulator and the PTY
+// when the size changes. Empty areas keep the previous size.
+func (p *Pane) setArea(area uv.Rectangle) error {
+	old := p.area
+	p.area = area
+	if area.Empty() || (area.Dx() == old.Dx() && area.Dy() == old.Dy()) {
+		return nil
+	}
+	p.emu.Resize(area.Dx(), area.Dy())
+	return p.pty.Resize(area.Dx(), area.Dy()) //nolint:wrapcheck
+}
+
+// close closes the emulator and the PTY of the pane.
+func (p *Pane) close() error {
+	p.closeOnce.Do(func() {
+		p.closeErr = errors.Join(p.emu.Close(), p.pty.Close())
+	})
+	return p.closeErr
+}
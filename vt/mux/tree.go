@@ -0,0 +1,154 @@
+package mux
+
+import (
+	"math"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// Direction is the direction of a split.
+type Direction uint8
+
+// Split directions.
+const (
+	// Horizontal places panes side by side, from left to right.
+	Horizontal Direction = iota
+	// Vertical stacks panes from top to bottom.
+	Vertical
+)
+
+// node is a node of the split tree. Leaves hold a pane while the other
+// nodes split their area between two children.
+type node struct {
+	parent *node
+	pane   *Pane
+
+	dir      Direction
+	children [2]*node
+	// ratio is the share of the first child of the split area, excluding
+	// the separator.
+	ratio float64
+
+	// area is the area of the node relative to the multiplexer.
+	area uv.Rectangle
+}
+
+// split replaces the leaf with a split node holding the leaf pane and the
+// given pane, in that order, and returns the leaf of the new pane.
+func (n *node) split(dir Direction, p *Pane) *node {
+	first := &node{parent: n, pane: n.pane}
+	second := &node{parent: n, pane: p}
+	n.pane = nil
+	n.dir, n.ratio = dir, 0.5 //nolint:mnd
+	n.children = [2]*node{first, second}
+	return second
+}
+
+// index returns the index of the child in its parent.
+func (n *node) index() int {
+	if n.parent.children[0] == n {
+		return 0
+	}
+	return 1
+}
+
+// find returns the leaf holding the given pane, if any.
+func (n *node) find(p *Pane) *node {
+	if n == nil {
+		return nil
+	}
+	if n.pane != nil {
+		if n.pane == p {
+			return n
+		}
+		return nil
+	}
+	if l := n.children[0].find(p); l != nil {
+		return l
+	}
+	return n.children[1].find(p)
+}
+
+// panes appends the panes of the node in layout order.
+func (n *node) panes(panes []*Pane) []*Pane {
+	if n == nil {
+		return panes
+	}
+	if n.pane != nil {
+		return append(panes, n.pane)
+	}
+	return n.children[1].panes(n.children[0].panes(panes))
+}
+
+// first returns the first pane of the node in layout order.
+func (n *node) first() *Pane {
+	for n.pane == nil {
+		n = n.children[0]
+	}
+	return n.pane
+}
+
+// size returns the size of the node area along its split direction.
+func (n *node) size() int {
+	if n.dir == Horizontal {
+		return n.area.Dx()
+	}
+	return n.area.Dy()
+}
+
+// layout sets the area of the node and its children. The children of a split
+// are separated by a one cell wide line, see [node.separator].
+func (n *node) layout(area uv.Rectangle, fn func(p *Pane, area uv.Rectangle)) {
+	n.area = area
+	if n.pane != nil {
+		fn(n.pane, area)
+		return
+	}
+	first := splitSize(n.size(), n.ratio)
+	a, b := area, area
+	if n.dir == Horizontal {
+		a.Max.X = area.Min.X + first
+		b.Min.X = min(a.Max.X+1, area.Max.X)
+	} else {
+		a.Max.Y = area.Min.Y + first
+		b.Min.Y = min(a.Max.Y+1, area.Max.Y)
+	}
+	n.children[0].layout(a, fn)
+	n.children[1].layout(b, fn)
+}
+
+// separator returns the area of the line between the children of a split.
+func (n *node) separator() uv.Rectangle {
+	first := splitSize(n.size(), n.ratio)
+	sep := n.area
+	if n.dir == Horizontal {
+		sep.Min.X += first
+		sep.Max.X = min(sep.Min.X+1, n.area.Max.X)
+	} else {
+		sep.Min.Y += first
+		sep.Max.Y = min(sep.Min.Y+1, n.area.Max.Y)
+	}
+	return sep
+}
+
+// walk calls fn for each split node.
+func (n *node) walk(fn func(n *node)) {
+	if n == nil || n.pane != nil {
+		return
+	}
+	fn(n)
+	n.children[0].walk(fn)
+	n.children[1].walk(fn)
+}
+
+// splitSize returns the size of the first child of a split of the given size
+// and ratio. Both children get at least one cell when there's room for them
+// and the separator.
+func splitSize(size int, ratio float64) int {
+	avail := size - 1
+	if avail < 2 { //nolint:mnd
+		return max(size, 0)
+	}
+	first := int(math.Round(float64(avail) * ratio))
+	return min(max(first, 1), avail-1)
+}
@@ -0,0 +1,306 @@
+package vt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// NotificationUrgency is the urgency of a desktop notification.
+type NotificationUrgency uint8
+
+// Notification urgencies.
+const (
+	UrgencyLow NotificationUrgency = iota
+	UrgencyNormal
+	UrgencyCritical
+)
+
+// Notification is a desktop notification sent by a program using OSC 9,
+// OSC 777, or OSC 99.
+type Notification struct {
+	// ID identifies the notification. It's only set by OSC 99 and can be
+	// used to close the notification.
+	ID string
+	// Title is the title of the notification, if any.
+	Title string
+	// Body is the body of the notification.
+	Body string
+	// Urgency is the urgency of the notification.
+	Urgency NotificationUrgency
+	// Metadata are the other OSC 99 metadata keys and values, such as the
+	// application name "f" or the actions "a". Values are not decoded.
+	Metadata map[string]string
+}
+
+// ProgressState is the state of a progress bar.
+type ProgressState uint8
+
+// Progress bar states.
+const (
+	// ProgressNone hides the progress bar.
+	ProgressNone ProgressState = iota
+	// ProgressNormal shows the progress bar in the default state.
+	ProgressNormal
+	// ProgressError shows the progress bar in the error state.
+	ProgressError
+	// ProgressIndeterminate shows an indeterminate progress bar.
+	ProgressIndeterminate
+	// ProgressWarning shows the progress bar in the warning state.
+	ProgressWarning
+)
+
+// Progress is the state of the progress bar set by a program using OSC 9;4.
+type Progress struct {
+	State ProgressState
+	// Value is the progress percentage from 0 to 100.
+	Value int
+}
+
+// Attention is a request for the user's attention set by a program using
+// iTerm2's OSC 1337 RequestAttention.
+type Attention string
+
+// Attention requests.
+const (
+	// AttentionYes requests attention until the terminal is focused.
+	AttentionYes Attention = "yes"
+	// AttentionOnce requests attention once.
+	AttentionOnce Attention = "once"
+	// AttentionNo cancels a previous request.
+	AttentionNo Attention = "no"
+	// AttentionFireworks requests attention with a fireworks animation at
+	// the cursor position.
+	AttentionFireworks Attention = "fireworks"
+)
+
+// Limits of the OSC 99 notifications being received in chunks.
+const (
+	// maxPendingNotifications is the maximum number of notifications being
+	// received at the same time.
+	maxPendingNotifications = 32
+	// maxNotificationSize is the maximum size in bytes of the title, body,
+	// and metadata of a notification.
+	maxNotificationSize = 64 * 1024
+)
+
+// handleNotify handles iTerm2 and ConEmu OSC 9 sequences.
+//
+//	OSC 9 ; Mc ST
+//	OSC 9 ; 4 ; St ; Pr ST
+//	OSC 9 ; 9 ; Pt ST
+//
+// Mc is the body of a notification. The numbered forms set the progress bar
+// state and percentage, and the working directory. Other ConEmu numbered
+// forms with parameters are ignored, while a number alone is the body of a
+// notification.
+func (e *Emulator) handleNotify(cmd int, data []byte) {
+	parts := bytes.SplitN(data, []byte{';'}, 3)
+	if len(parts) < 2 || cmd != 9 {
+		// Invalid, ignore
+		return
+	}
+
+	switch string(parts[1]) {
+	case "4": // Progress bar
+		e.handleProgress(parts[2:])
+		return
+	case "9": // Working directory
+		if len(parts) == 3 {
+			e.cwd = string(parts[2])
+			if e.cb.WorkingDirectory != nil {
+				e.cb.WorkingDirectory(e.cwd)
+			}
+			emit(&e.events, CwdChanged{Dir: e.cwd})
+			return
+		}
+	}
+	if _, err := strconv.Atoi(string(parts[1])); err == nil && len(parts) == 3 {
+		e.logf("unhandled OSC 9 sequence: %q", data)
+		return
+	}
+
+	body := string(bytes.TrimPrefix(data, []byte("9;")))
+	e.notify(Notification{Body: body, Urgency: UrgencyNormal})
+}
+
+// handleProgress handles the parameters of an OSC 9;4 progress bar sequence.
+func (e *Emulator) handleProgress(params [][]byte) {
+	var p Progress
+	if len(params) > 0 {
+		params = bytes.Split(params[0], []byte{';'})
+		state, _ := strconv.Atoi(string(params[0]))
+		if state < int(ProgressNone) || state > int(ProgressWarning) {
+			// Invalid, ignore
+			return
+		}
+		p.State = ProgressState(state)
+		if len(params) > 1 {
+			p.Value, _ = strconv.Atoi(string(params[1]))
+			p.Value = min(max(p.Value, 0), 100) //nolint:mnd
+		}
+	}
+	if e.cb.Progress != nil {
+		e.cb.Progress(p)
+	}
+	emit(&e.events, p)
+}
+
+// handleNotifyRxvt handles rxvt OSC 777 notification sequences.
+//
+//	OSC 777 ; notify ; Pt ; Pb ST
+//
+// Where Pt is the title and Pb is the body of the notification.
+func (e *Emulator) handleNotifyRxvt(cmd int, data []byte) {
+	parts := bytes.SplitN(data, []byte{';'}, 4)
+	if len(parts) < 3 || cmd != 777 || string(parts[1]) != "notify" {
+		// Invalid, ignore
+		return
+	}
+	n := Notification{Title: string(parts[2]), Urgency: UrgencyNormal}
+	if len(parts) == 4 {
+		n.Body = string(parts[3])
+	}
+	e.notify(n)
+}
+
+// handleDesktopNotification handles kitty OSC 99 desktop notification
+// sequences.
+//
+//	OSC 99 ; metadata ; payload ST
+//
+// The metadata is a colon-separated list of key=value pairs. Notifications
+// can be sent in chunks that share the same "i" identifier, "d=0" marks
+// chunks that are followed by more. The "p" key selects what the payload
+// is: "title" (default), "body", "close" to close a notification, or "?" to
+// query the supported features.
+//
+// See: https://sw.kovidgoyal.net/kitty/desktop-notifications/
+func (e *Emulator) handleDesktopNotification(cmd int, data []byte) {
+	parts := bytes.SplitN(data, []byte{';'}, 3)
+	if len(parts) != 3 || cmd != 99 {
+		// Invalid, ignore
+		return
+	}
+
+	meta := map[string]string{}
+	for _, kv := range strings.Split(string(parts[1]), ":") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			meta[k] = v
+		}
+	}
+	id, done, kind := meta["i"], meta["d"] != "0", meta["p"]
+	payload := string(parts[2])
+	if meta["e"] == "1" {
+		b, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			e.logf("invalid OSC 99 payload: %v", err)
+			return
+		}
+		payload = string(b)
+	}
+	for _, k := range []string{"i", "d", "p", "e"} {
+		delete(meta, k)
+	}
+
+	switch kind {
+	case "", "title", "body":
+	case "close":
+		delete(e.notifications, id)
+		if e.cb.CloseNotification != nil {
+			e.cb.CloseNotification(id)
+		}
+		emit(&e.events, NotificationClosed{ID: id})
+		return
+	case "?":
+		_, _ = io.WriteString(e.pw, "\x1b]99;i="+id+":p=?;p=title,body,close,?:u=0,1,2\x1b\\")
+		return
+	default:
+		e.logf("unhandled OSC 99 payload type: %q", kind)
+		return
+	}
+
+	n, ok := e.notifications[id]
+	if !ok {
+		if len(e.notifications) >= maxPendingNotifications {
+			clear(e.notifications)
+		}
+		if e.notifications == nil {
+			e.notifications = make(map[string]*Notification)
+		}
+		n = &Notification{ID: id, Urgency: UrgencyNormal}
+		e.notifications[id] = n
+	}
+	if u, err := strconv.Atoi(meta["u"]); err == nil && u >= int(UrgencyLow) && u <= int(UrgencyCritical) {
+		n.Urgency = NotificationUrgency(u)
+	}
+	delete(meta, "u")
+	if len(meta) > 0 {
+		if n.Metadata == nil {
+			n.Metadata = make(map[string]string, len(meta))
+		}
+		maps.Copy(n.Metadata, meta)
+	}
+	if kind == "body" {
+		n.Body += payload
+	} else {
+		n.Title += payload
+	}
+	if notificationSize(n) > maxNotificationSize {
+		e.logf("OSC 99 notification too large: %q", id)
+		delete(e.notifications, id)
+		return
+	}
+
+	if done {
+		delete(e.notifications, id)
+		e.notify(*n)
+	}
+}
+
+// handleRequestAttention handles iTerm2 OSC 1337 RequestAttention
+// sequences. Other OSC 1337 sequences are not supported.
+//
+//	OSC 1337 ; RequestAttention=Pv ST
+//
+// Where Pv is one of yes, once, no, or fireworks.
+func (e *Emulator) handleRequestAttention(cmd int, data []byte) bool {
+	parts := bytes.SplitN(data, []byte{';'}, 2)
+	if len(parts) != 2 || cmd != 1337 {
+		return false
+	}
+	k, v, _ := strings.Cut(string(parts[1]), "=")
+	if k != "RequestAttention" {
+		return false
+	}
+	switch a := Attention(v); a {
+	case AttentionYes, AttentionOnce, AttentionNo, AttentionFireworks:
+		if e.cb.Attention != nil {
+			e.cb.Attention(a)
+		}
+		emit(&e.events, a)
+		return true
+	}
+	return false
+}
+
+// notificationSize returns the size in bytes of the title, body, and
+// metadata of a notification.
+func notificationSize(n *Notification) int {
+	size := len(n.Title) + len(n.Body)
+	for k, v := range n.Metadata {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// notify reports a notification to the callback.
+func (e *Emulator) notify(n Notification) {
+	if e.cb.Notification != nil {
+		e.cb.Notification(n)
+	}
+	emit(&e.events, n)
+}
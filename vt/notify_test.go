@@ -0,0 +1,140 @@
+package vt
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestNotification(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []Notification
+	}{
+		{
+			name:  "osc 9",
+			input: ansi.Notify("hello; world"),
+			want:  []Notification{{Body: "hello; world", Urgency: UrgencyNormal}},
+		},
+		{
+			name:  "osc 9 number",
+			input: "\x1b]9;42\x07\x1b]9;9\x07",
+			want: []Notification{
+				{Body: "42", Urgency: UrgencyNormal},
+				{Body: "9", Urgency: UrgencyNormal},
+			},
+		},
+		{
+			name:  "osc 9 conemu",
+			input: "\x1b]9;1;100\x07\x1b]9;3;title\x07\x1b]9;6;macro\x07",
+		},
+		{
+			name:  "osc 777",
+			input: "\x1b]777;notify;title;body; more\x07",
+			want:  []Notification{{Title: "title", Body: "body; more", Urgency: UrgencyNormal}},
+		},
+		{
+			name:  "osc 99",
+			input: ansi.DesktopNotification("hello"),
+			want:  []Notification{{Title: "hello", Urgency: UrgencyNormal}},
+		},
+		{
+			name: "osc 99 chunks",
+			input: ansi.DesktopNotification("hel", "i=1", "d=0", "u=2", "f=YXBw") +
+				ansi.DesktopNotification("lo", "i=1", "d=0") +
+				ansi.DesktopNotification("Ym9keQ==", "i=1", "p=body", "e=1"),
+			want: []Notification{{
+				ID:       "1",
+				Title:    "hello",
+				Body:     "body",
+				Urgency:  UrgencyCritical,
+				Metadata: map[string]string{"f": "YXBw"},
+			}},
+		},
+		{
+			name: "osc 99 too large",
+			input: ansi.DesktopNotification(strings.Repeat("x", maxNotificationSize), "i=1", "d=0") +
+				ansi.DesktopNotification("x", "i=1", "d=0") +
+				ansi.DesktopNotification("end", "i=1"),
+			want: []Notification{{ID: "1", Title: "end", Urgency: UrgencyNormal}},
+		},
+		{
+			name:  "osc 9 working directory",
+			input: "\x1b]9;9;/tmp\x07",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []Notification
+			e := NewEmulator(10, 2)
+			e.SetCallbacks(Callbacks{Notification: func(n Notification) {
+				got = append(got, n)
+			}})
+			_, _ = e.WriteString(tc.input)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("notifications = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPendingNotifications(t *testing.T) {
+	e := NewEmulator(10, 2)
+	for i := range 2 * maxPendingNotifications {
+		_, _ = e.WriteString(ansi.DesktopNotification("chunk", "i="+strconv.Itoa(i), "d=0"))
+		if len(e.notifications) > maxPendingNotifications {
+			t.Fatalf("pending notifications = %d, want at most %d", len(e.notifications), maxPendingNotifications)
+		}
+	}
+}
+
+func TestCloseNotification(t *testing.T) {
+	var closed string
+	e := NewEmulator(10, 2)
+	e.SetCallbacks(Callbacks{CloseNotification: func(id string) { closed = id }})
+	_, _ = e.WriteString(ansi.DesktopNotification("", "i=x", "p=close"))
+	if closed != "x" {
+		t.Errorf("closed = %q, want %q", closed, "x")
+	}
+
+	got := readInput(t, e, func() {
+		_, _ = e.WriteString(ansi.DesktopNotification("", "i=q", "p=?"))
+	})
+	if want := "\x1b]99;i=q:p=?;p=title,body,close,?:u=0,1,2\x1b\\"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var got []Progress
+	e := NewEmulator(10, 2)
+	e.SetCallbacks(Callbacks{Progress: func(p Progress) { got = append(got, p) }})
+	_, _ = e.WriteString(ansi.SetProgressBar(42) + ansi.SetErrorProgressBar(150) +
+		ansi.SetIndeterminateProgressBar + ansi.SetWarningProgressBar(5) +
+		ansi.ResetProgressBar + "\x1b]9;4;9\x07")
+	want := []Progress{
+		{State: ProgressNormal, Value: 42},
+		{State: ProgressError, Value: 100},
+		{State: ProgressIndeterminate},
+		{State: ProgressWarning, Value: 5},
+		{State: ProgressNone},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("progress = %+v, want %+v", got, want)
+	}
+}
+
+func TestAttention(t *testing.T) {
+	var got []Attention
+	e := NewEmulator(10, 2)
+	e.SetCallbacks(Callbacks{Attention: func(a Attention) { got = append(got, a) }})
+	_, _ = e.WriteString("\x1b]1337;RequestAttention=once\x07\x1b]1337;RequestAttention=maybe\x07")
+	if want := []Attention{AttentionOnce}; !reflect.DeepEqual(got, want) {
+		t.Errorf("attention = %v, want %v", got, want)
+	}
+}
@@ -31,21 +31,25 @@ func (e *Emulator) handleTitle(cmd int, data []byte) {
 		if e.cb.Title != nil {
 			e.cb.Title(name)
 		}
+		emit(&e.events, TitleChanged{Title: name})
 		if e.cb.IconName != nil {
 			e.cb.IconName(name)
 		}
+		emit(&e.events, IconNameChanged{IconName: name})
 	case 1: // Set icon name
 		name := string(parts[1])
 		e.iconName = name
 		if e.cb.IconName != nil {
 			e.cb.IconName(name)
 		}
+		emit(&e.events, IconNameChanged{IconName: name})
 	case 2: // Set window title
 		name := string(parts[1])
 		e.title = name
 		if e.cb.Title != nil {
 			e.cb.Title(name)
 		}
+		emit(&e.events, TitleChanged{Title: name})
 	}
 }
 
@@ -122,15 +126,17 @@ func (e *Emulator) handleWorkingDirectory(cmd int, data []byte) {
 	if e.cb.WorkingDirectory != nil {
 		e.cb.WorkingDirectory(path)
 	}
+	emit(&e.events, CwdChanged{Dir: path})
 }
 
 func (e *Emulator) handleHyperlink(cmd int, data []byte) {
-	parts := bytes.Split(data, []byte{';'})
+	// The URI can contain semicolons.
+	parts := bytes.SplitN(data, []byte{';'}, 3)
 	if len(parts) != 3 || cmd != 8 {
 		// Invalid, ignore
 		return
 	}
 
-	e.scr.cur.Link.URL = string(parts[1])
-	e.scr.cur.Link.Params = string(parts[2])
+	e.scr.cur.Link.Params = string(parts[1])
+	e.scr.cur.Link.URL = string(parts[2])
 }
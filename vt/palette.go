@@ -0,0 +1,159 @@
+package vt
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Special colors are used by some terminals to render text with the given
+// attributes instead of, or in addition to, the attribute itself. They can be
+// set using OSC 5, or OSC 4 with indices 256 through 260.
+const (
+	SpecialColorBold = iota
+	SpecialColorUnderline
+	SpecialColorBlink
+	SpecialColorReverse
+	SpecialColorItalic
+)
+
+// specialColorOffset is the offset of the special colors in OSC 4 indices.
+const specialColorOffset = 256
+
+// SpecialColor returns a terminal's special color. This returns nil if the
+// special color is not set.
+func (e *Emulator) SpecialColor(i int) color.Color {
+	if i < 0 || i >= len(e.specialColors) {
+		return nil
+	}
+	return e.specialColors[i]
+}
+
+// SetSpecialColor sets a terminal's special color. The index must be one of
+// the SpecialColor constants. A nil color resets the special color.
+func (e *Emulator) SetSpecialColor(i int, c color.Color) {
+	if i < 0 || i >= len(e.specialColors) {
+		return
+	}
+	e.specialColors[i] = c
+	if e.cb.PaletteColor != nil {
+		e.cb.PaletteColor(specialColorOffset+i, c)
+	}
+	emit(&e.events, PaletteColorChanged{Index: specialColorOffset + i, Color: c})
+}
+
+// paletteColor returns the palette color at the given OSC 4 index.
+func (e *Emulator) paletteColor(i int) color.Color {
+	if i >= specialColorOffset {
+		if c := e.SpecialColor(i - specialColorOffset); c != nil {
+			return c
+		}
+		// Unset special colors default to the foreground color like xterm.
+		return e.ForegroundColor()
+	}
+	return e.IndexedColor(i)
+}
+
+// setPaletteColor sets the palette color at the given OSC 4 index.
+func (e *Emulator) setPaletteColor(i int, c color.Color) {
+	if i >= specialColorOffset {
+		e.SetSpecialColor(i-specialColorOffset, c)
+		return
+	}
+	e.SetIndexedColor(i, c)
+}
+
+// handlePalette handles OSC 4 and OSC 5 palette sequences.
+//
+//	OSC 4 ; c ; spec [; c ; spec ...] ST
+//	OSC 5 ; c ; spec [; c ; spec ...] ST
+//
+// Where c is the color index and spec is either a color specification to set
+// the color or "?" to query it. OSC 5 operates on the special colors.
+func (e *Emulator) handlePalette(cmd int, data []byte) {
+	parts := bytes.Split(data, []byte{';'})
+	if len(parts) < 3 || len(parts)%2 != 1 || (cmd != 4 && cmd != 5) {
+		// Invalid, ignore
+		return
+	}
+
+	offset, limit := 0, len(e.colors)+len(e.specialColors)
+	if cmd == 5 {
+		offset, limit = specialColorOffset, len(e.specialColors)
+	}
+
+	for j := 1; j < len(parts); j += 2 {
+		i, err := strconv.Atoi(string(parts[j]))
+		if err != nil || i < 0 || i >= limit {
+			e.logf("invalid palette index: %q", parts[j])
+			continue
+		}
+
+		spec := string(parts[j+1])
+		if spec == "?" {
+			xrgb := ansi.XRGBColor{Color: e.paletteColor(offset + i)}
+			_, _ = io.WriteString(e.pw, fmt.Sprintf("\x1b]%d;%d;%s\x07", cmd, i, xrgb))
+			continue
+		}
+
+		if c := ansi.XParseColor(spec); c != nil {
+			e.setPaletteColor(offset+i, c)
+		}
+	}
+}
+
+// handleResetPalette handles OSC 104 and OSC 105 palette reset sequences.
+//
+//	OSC 104 [; c ...] ST
+//	OSC 105 [; c ...] ST
+//
+// Where c is the color index to reset. When no index is given, all the colors
+// are reset. OSC 105 operates on the special colors.
+func (e *Emulator) handleResetPalette(cmd int, data []byte) {
+	if cmd != 104 && cmd != 105 {
+		// Invalid, ignore
+		return
+	}
+
+	offset, limit := 0, len(e.colors)+len(e.specialColors)
+	if cmd == 105 {
+		offset, limit = specialColorOffset, len(e.specialColors)
+	}
+
+	parts := bytes.Split(data, []byte{';'})
+	if len(parts) < 2 || (len(parts) == 2 && len(parts[1]) == 0) {
+		// Reset all colors. OSC 104 only resets the special colors when
+		// they're explicitly given.
+		if cmd == 104 {
+			limit = len(e.colors)
+		}
+		for i := range limit {
+			if e.paletteColorSet(offset + i) {
+				e.setPaletteColor(offset+i, nil)
+			}
+		}
+		return
+	}
+
+	for _, p := range parts[1:] {
+		i, err := strconv.Atoi(string(p))
+		if err != nil || i < 0 || i >= limit {
+			e.logf("invalid palette index: %q", p)
+			continue
+		}
+		e.setPaletteColor(offset+i, nil)
+	}
+}
+
+// paletteColorSet reports whether the palette color at the given OSC 4 index
+// is set.
+func (e *Emulator) paletteColorSet(i int) bool {
+	if i >= specialColorOffset {
+		return e.specialColors[i-specialColorOffset] != nil
+	}
+	return e.colors[i] != nil
+}
@@ -0,0 +1,72 @@
+package vt
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestPalette(t *testing.T) {
+	e := NewEmulator(10, 10)
+	changed := map[int]color.Color{}
+	e.SetCallbacks(Callbacks{
+		PaletteColor: func(index int, c color.Color) {
+			changed[index] = c
+		},
+	})
+
+	_, _ = e.WriteString("\x1b]4;1;#ff0000;2;rgb:00/ff/00\x07")
+	red, green := color.RGBA{R: 0xff, A: 0xff}, color.RGBA{G: 0xff, A: 0xff}
+	if !colorEqual(e.IndexedColor(1), red) || !colorEqual(e.IndexedColor(2), green) {
+		t.Errorf("colors = %v %v, want %v %v", e.IndexedColor(1), e.IndexedColor(2), red, green)
+	}
+	if len(changed) != 2 || !colorEqual(changed[1], red) {
+		t.Errorf("callback colors = %v", changed)
+	}
+
+	got := readInput(t, e, func() {
+		_, _ = e.WriteString("\x1b]4;1;?\x07")
+	})
+	if want := "\x1b]4;1;rgb:ffff/0000/0000\x07"; got != want {
+		t.Errorf("query reply = %q, want %q", got, want)
+	}
+
+	// Special colors are set using OSC 5 or OSC 4 with indices above 255.
+	_, _ = e.WriteString("\x1b]5;0;#00ff00\x07\x1b]4;260;#ff0000\x07")
+	if !colorEqual(e.SpecialColor(SpecialColorBold), green) ||
+		!colorEqual(e.SpecialColor(SpecialColorItalic), red) {
+		t.Errorf("special colors = %v %v", e.SpecialColor(SpecialColorBold), e.SpecialColor(SpecialColorItalic))
+	}
+	got = readInput(t, e, func() {
+		_, _ = e.WriteString("\x1b]5;0;?\x07")
+	})
+	if want := "\x1b]5;0;rgb:0000/ffff/0000\x07"; got != want {
+		t.Errorf("special query reply = %q, want %q", got, want)
+	}
+
+	_, _ = e.WriteString("\x1b]104;2\x07")
+	if !colorEqual(e.IndexedColor(2), ansi.IndexedColor(2)) || changed[2] != nil {
+		t.Errorf("expected color 2 to be reset, got %v", e.IndexedColor(2))
+	}
+	_, _ = e.WriteString("\x1b]104\x07")
+	if !colorEqual(e.IndexedColor(1), ansi.IndexedColor(1)) {
+		t.Errorf("expected color 1 to be reset, got %v", e.IndexedColor(1))
+	}
+	if e.SpecialColor(SpecialColorBold) == nil {
+		t.Errorf("expected special colors to be kept")
+	}
+	_, _ = e.WriteString("\x1b]105\x07")
+	if e.SpecialColor(SpecialColorBold) != nil || e.SpecialColor(SpecialColorItalic) != nil {
+		t.Errorf("expected special colors to be reset")
+	}
+}
+
+func colorEqual(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
@@ -0,0 +1,253 @@
+package vt
+
+import (
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// protection marks the cells of a screen protected from selective erase.
+//
+// Cell styles have no room for a protection attribute, so protected cells
+// are tracked separately and moved along with the screen lines. Lines are
+// only allocated once a protected cell is written to them.
+type protection struct {
+	width int
+	// lines are the protected cells of each line, nil for lines without
+	// protected cells.
+	lines [][]bool
+}
+
+// newProtection returns the protection of a screen of the given size.
+func newProtection(width, height int) *protection {
+	return &protection{width: width, lines: make([][]bool, height)}
+}
+
+// isSet reports whether the cell at the given position is protected.
+func (p *protection) isSet(x, y int) bool {
+	return y >= 0 && y < len(p.lines) && x >= 0 && x < len(p.lines[y]) && p.lines[y][x]
+}
+
+// set marks or unmarks the cells in the given area as protected.
+func (p *protection) set(area uv.Rectangle, on bool) {
+	area = area.Intersect(uv.Rect(0, 0, p.width, len(p.lines)))
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		if p.lines[y] == nil {
+			if !on {
+				continue
+			}
+			p.lines[y] = make([]bool, p.width)
+		}
+		for x := area.Min.X; x < area.Max.X; x++ {
+			p.lines[y][x] = on
+		}
+	}
+}
+
+// resize resizes the protection to the given screen size.
+func (p *protection) resize(width, height int) {
+	if height <= len(p.lines) {
+		clear(p.lines[height:])
+		p.lines = p.lines[:height]
+	} else {
+		p.lines = append(p.lines, make([][]bool, height-len(p.lines))...)
+	}
+	if width != p.width {
+		for y, l := range p.lines {
+			if l != nil {
+				p.lines[y] = make([]bool, width)
+				copy(p.lines[y], l)
+			}
+		}
+		p.width = width
+	}
+}
+
+// insertCells shifts the protected cells right after n cells are inserted at
+// the given position within the scroll region.
+func (p *protection) insertCells(x, y, n int, scroll uv.Rectangle) {
+	if n <= 0 || !uv.Pos(x, y).In(scroll) || y >= len(p.lines) || p.lines[y] == nil {
+		return
+	}
+	l := p.lines[y][:scroll.Max.X]
+	n = min(n, len(l)-x)
+	copy(l[x+n:], l[x:])
+	clear(l[x : x+n])
+}
+
+// deleteCells shifts the protected cells left after n cells are deleted at
+// the given position within the scroll region.
+func (p *protection) deleteCells(x, y, n int, scroll uv.Rectangle) {
+	if n <= 0 || !uv.Pos(x, y).In(scroll) || y >= len(p.lines) || p.lines[y] == nil {
+		return
+	}
+	l := p.lines[y][:scroll.Max.X]
+	n = min(n, len(l)-x)
+	copy(l[x:], l[x+n:])
+	clear(l[len(l)-n:])
+}
+
+// insertLines shifts the protected lines down after n lines are inserted at y
+// within the scroll region.
+func (p *protection) insertLines(y, n int, scroll uv.Rectangle) {
+	if n <= 0 || y < scroll.Min.Y || y >= scroll.Max.Y {
+		return
+	}
+	n = min(n, scroll.Max.Y-y)
+	for i := scroll.Max.Y - 1; i >= y+n; i-- {
+		p.copyLine(i, i-n, scroll)
+	}
+	for i := y; i < y+n; i++ {
+		p.copyLine(i, -1, scroll)
+	}
+}
+
+// deleteLines shifts the protected lines up after n lines are deleted at y
+// within the scroll region.
+func (p *protection) deleteLines(y, n int, scroll uv.Rectangle) {
+	if n <= 0 || y < scroll.Min.Y || y >= scroll.Max.Y {
+		return
+	}
+	n = min(n, scroll.Max.Y-y)
+	for i := y; i < scroll.Max.Y-n; i++ {
+		p.copyLine(i, i+n, scroll)
+	}
+	for i := scroll.Max.Y - n; i < scroll.Max.Y; i++ {
+		p.copyLine(i, -1, scroll)
+	}
+}
+
+// copyLine copies the protected cells of line src to line dst within the
+// columns of the scroll region. A negative src clears them. Full lines are
+// moved rather than copied, every moved line is then either overwritten or
+// cleared by the callers.
+func (p *protection) copyLine(dst, src int, scroll uv.Rectangle) {
+	var from []bool
+	if src >= 0 {
+		from = p.lines[src]
+	}
+	if scroll.Min.X == 0 && scroll.Max.X == p.width {
+		p.lines[dst] = from
+		return
+	}
+	if from == nil && p.lines[dst] == nil {
+		return
+	}
+	if p.lines[dst] == nil {
+		p.lines[dst] = make([]bool, p.width)
+	}
+	for x := scroll.Min.X; x < scroll.Max.X; x++ {
+		p.lines[dst][x] = from != nil && from[x]
+	}
+}
+
+// ranges returns the protected cells of line y as pairs of start and end
+// columns.
+func (p *protection) ranges(y int) []int {
+	var r []int
+	for x, on := range p.lines[y] {
+		if on && (x == 0 || !p.lines[y][x-1]) {
+			r = append(r, x)
+		}
+		if on && (x == len(p.lines[y])-1 || !p.lines[y][x+1]) {
+			r = append(r, x+1)
+		}
+	}
+	return r
+}
+
+// protect marks or unmarks the cells in the given area as protected from
+// selective erase. The protection is only allocated once a protected cell is
+// written.
+func (s *Screen) protect(area uv.Rectangle, on bool) {
+	if s.protected == nil {
+		if !on {
+			return
+		}
+		s.protected = newProtection(s.buf.Width(), s.buf.Height())
+	}
+	s.protected.set(area, on)
+}
+
+// isProtected reports whether the cell at the given position is protected
+// from selective erase.
+func (s *Screen) isProtected(x, y int) bool {
+	return s.protected != nil && s.protected.isSet(x, y)
+}
+
+// selectiveEraseArea erases the cells in the given area that are not
+// protected.
+func (s *Screen) selectiveEraseArea(area uv.Rectangle) {
+	if s.protected == nil {
+		s.FillArea(s.blankCell(), area)
+		return
+	}
+	area = area.Intersect(s.Bounds())
+	blank := s.blankCell()
+	if blank == nil {
+		blank = &uv.EmptyCell
+	}
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if !s.isProtected(x, y) {
+				s.buf.SetCell(x, y, blank)
+			}
+		}
+	}
+	s.touchArea(area)
+	s.unwrapArea(area)
+}
+
+// setProtection sets whether the characters written after it are protected
+// from selective erase. This performs DECSCA.
+func (e *Emulator) setProtection(params ansi.Params) bool {
+	n, _, _ := params.Param(0, 0)
+	switch n {
+	case 0, 2:
+		e.scr.cur.protected = false
+	case 1:
+		e.scr.cur.protected = true
+	default:
+		return false
+	}
+	return true
+}
+
+// selectiveEraseDisplay erases the unprotected cells of the screen. This
+// performs DECSED.
+func (e *Emulator) selectiveEraseDisplay(params ansi.Params) bool {
+	n, _, _ := params.Param(0, 0)
+	width, height := e.Width(), e.Height()
+	x, y := e.scr.CursorPosition()
+	switch n {
+	case 0: // Erase screen below (from after cursor position)
+		e.scr.selectiveEraseArea(uv.Rect(x, y, width-x, 1))
+		e.scr.selectiveEraseArea(uv.Rect(0, y+1, width, height-y-1))
+	case 1: // Erase screen above (including cursor)
+		e.scr.selectiveEraseArea(uv.Rect(0, 0, width, y))
+		e.scr.selectiveEraseArea(uv.Rect(0, y, x+1, 1))
+	case 2: // Erase screen
+		e.scr.selectiveEraseArea(e.scr.Bounds())
+	default:
+		return false
+	}
+	return true
+}
+
+// selectiveEraseLine erases the unprotected cells of the cursor line. This
+// performs DECSEL.
+func (e *Emulator) selectiveEraseLine(params ansi.Params) bool {
+	n, _, _ := params.Param(0, 0)
+	x, y := e.scr.CursorPosition()
+	w := e.scr.Width()
+	switch n {
+	case 0: // Erase from cursor to end of line
+		e.scr.selectiveEraseArea(uv.Rect(x, y, w-x, 1))
+	case 1: // Erase from start of line to cursor
+		e.scr.selectiveEraseArea(uv.Rect(0, y, x+1, 1))
+	case 2: // Erase entire line
+		e.scr.selectiveEraseArea(uv.Rect(0, y, w, 1))
+	default:
+		return false
+	}
+	return true
+}
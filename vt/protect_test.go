@@ -0,0 +1,103 @@
+package vt
+
+import "testing"
+
+func TestSelectiveErase(t *testing.T) {
+	// The middle of each line is protected.
+	const screen = "ab\x1b[1\"qcd\x1b[0\"qef\r\ngh\x1b[1\"qij\x1b[2\"qkl\r\nmnopqr"
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "erase in display",
+			input: "\x1b[?2J",
+			want:  "  cd\n  ij\n",
+		},
+		{
+			name:  "erase in display below",
+			input: "\x1b[2;3H\x1b[?0J",
+			want:  "abcdef\nghij\n",
+		},
+		{
+			name:  "erase in display above",
+			input: "\x1b[2;4H\x1b[?1J",
+			want:  "  cd\n  ijkl\nmnopqr",
+		},
+		{
+			name:  "erase in line",
+			input: "\x1b[1;1H\x1b[?2K",
+			want:  "  cd\nghijkl\nmnopqr",
+		},
+		{
+			name:  "erase rectangular area",
+			input: "\x1b[1;1;3;6${",
+			want:  "  cd\n  ij\n",
+		},
+		{
+			name:  "erase ignores protection",
+			input: "\x1b[2J\x1b[?2J",
+			want:  "\n\n",
+		},
+		{
+			name:  "overwrite removes protection",
+			input: "\x1b[1;3HXY\x1b[?2J",
+			want:  "\n  ij\n",
+		},
+		{
+			name:  "protection moves with lines",
+			input: "\x1b[1;1H\x1b[L\x1b[?2J",
+			want:  "\n  cd\n  ij",
+		},
+		{
+			name:  "protection moves with deleted lines",
+			input: "\x1b[1;1H\x1b[M\x1b[?2J",
+			want:  "  ij\n\n",
+		},
+		{
+			name:  "protection moves with lines within margins",
+			input: "\x1b[?69h\x1b[3;6s\x1b[1;3H\x1b[L\x1b[?2J",
+			want:  "\n  cd\n  ij",
+		},
+		{
+			name:  "protection moves with characters",
+			input: "\x1b[1;1H\x1b[P\x1b[?2J",
+			want:  " cd\n  ij\n",
+		},
+		{
+			name:  "protection moves with inserted characters",
+			input: "\x1b[1;1H\x1b[@\x1b[?2J",
+			want:  "   cd\n  ij\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(6, 3)
+			_, _ = e.WriteString(screen + tc.input)
+			if got := e.String(); got != tc.want {
+				t.Errorf("screen = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProtectionState(t *testing.T) {
+	e := NewEmulator(6, 3)
+	_, _ = e.WriteString("ab\x1b[1\"qcd\x1b[0\"qef\r\ngh\x1b[1\"qij")
+	data, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := NewEmulator(2, 2)
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	// The cursor still writes protected characters.
+	_, _ = got.WriteString("kl\x1b[?2J")
+	if want := "  cd\n  ijkl\n"; got.String() != want {
+		t.Errorf("screen = %q, want %q", got.String(), want)
+	}
+}
@@ -0,0 +1,322 @@
+package vt
+
+import (
+	"slices"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// IsWrapped reports whether the line at the given y position is soft-wrapped,
+// meaning the text continues on the next line because it reached the right
+// edge of the screen rather than ending with a newline.
+func (s *Screen) IsWrapped(y int) bool {
+	if y < 0 || y >= len(s.wrapped) {
+		return false
+	}
+	return s.wrapped[y]
+}
+
+// setWrapped sets the soft-wrapped state of the line at the given y position.
+func (s *Screen) setWrapped(y int, wrapped bool) {
+	if y >= 0 && y < len(s.wrapped) {
+		s.wrapped[y] = wrapped
+	}
+}
+
+// resizeWrapped resizes the wrapped state slice to the given height.
+func (s *Screen) resizeWrapped(height int) {
+	if height <= len(s.wrapped) {
+		s.wrapped = s.wrapped[:height]
+		return
+	}
+	s.wrapped = append(s.wrapped, make([]bool, height-len(s.wrapped))...)
+}
+
+// unwrapArea clears the soft-wrapped state of the lines in the given area.
+// A line stops being wrapped once its last column is erased or overwritten.
+func (s *Screen) unwrapArea(area uv.Rectangle) {
+	if area.Max.X < s.buf.Width() {
+		return
+	}
+	for y := max(area.Min.Y, 0); y < min(area.Max.Y, len(s.wrapped)); y++ {
+		s.wrapped[y] = false
+	}
+}
+
+// insertWrapped shifts the soft-wrapped state of the lines down after n lines
+// are inserted at y within the given scroll region.
+func (s *Screen) insertWrapped(y, n int, scroll uv.Rectangle) {
+	bottom := min(scroll.Max.Y, len(s.wrapped))
+	if y < 0 || y >= bottom {
+		return
+	}
+	if scroll.Min.X != 0 || scroll.Max.X != s.buf.Width() {
+		// Partial lines were moved, the wrapped state no longer applies.
+		clear(s.wrapped[y:bottom])
+		return
+	}
+	n = min(n, bottom-y)
+	copy(s.wrapped[y+n:bottom], s.wrapped[y:bottom-n])
+	clear(s.wrapped[y : y+n])
+}
+
+// deleteWrapped shifts the soft-wrapped state of the lines up after n lines
+// are deleted at y within the given scroll region.
+func (s *Screen) deleteWrapped(y, n int, scroll uv.Rectangle) {
+	bottom := min(scroll.Max.Y, len(s.wrapped))
+	if y < 0 || y >= bottom {
+		return
+	}
+	if scroll.Min.X != 0 || scroll.Max.X != s.buf.Width() {
+		// Partial lines were moved, the wrapped state no longer applies.
+		clear(s.wrapped[y:bottom])
+		return
+	}
+	n = min(n, bottom-y)
+	copy(s.wrapped[y:bottom-n], s.wrapped[y+n:bottom])
+	clear(s.wrapped[bottom-n : bottom])
+}
+
+// reflowRow is a line produced by rewrapping logical lines to a new width.
+type reflowRow struct {
+	line    uv.Line
+	wrapped bool
+}
+
+// reflowMark marks the index of the cell of a point in the logical line it's
+// part of.
+type reflowMark struct {
+	point, idx int
+}
+
+// reflow resizes the screen to the given width and height rewrapping
+// soft-wrapped lines, including the ones in the scrollback buffer, to fit the
+// new width. The cursor is kept on the same character it was on before the
+// resize. Pending reports whether the cursor is at the right edge of the
+// screen waiting to wrap, it's used as input to keep the cursor on the last
+// column, and returned with the new pending wrap state.
+func (s *Screen) reflow(width, height int, pending bool) bool {
+	if width <= 0 || height <= 0 || s.buf.Width() == 0 {
+		s.Resize(width, height)
+		return false
+	}
+
+	// Collect the rows to rewrap. Blank rows at the bottom of the screen are
+	// not part of the content and get dropped unless the cursor is on them.
+	sbLen := s.scrollback.Len()
+	last := s.cur.Y
+	for y := s.buf.Height() - 1; y > last; y-- {
+		if line := s.buf.Line(y); line != nil && trimmedLen(line) > 0 {
+			last = y
+			break
+		}
+	}
+
+	rowAt := func(i int) (uv.Line, bool) {
+		if i < sbLen {
+			return s.scrollback.Line(i), s.scrollback.IsWrapped(i)
+		}
+		return s.buf.Line(i - sbLen), s.IsWrapped(i - sbLen)
+	}
+
+	// Positions that move along with the text: the top of the screen, the
+	// images, the semantic prompt marks, the search position, and the
+	// selection. The Y coordinates are relative to the first scrollback row.
+	marked := append(s.marks(), s.selectionMarks()...)
+	points := []uv.Position{uv.Pos(0, sbLen)}
+	for _, p := range s.images {
+		points = append(points, uv.Pos(0, sbLen+p.Bounds.Min.Y))
+	}
+	for _, m := range marked {
+		points = append(points, uv.Pos(m.X, sbLen+m.Y))
+	}
+	pointRows := map[int][]int{}
+	for i, pt := range points {
+		pointRows[pt.Y] = append(pointRows[pt.Y], i)
+	}
+	moved := make([]uv.Position, len(points))
+	found := make([]bool, len(points))
+
+	var (
+		rows      []reflowRow
+		cells     []uv.Cell
+		marks     []reflowMark
+		cursorIdx = -1
+		cursorRow = -1
+		cursorCol int
+	)
+
+	// flush rewraps the current logical line and appends it to rows.
+	flush := func() {
+		rowOf := make([]int, len(cells))
+		colOf := make([]int, len(cells))
+		row := newReflowLine(width)
+		col := 0
+		for i, c := range cells {
+			w := max(c.Width, 1)
+			if w > width {
+				// The cell can't fit the new width, replace it with a blank.
+				c = uv.EmptyCell
+				c.Style = cells[i].Style
+				w = 1
+			}
+			if col+w > width {
+				rows = append(rows, reflowRow{line: row, wrapped: true})
+				row = newReflowLine(width)
+				col = 0
+			}
+			rowOf[i], colOf[i] = len(rows), col
+			row[col] = c
+			for j := 1; j < w; j++ {
+				row[col+j] = uv.Cell{}
+			}
+			col += w
+		}
+
+		// Cells past the end of the line are on its last row.
+		at := func(i int) (int, int) {
+			if i < len(cells) {
+				return rowOf[i], colOf[i]
+			}
+			return len(rows), min(col, width-1)
+		}
+		if cursorIdx >= 0 {
+			cursorRow, cursorCol = at(cursorIdx)
+		}
+		for _, m := range marks {
+			row, col := at(m.idx)
+			moved[m.point], found[m.point] = uv.Pos(col, row), true
+		}
+
+		rows = append(rows, reflowRow{line: row})
+		cells = cells[:0]
+		marks = marks[:0]
+		cursorIdx = -1
+	}
+
+	for i := 0; i <= sbLen+last; i++ {
+		line, wrapped := rowAt(i)
+		if i == sbLen+last {
+			// The last row always ends the logical line.
+			wrapped = false
+		}
+
+		n := len(line)
+		if !wrapped {
+			n = trimmedLen(line)
+		}
+		for _, k := range pointRows[i] {
+			idx := len(cells)
+			for x := 0; x < points[k].X && x < n; x++ {
+				if line[x].Width > 0 {
+					idx++
+				}
+			}
+			marks = append(marks, reflowMark{point: k, idx: idx})
+		}
+		if i == sbLen+s.cur.Y {
+			// Make sure the cursor cell is part of the line.
+			n = min(max(n, s.cur.X+1), len(line))
+			cursorIdx = len(cells)
+			for x := 0; x < s.cur.X && x < len(line); x++ {
+				if line[x].Width > 0 {
+					cursorIdx++
+				}
+			}
+		}
+
+		for x := 0; x < n; x++ {
+			if line[x].Width == 0 && line[x].Content == "" {
+				// Skip wide cell placeholders.
+				continue
+			}
+			cells = append(cells, line[x])
+		}
+
+		if !wrapped {
+			flush()
+		}
+	}
+
+	// Pick the first row of the screen. Keep the old top row on top of the
+	// screen unless the content below it doesn't fit, and keep the cursor
+	// visible.
+	top := max(len(rows)-height, 0)
+	if found[0] {
+		top = max(top, moved[0].Y)
+	}
+	if cursorRow >= 0 {
+		top = min(top, cursorRow)
+	}
+
+	// Move the rows above the screen to the scrollback buffer.
+	if s.scrollback != nil {
+		lines := make([]uv.Line, 0, top)
+		wrapped := make([]bool, 0, top)
+		for _, r := range rows[:top] {
+			line := r.line
+			if !r.wrapped {
+				line = line[:trimmedLen(line)]
+			}
+			lines = append(lines, slices.Clone(line))
+			wrapped = append(wrapped, r.wrapped)
+		}
+		s.scrollback.setLines(lines, wrapped)
+	}
+
+	s.Resize(width, height)
+	// Protected cells and line sizes don't follow the rewrapped text.
+	s.protected = nil
+	s.lineSizes = nil
+	for y := range height {
+		line := s.buf.Line(y)
+		if top+y < len(rows) {
+			copy(line, rows[top+y].line)
+			s.wrapped[y] = rows[top+y].wrapped
+		} else {
+			for x := range line {
+				line[x] = uv.EmptyCell
+			}
+			s.wrapped[y] = false
+		}
+	}
+	s.touchArea(s.Bounds())
+
+	// Move the images and marks along with the text they're anchored to.
+	for i, p := range s.images {
+		if k := 1 + i; found[k] {
+			s.images[i].Bounds = p.Bounds.Add(uv.Pos(0, moved[k].Y-top-p.Bounds.Min.Y))
+		}
+	}
+	for i, m := range marked {
+		if k := 1 + len(s.images) + i; found[k] {
+			*m = uv.Pos(moved[k].X, moved[k].Y-top)
+		}
+	}
+	s.pruneScrollback()
+
+	if cursorRow < 0 {
+		s.setCursor(0, 0, false)
+		return false
+	}
+
+	x, y := cursorCol, cursorRow-top
+	if pending {
+		if x < width-1 {
+			x++
+			pending = false
+		}
+	}
+	s.setCursor(x, y, false)
+
+	return pending
+}
+
+// newReflowLine returns a new blank line of the given width.
+func newReflowLine(width int) uv.Line {
+	line := make(uv.Line, width)
+	for i := range line {
+		line[i] = uv.EmptyCell
+	}
+	return line
+}
@@ -0,0 +1,132 @@
+package vt
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// screenLines returns the scrollback and screen lines as plain text with
+// trailing spaces trimmed.
+func screenLines(e *Emulator) []string {
+	var lines []string
+	text := func(line uv.Line) string {
+		var b strings.Builder
+		for _, c := range line {
+			b.WriteString(c.Content)
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+	for i := range e.ScrollbackLen() {
+		lines = append(lines, text(e.Scrollback().Line(i)))
+	}
+	for y := range e.Height() {
+		line := make(uv.Line, e.Width())
+		for x := range line {
+			if c := e.CellAt(x, y); c != nil {
+				line[x] = *c
+			}
+		}
+		lines = append(lines, text(line))
+	}
+	return lines
+}
+
+func TestResizeReflow(t *testing.T) {
+	cases := []struct {
+		name          string
+		width, height int
+		input         string
+		newW, newH    int
+		want          []string
+		cursor        uv.Position
+	}{
+		{
+			name:  "shrink wraps long line",
+			width: 10, height: 3,
+			input: "abcdefgh\r\nxy",
+			newW:  4, newH: 3,
+			want:   []string{"abcd", "efgh", "xy"},
+			cursor: uv.Pos(2, 2),
+		},
+		{
+			name:  "grow joins soft-wrapped lines",
+			width: 4, height: 4,
+			input: "abcdefgh\r\nxy",
+			newW:  10, newH: 4,
+			want:   []string{"abcdefgh", "xy", "", ""},
+			cursor: uv.Pos(2, 1),
+		},
+		{
+			name:  "hard newlines are kept",
+			width: 4, height: 3,
+			input: "abcd\r\nefgh",
+			newW:  10, newH: 3,
+			want:   []string{"abcd", "efgh", ""},
+			cursor: uv.Pos(4, 1),
+		},
+		{
+			name:  "grow pulls wrapped lines from scrollback",
+			width: 4, height: 2,
+			input: "abcdefghij",
+			newW:  10, newH: 2,
+			want:   []string{"abcdefghij", ""},
+			cursor: uv.Pos(0, 1),
+		},
+		{
+			name:  "cursor stays on the same character",
+			width: 6, height: 3,
+			input: "abcdefghijk\x1b[2D",
+			newW:  3, newH: 4,
+			want:   []string{"abc", "def", "ghi", "jk"},
+			cursor: uv.Pos(0, 3),
+		},
+		{
+			name:  "wide characters are not split",
+			width: 6, height: 2,
+			input: "a世界b",
+			newW:  4, newH: 2,
+			want:   []string{"a世", "界b"},
+			cursor: uv.Pos(3, 1),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(tc.width, tc.height)
+			_, _ = e.WriteString(tc.input)
+			e.Resize(tc.newW, tc.newH)
+
+			got := screenLines(e)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("lines = %q, want %q", got, tc.want)
+			}
+			if pos := e.CursorPosition(); pos != tc.cursor {
+				t.Errorf("cursor = %v, want %v", pos, tc.cursor)
+			}
+		})
+	}
+}
+
+func TestResizeReflowRoundTrip(t *testing.T) {
+	e := NewEmulator(20, 5)
+	_, _ = e.WriteString("the quick brown fox jumps over the lazy dog\r\n$ ")
+	want := screenLines(e)
+	cursor := e.CursorPosition()
+
+	e.Resize(7, 5)
+	e.Resize(13, 8)
+	e.Resize(20, 5)
+
+	if got := screenLines(e); !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	if pos := e.CursorPosition(); pos != cursor {
+		t.Errorf("cursor = %v, want %v", pos, cursor)
+	}
+	if len(e.Touched()) != e.Height() {
+		t.Errorf("touched = %d lines, want %d", len(e.Touched()), e.Height())
+	}
+}
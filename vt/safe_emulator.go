@@ -1,8 +1,11 @@
 package vt
 
 import (
+	"image"
 	"image/color"
+	"image/draw"
 	"sync"
+	"time"
 
 	uv "github.com/charmbracelet/ultraviolet"
 )
@@ -62,6 +65,20 @@ func (se *SafeEmulator) CellAt(x, y int) *uv.Cell {
 	return se.Emulator.CellAt(x, y)
 }
 
+// CellSize returns the size of a cell in pixels in a concurrency-safe manner.
+func (se *SafeEmulator) CellSize() (int, int) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.CellSize()
+}
+
+// SetCellSize sets the size of a cell in pixels in a concurrency-safe manner.
+func (se *SafeEmulator) SetCellSize(width, height int) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetCellSize(width, height)
+}
+
 // SendKey sends a key event to the emulator in a concurrency-safe manner.
 func (se *SafeEmulator) SendKey(key uv.KeyEvent) {
 	se.mu.Lock()
@@ -76,6 +93,20 @@ func (se *SafeEmulator) SendMouse(mouse uv.MouseEvent) {
 	se.Emulator.SendMouse(mouse)
 }
 
+// Focus sends a focus event to the emulator in a concurrency-safe manner.
+func (se *SafeEmulator) Focus() {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.Focus()
+}
+
+// Blur sends a blur event to the emulator in a concurrency-safe manner.
+func (se *SafeEmulator) Blur() {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.Blur()
+}
+
 // SendText sends text input to the emulator in a concurrency-safe manner.
 func (se *SafeEmulator) SendText(text string) {
 	se.mu.Lock()
@@ -118,6 +149,20 @@ func (se *SafeEmulator) SetIndexedColor(index int, color color.Color) {
 	se.Emulator.SetIndexedColor(index, color)
 }
 
+// SetSpecialColor sets a special color in a concurrency-safe manner.
+func (se *SafeEmulator) SetSpecialColor(index int, color color.Color) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetSpecialColor(index, color)
+}
+
+// SpecialColor retrieves a special color in a concurrency-safe manner.
+func (se *SafeEmulator) SpecialColor(index int) color.Color {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.SpecialColor(index)
+}
+
 // IndexedColor retrieves an indexed color in a concurrency-safe manner.
 func (se *SafeEmulator) IndexedColor(index int) color.Color {
 	se.mu.RLock()
@@ -125,6 +170,21 @@ func (se *SafeEmulator) IndexedColor(index int) color.Color {
 	return se.Emulator.IndexedColor(index)
 }
 
+// DrainDamage returns and clears the damaged areas in a concurrency-safe
+// manner.
+func (se *SafeEmulator) DrainDamage() []Damage {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	return se.Emulator.DrainDamage()
+}
+
+// LineSize returns the size attribute of a line in a concurrency-safe manner.
+func (se *SafeEmulator) LineSize(y int) LineSize {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.LineSize(y)
+}
+
 // Touched returns the touched lines in a concurrency-safe manner.
 func (se *SafeEmulator) Touched() []*uv.LineData {
 	se.mu.RLock()
@@ -181,6 +241,93 @@ func (se *SafeEmulator) Draw(s uv.Screen, a uv.Rectangle) {
 	se.Emulator.Draw(s, a)
 }
 
+// Commands returns the tracked shell commands in a concurrency-safe manner.
+func (se *SafeEmulator) Commands() []Command {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.Commands()
+}
+
+// CommandOutput returns the output text of a tracked shell command in a
+// concurrency-safe manner.
+func (se *SafeEmulator) CommandOutput(i int) (string, bool) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.CommandOutput(i)
+}
+
+// DrawImages composites the images visible on the screen onto dst in a
+// concurrency-safe manner.
+func (se *SafeEmulator) DrawImages(dst draw.Image, cellWidth, cellHeight int) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	se.Emulator.DrawImages(dst, cellWidth, cellHeight)
+}
+
+// Images returns the images placed on the active screen in a
+// concurrency-safe manner.
+func (se *SafeEmulator) Images() []ImagePlacement {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.Images()
+}
+
+// KittyImage returns the Kitty graphics image with the given ID in a
+// concurrency-safe manner.
+func (se *SafeEmulator) KittyImage(id int) (image.Image, bool) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.KittyImage(id)
+}
+
+// KittyVirtualPlacements returns the Kitty graphics virtual placements in a
+// concurrency-safe manner.
+func (se *SafeEmulator) KittyVirtualPlacements() []ImagePlacement {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.KittyVirtualPlacements()
+}
+
+// SetKittyFileTransmission sets whether Kitty graphics images can be
+// transmitted using files in a concurrency-safe manner.
+func (se *SafeEmulator) SetKittyFileTransmission(enabled bool) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetKittyFileTransmission(enabled)
+}
+
+// SetKittyImageLimit sets the Kitty graphics image store memory limit in a
+// concurrency-safe manner.
+func (se *SafeEmulator) SetKittyImageLimit(limit int) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetKittyImageLimit(limit)
+}
+
+// SetCapabilities sets the terminal capabilities reported to XTGETTCAP
+// requests in a concurrency-safe manner.
+func (se *SafeEmulator) SetCapabilities(caps map[string]string) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetCapabilities(caps)
+}
+
+// SetClipboardPolicy sets the clipboard access policy in a concurrency-safe
+// manner.
+func (se *SafeEmulator) SetClipboardPolicy(p ClipboardPolicy) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetClipboardPolicy(p)
+}
+
+// SetClipboardProvider sets the clipboard provider in a concurrency-safe
+// manner.
+func (se *SafeEmulator) SetClipboardProvider(p ClipboardProvider) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetClipboardProvider(p)
+}
+
 // Scrollback returns the scrollback buffer in a concurrency-safe manner.
 func (se *SafeEmulator) Scrollback() *Scrollback {
 	se.mu.RLock()
@@ -202,6 +349,22 @@ func (se *SafeEmulator) ScrollbackCellAt(x, y int) *uv.Cell {
 	return se.Emulator.ScrollbackCellAt(x, y)
 }
 
+// SetSyncTimeout sets the maximum duration of a synchronized update in a
+// concurrency-safe manner.
+func (se *SafeEmulator) SetSyncTimeout(d time.Duration) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetSyncTimeout(d)
+}
+
+// SetScrollbackFile sets the file storing the oldest scrollback lines in a
+// concurrency-safe manner.
+func (se *SafeEmulator) SetScrollbackFile(f ScrollbackFile, memLines int) error {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	return se.Emulator.SetScrollbackFile(f, memLines)
+}
+
 // SetScrollbackSize sets the scrollback buffer size in a concurrency-safe manner.
 func (se *SafeEmulator) SetScrollbackSize(maxLines int) {
 	se.mu.Lock()
@@ -222,3 +385,127 @@ func (se *SafeEmulator) IsAltScreen() bool {
 	defer se.mu.RUnlock()
 	return se.Emulator.IsAltScreen()
 }
+
+// SetSelection sets the text selection in a concurrency-safe manner.
+func (se *SafeEmulator) SetSelection(sel Selection) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetSelection(sel)
+}
+
+// Selection returns the text selection in a concurrency-safe manner.
+func (se *SafeEmulator) Selection() (Selection, bool) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.Selection()
+}
+
+// ClearSelection clears the text selection in a concurrency-safe manner.
+func (se *SafeEmulator) ClearSelection() {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.ClearSelection()
+}
+
+// IsSelected reports whether a cell is selected in a concurrency-safe manner.
+func (se *SafeEmulator) IsSelected(x, y int) bool {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.IsSelected(x, y)
+}
+
+// SelectedText returns the selected text in a concurrency-safe manner.
+func (se *SafeEmulator) SelectedText() string {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.SelectedText()
+}
+
+// SelectedStyledText returns the selected text with ANSI styles in a
+// concurrency-safe manner.
+func (se *SafeEmulator) SelectedStyledText() string {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.SelectedStyledText()
+}
+
+// Search starts a search in a concurrency-safe manner.
+func (se *SafeEmulator) Search(pattern string, opts SearchOptions) error {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	return se.Emulator.Search(pattern, opts)
+}
+
+// ClearSearch clears the active search in a concurrency-safe manner.
+func (se *SafeEmulator) ClearSearch() {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.ClearSearch()
+}
+
+// SearchMatches returns the matches of the active search in a
+// concurrency-safe manner.
+func (se *SafeEmulator) SearchMatches(top, bottom int) []SearchMatch {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.SearchMatches(top, bottom)
+}
+
+// FindNext moves to the next search match in a concurrency-safe manner.
+func (se *SafeEmulator) FindNext() (SearchMatch, bool) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	return se.Emulator.FindNext()
+}
+
+// FindPrev moves to the previous search match in a concurrency-safe manner.
+func (se *SafeEmulator) FindPrev() (SearchMatch, bool) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	return se.Emulator.FindPrev()
+}
+
+// Links returns the links on the given rows in a concurrency-safe manner.
+func (se *SafeEmulator) Links(top, bottom int) []LinkSpan {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.Links(top, bottom)
+}
+
+// LinkAt returns the link at the given position in a concurrency-safe
+// manner.
+func (se *SafeEmulator) LinkAt(x, y int) (LinkSpan, bool) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.LinkAt(x, y)
+}
+
+// SetLinkDetection sets whether plain text links are detected in a
+// concurrency-safe manner.
+func (se *SafeEmulator) SetLinkDetection(enabled bool) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.Emulator.SetLinkDetection(enabled)
+}
+
+// MarshalBinary encodes the emulator state in a concurrency-safe manner.
+func (se *SafeEmulator) MarshalBinary() ([]byte, error) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.MarshalBinary()
+}
+
+// UnmarshalBinary restores the emulator state in a concurrency-safe manner.
+func (se *SafeEmulator) UnmarshalBinary(data []byte) error {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	return se.Emulator.UnmarshalBinary(data)
+}
+
+// ReplayANSI returns an ANSI byte stream that repaints a terminal to match
+// the emulator state in a concurrency-safe manner.
+func (se *SafeEmulator) ReplayANSI() []byte {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Emulator.ReplayANSI()
+}
@@ -1,6 +1,8 @@
 package vt
 
 import (
+	"slices"
+
 	uv "github.com/charmbracelet/ultraviolet"
 	"github.com/charmbracelet/x/exp/ordered"
 )
@@ -9,6 +11,8 @@ import (
 type Screen struct {
 	// cb is the callbacks struct to use.
 	cb *Callbacks
+	// events delivers the events to the emulator subscriptions.
+	events *eventHub
 	// The buffer of the screen.
 	buf *uv.RenderBuffer
 	// The cur of the screen.
@@ -17,6 +21,27 @@ type Screen struct {
 	scroll uv.Rectangle
 	// scrollback is the scrollback buffer for lines scrolled off the top.
 	scrollback *Scrollback
+	// wrapped reports whether each line is soft-wrapped into the next one.
+	wrapped []bool
+	// lineSizes are the size attributes of the lines, nil when all lines
+	// are single width.
+	lineSizes []LineSize
+	// images are the images placed on the screen.
+	images []ImagePlacement
+	// commands are the shell commands tracked using semantic prompt
+	// sequences.
+	commands []Command
+	// selection is the text selection, if any.
+	selection *Selection
+	// search is the active search, if any.
+	search *search
+	// protected marks the cells protected from selective erase, if any.
+	protected *protection
+	// damage are the damaged areas since the last drain.
+	damage []Damage
+	// kittyFlags is the Kitty keyboard protocol flags stack. Each screen
+	// keeps its own stack.
+	kittyFlags []int
 }
 
 // NewScreen creates a new screen.
@@ -24,6 +49,7 @@ func NewScreen(w, h int) *Screen {
 	s := Screen{
 		buf:        uv.NewRenderBuffer(w, h),
 		scrollback: NewScrollback(DefaultScrollbackSize),
+		wrapped:    make([]bool, h),
 	}
 	s.scroll = s.buf.Bounds()
 	return &s
@@ -38,6 +64,15 @@ func (s *Screen) Reset() {
 	s.saved = Cursor{}
 	s.scroll = s.buf.Bounds()
 	s.buf.Touched = nil
+	clear(s.wrapped)
+	s.images = nil
+	s.commands = nil
+	s.selection = nil
+	s.search = nil
+	s.protected = nil
+	s.lineSizes = nil
+	s.kittyFlags = nil
+	s.damageArea(s.Bounds())
 }
 
 // Bounds returns the bounds of the screen.
@@ -63,6 +98,11 @@ func (s *Screen) CellAt(x int, y int) *uv.Cell {
 // SetCell sets the cell at the given x, y position.
 func (s *Screen) SetCell(x, y int, c *uv.Cell) {
 	s.buf.SetCell(x, y, c)
+	w := 1
+	if c != nil && c.Width > 1 {
+		w = c.Width
+	}
+	s.addDamage(CellDamage{X: x, Y: y, Width: w})
 }
 
 // Height returns the height of the screen.
@@ -79,6 +119,14 @@ func (s *Screen) Resize(width int, height int) {
 		s.buf.Touched = nil
 	}
 	s.scroll = s.buf.Bounds()
+	// Keep the saved cursor within the screen, like the cursor.
+	s.saved.X, s.saved.Y = min(s.saved.X, width-1), min(s.saved.Y, height-1)
+	s.resizeWrapped(height)
+	s.resizeLineSizes(height)
+	if s.protected != nil {
+		s.protected.resize(width, height)
+	}
+	s.damageArea(s.Bounds())
 }
 
 // Width returns the width of the screen.
@@ -89,6 +137,7 @@ func (s *Screen) Width() int {
 // Clear clears the screen with blank cells.
 func (s *Screen) Clear() {
 	s.ClearArea(s.Bounds())
+	s.lineSizes = nil
 }
 
 // ClearWithScrollback saves all non-empty lines to scrollback before clearing.
@@ -100,7 +149,7 @@ func (s *Screen) ClearWithScrollback() {
 		for y := 0; y < s.buf.Height(); y++ {
 			line := s.buf.Line(y)
 			if line != nil && !s.isLineEmpty(line) {
-				s.scrollback.Push(line)
+				s.scrollback.push(line, s.IsWrapped(y))
 			}
 		}
 	}
@@ -120,7 +169,10 @@ func (s *Screen) isLineEmpty(line uv.Line) bool {
 // ClearArea clears the given area.
 func (s *Screen) ClearArea(area uv.Rectangle) {
 	s.buf.ClearArea(area)
+	s.protect(area, false)
 	s.touchArea(area)
+	s.unwrapArea(area)
+	s.clearImages(area)
 }
 
 // Fill fills the screen or part of it.
@@ -131,7 +183,9 @@ func (s *Screen) Fill(c *uv.Cell) {
 // FillArea fills the given area with the given cell.
 func (s *Screen) FillArea(c *uv.Cell, area uv.Rectangle) {
 	s.buf.FillArea(c, area)
+	s.protect(area, false)
 	s.touchArea(area)
+	s.unwrapArea(area)
 }
 
 // setHorizontalMargins sets the horizontal margins.
@@ -163,10 +217,14 @@ func (s *Screen) setCursor(x, y int, margins bool) {
 		y = ordered.Clamp(s.scroll.Min.Y+y, s.scroll.Min.Y, s.scroll.Max.Y-1)
 		x = ordered.Clamp(s.scroll.Min.X+x, s.scroll.Min.X, s.scroll.Max.X-1)
 	}
+	x = min(x, s.lineWidth(y)-1)
 	s.cur.X, s.cur.Y = x, y
 
-	if s.cb.CursorPosition != nil && (old.X != x || old.Y != y) {
-		s.cb.CursorPosition(old, uv.Pos(x, y))
+	if old.X != x || old.Y != y {
+		if s.cb.CursorPosition != nil {
+			s.cb.CursorPosition(old, uv.Pos(x, y))
+		}
+		emit(s.events, CursorPositionChanged{Old: old, New: uv.Pos(x, y)})
 	}
 }
 
@@ -195,11 +253,15 @@ func (s *Screen) moveCursor(dx, dy int) {
 		y = ordered.Clamp(pt.Y, 0, s.buf.Height()-1)
 		x = ordered.Clamp(pt.X, 0, s.buf.Width()-1)
 	}
+	x = min(x, s.lineWidth(y)-1)
 
 	s.cur.X, s.cur.Y = x, y
 
-	if s.cb.CursorPosition != nil && (old.X != x || old.Y != y) {
-		s.cb.CursorPosition(old, uv.Pos(x, y))
+	if old.X != x || old.Y != y {
+		if s.cb.CursorPosition != nil {
+			s.cb.CursorPosition(old, uv.Pos(x, y))
+		}
+		emit(s.events, CursorPositionChanged{Old: old, New: uv.Pos(x, y)})
 	}
 }
 
@@ -228,8 +290,11 @@ func (s *Screen) RestoreCursor() {
 	old := s.cur.Position
 	s.cur = s.saved
 
-	if s.cb.CursorPosition != nil && (old.X != s.cur.X || old.Y != s.cur.Y) {
-		s.cb.CursorPosition(old, s.cur.Position)
+	if old.X != s.cur.X || old.Y != s.cur.Y {
+		if s.cb.CursorPosition != nil {
+			s.cb.CursorPosition(old, s.cur.Position)
+		}
+		emit(s.events, CursorPositionChanged{Old: old, New: s.cur.Position})
 	}
 }
 
@@ -240,6 +305,9 @@ func (s *Screen) setCursorHidden(hidden bool) {
 	if changed && s.cb.CursorVisibility != nil {
 		s.cb.CursorVisibility(!hidden)
 	}
+	if changed {
+		emit(s.events, CursorVisibilityChanged{Visible: !hidden})
+	}
 }
 
 // setCursorStyle sets the cursor style.
@@ -250,6 +318,9 @@ func (s *Screen) setCursorStyle(style CursorStyle, blink bool) {
 	if changed && s.cb.CursorStyle != nil {
 		s.cb.CursorStyle(style, !blink)
 	}
+	if changed {
+		emit(s.events, CursorStyleChanged{Style: style, Blink: blink})
+	}
 }
 
 // cursorPen returns the cursor pen.
@@ -281,6 +352,10 @@ func (s *Screen) InsertCell(n int) {
 
 	x, y := s.cur.X, s.cur.Y
 	s.buf.InsertCellArea(x, y, n, s.blankCell(), s.scroll)
+	if s.protected != nil {
+		s.protected.insertCells(x, y, n, s.scroll)
+	}
+	s.damageArea(uv.Rect(x, y, s.scroll.Max.X-x, 1))
 }
 
 // DeleteCell deletes n cells at the cursor position moving cells to the left.
@@ -292,6 +367,10 @@ func (s *Screen) DeleteCell(n int) {
 
 	x, y := s.cur.X, s.cur.Y
 	s.buf.DeleteCellArea(x, y, n, s.blankCell(), s.scroll)
+	if s.protected != nil {
+		s.protected.deleteCells(x, y, n, s.scroll)
+	}
+	s.damageArea(uv.Rect(x, y, s.scroll.Max.X-x, 1))
 }
 
 // ScrollUp scrolls the content up n lines within the given region. Lines
@@ -300,7 +379,9 @@ func (s *Screen) DeleteCell(n int) {
 func (s *Screen) ScrollUp(n int) {
 	x, y := s.CursorPosition()
 	s.setCursor(s.cur.X, 0, true)
-	s.DeleteLine(n)
+	if s.deleteLine(n) {
+		s.addDamage(ScrollDamage{Rectangle: s.scroll, Dy: -n})
+	}
 	s.setCursor(x, y, false)
 }
 
@@ -310,7 +391,9 @@ func (s *Screen) ScrollUp(n int) {
 func (s *Screen) ScrollDown(n int) {
 	x, y := s.CursorPosition()
 	s.setCursor(s.cur.X, 0, true)
-	s.InsertLine(n)
+	if s.insertLine(n) {
+		s.addDamage(ScrollDamage{Rectangle: s.scroll, Dy: n})
+	}
 	s.setCursor(x, y, false)
 }
 
@@ -319,6 +402,15 @@ func (s *Screen) ScrollDown(n int) {
 // are moved down, with those past bottom margin being discarded.
 // It returns true if the operation was successful.
 func (s *Screen) InsertLine(n int) bool {
+	if !s.insertLine(n) {
+		return false
+	}
+	s.damageArea(uv.Rect(s.scroll.Min.X, s.cur.Y, s.scroll.Dx(), s.scroll.Max.Y-s.cur.Y))
+	return true
+}
+
+// insertLine is like [Screen.InsertLine] without recording damage.
+func (s *Screen) insertLine(n int) bool {
 	if n <= 0 {
 		return false
 	}
@@ -331,7 +423,19 @@ func (s *Screen) InsertLine(n int) bool {
 		return false
 	}
 
-	s.buf.InsertLineArea(y, n, s.blankCell(), s.scroll)
+	if s.fullWidth(s.scroll) {
+		s.moveLines(y, n, s.scroll)
+	} else {
+		s.buf.InsertLineArea(y, n, s.blankCell(), s.scroll)
+	}
+	if s.protected != nil {
+		s.protected.insertLines(y, n, s.scroll)
+	}
+	s.insertWrapped(y, n, s.scroll)
+	s.insertLineSizes(y, n, s.scroll)
+	s.insertImages(y, n, s.scroll)
+	s.insertMarks(y, n, s.scroll)
+	s.insertSelection(y, n, s.scroll)
 
 	return true
 }
@@ -343,6 +447,15 @@ func (s *Screen) InsertLine(n int) bool {
 // are saved to the scrollback buffer before deletion.
 // It returns true if the operation was successful.
 func (s *Screen) DeleteLine(n int) bool {
+	if !s.deleteLine(n) {
+		return false
+	}
+	s.damageArea(uv.Rect(s.scroll.Min.X, s.cur.Y, s.scroll.Dx(), s.scroll.Max.Y-s.cur.Y))
+	return true
+}
+
+// deleteLine is like [Screen.DeleteLine] without recording damage.
+func (s *Screen) deleteLine(n int) bool {
 	if n <= 0 {
 		return false
 	}
@@ -359,18 +472,93 @@ func (s *Screen) DeleteLine(n int) bool {
 	// Save lines to scrollback if we're at the top of the scroll region
 	// and the scroll region uses the full width (typical terminal scroll).
 	// This captures lines that would be lost during scroll up operations.
-	if s.scrollback != nil && y == scroll.Min.Y &&
-		scroll.Min.X == 0 && scroll.Max.X == s.buf.Width() {
+	save := s.scrollback != nil && y == scroll.Min.Y &&
+		scroll.Min.X == 0 && scroll.Max.X == s.buf.Width()
+	if save {
 		// Save lines that will be deleted
 		linesToSave := min(n, scroll.Max.Y-y)
-		s.scrollback.PushN(s.buf, y, linesToSave)
+		for i := range linesToSave {
+			if line := s.buf.Line(y + i); line != nil {
+				s.scrollback.push(line, s.IsWrapped(y+i))
+			}
+		}
 	}
 
-	s.buf.DeleteLineArea(y, n, s.blankCell(), scroll)
+	if s.fullWidth(scroll) {
+		s.moveLines(y, -n, scroll)
+	} else {
+		s.buf.DeleteLineArea(y, n, s.blankCell(), scroll)
+	}
+	if s.protected != nil {
+		s.protected.deleteLines(y, n, scroll)
+	}
+	s.deleteWrapped(y, n, scroll)
+	s.deleteLineSizes(y, n, scroll)
+	s.deleteImages(y, n, scroll, save)
+	s.deleteMarks(y, n, scroll, save)
+	s.deleteSelection(y, n, scroll, save)
+	if save {
+		s.pruneScrollback()
+	}
 
 	return true
 }
 
+// moveLines moves the lines of the scroll region starting at y down by n
+// lines, or up when n is negative, and blanks the lines left behind. It's
+// like [uv.RenderBuffer.InsertLineArea] and [uv.RenderBuffer.DeleteLineArea]
+// for scroll regions spanning the whole width, but moves the lines instead of
+// copying their cells.
+func (s *Screen) moveLines(y, n int, scroll uv.Rectangle) {
+	lines := s.buf.Lines[y:scroll.Max.Y]
+	k := min(max(n, -n), len(lines))
+	var blank []uv.Line
+	if n > 0 {
+		rotateLines(lines, len(lines)-k)
+		blank = lines[:k]
+	} else {
+		rotateLines(lines, k)
+		blank = lines[len(lines)-k:]
+	}
+
+	cell := uv.EmptyCell
+	if c := s.blankCell(); c != nil {
+		cell = *c
+	}
+	for _, line := range blank {
+		for x := range line {
+			line[x] = cell
+		}
+	}
+	for i := y; i < scroll.Max.Y; i++ {
+		s.buf.TouchLine(0, i, s.buf.Width())
+	}
+}
+
+// rotateLines rotates the lines to the left by k lines.
+func rotateLines(lines []uv.Line, k int) {
+	slices.Reverse(lines[:k])
+	slices.Reverse(lines[k:])
+	slices.Reverse(lines)
+}
+
+// savedRow returns the row a line at row r moves to after the n lines at y,
+// the top of the given scroll region, are deleted and saved to the scrollback
+// buffer. The lines above the scroll region stay in place.
+func savedRow(r, y, n int, scroll uv.Rectangle) int {
+	saved := min(n, scroll.Max.Y-y)
+	switch {
+	case r < 0:
+		return r - saved
+	case r < y || r >= scroll.Max.Y:
+		return r
+	case r < y+saved:
+		return r - y - saved
+	default:
+		return r - n
+	}
+}
+
 // blankCell returns the cursor blank cell with the background color set to the
 // current pen background color. If the pen background color is nil, the return
 // value is nil.
@@ -384,11 +572,12 @@ func (s *Screen) blankCell() *uv.Cell {
 	return &c
 }
 
-// touchArea marks all lines in the given area as touched.
+// touchArea marks all lines in the given area as touched and damaged.
 func (s *Screen) touchArea(area uv.Rectangle) {
 	for y := area.Min.Y; y < area.Max.Y; y++ {
 		s.buf.TouchLine(area.Min.X, y, area.Max.X-area.Min.X)
 	}
+	s.damageArea(area)
 }
 
 // Scrollback returns the screen's scrollback buffer.
@@ -409,4 +598,5 @@ func (s *Screen) SetScrollbackSize(maxLines int) {
 	} else {
 		s.scrollback.SetMaxLines(maxLines)
 	}
+	s.pruneScrollback()
 }
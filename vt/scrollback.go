@@ -1,8 +1,6 @@
 package vt
 
 import (
-	"slices"
-
 	uv "github.com/charmbracelet/ultraviolet"
 )
 
@@ -10,8 +8,16 @@ import (
 const DefaultScrollbackSize = 10000
 
 // Scrollback represents a scrollback buffer that stores lines scrolled off the screen.
+//
+// Lines are stored in a compact encoding and decoded when they are accessed.
+// The oldest lines can be moved to a file with [Scrollback.SetFile] to keep
+// large histories out of memory.
 type Scrollback struct {
-	lines    []uv.Line
+	// lines are the lines kept in memory, oldest first. They follow the
+	// lines stored in the file, if any.
+	lines    []compactLine
+	file     *scrollbackFile
+	err      error
 	maxLines int
 }
 
@@ -21,7 +27,7 @@ func NewScrollback(maxLines int) *Scrollback {
 		maxLines = DefaultScrollbackSize
 	}
 	return &Scrollback{
-		lines:    make([]uv.Line, 0, min(maxLines, 1000)), // Pre-allocate reasonable capacity
+		lines:    make([]compactLine, 0, min(maxLines, 1000)), // Pre-allocate reasonable capacity
 		maxLines: maxLines,
 	}
 }
@@ -29,29 +35,83 @@ func NewScrollback(maxLines int) *Scrollback {
 // Push adds a line to the scrollback buffer.
 // If the buffer is full, the oldest line is removed.
 func (s *Scrollback) Push(line uv.Line) {
+	s.push(line, false)
+}
+
+// push adds a line to the scrollback buffer and records whether it's
+// soft-wrapped into the next line. Trailing empty cells are trimmed from
+// lines that are not soft-wrapped.
+func (s *Scrollback) push(line uv.Line, wrapped bool) {
 	if s == nil || s.maxLines <= 0 {
 		return
 	}
 
-	// Find last non-empty cell to trim trailing empty cells.
-	// This helps with wrapping and window resizing.
-	lastNonEmpty := -1
-	for i := len(line) - 1; i >= 0; i-- {
-		c := &line[i]
-		if !c.IsZero() && !c.Equal(&uv.EmptyCell) {
-			lastNonEmpty = i
+	end := len(line)
+	if !wrapped {
+		// Find last non-empty cell to trim trailing empty cells.
+		// This helps with wrapping and window resizing.
+		end = trimmedLen(line)
+	}
+
+	if s.Len() >= s.maxLines {
+		// Remove oldest line and append new one
+		s.drop(1)
+	}
+	s.lines = append(s.lines, newCompactLine(line[:end], wrapped))
+	s.spill()
+}
+
+// drop removes the n oldest lines.
+func (s *Scrollback) drop(n int) {
+	if m := min(n, s.file.Len()); m > 0 {
+		if err := s.file.drop(m); err != nil {
+			s.err = err
+		}
+		n -= m
+	}
+	n = min(n, len(s.lines))
+	clear(s.lines[:n])
+	s.lines = s.lines[n:]
+}
+
+// spill moves the lines exceeding the memory limit to the scrollback file.
+// Lines that can't be written stay in memory.
+func (s *Scrollback) spill() {
+	if s.file == nil {
+		return
+	}
+	n := 0
+	for ; n < len(s.lines)-s.file.memLines; n++ {
+		if err := s.file.store(&s.lines[n]); err != nil {
+			s.err = err
 			break
 		}
 	}
+	clear(s.lines[:n])
+	s.lines = s.lines[n:]
+}
 
-	// Clone the line content up to and including the last non-empty cell
-	cloned := slices.Clone(line[:lastNonEmpty+1])
+// line returns the encoded line at the given index.
+func (s *Scrollback) line(index int) (compactLine, bool) {
+	if s == nil || index < 0 || index >= s.Len() {
+		return compactLine{}, false
+	}
+	if n := s.file.Len(); index < n {
+		l, err := s.file.load(index)
+		return l, err == nil
+	}
+	return s.lines[index-s.file.Len()], true
+}
 
-	if len(s.lines) >= s.maxLines {
-		// Remove oldest line and append new one
-		s.lines = slices.Delete(s.lines, 0, 1)
+// trimmedLen returns the length of the line without trailing empty cells.
+func trimmedLen(line uv.Line) int {
+	for i := len(line) - 1; i >= 0; i-- {
+		c := &line[i]
+		if !c.IsZero() && !c.Equal(&uv.EmptyCell) {
+			return i + 1
+		}
 	}
-	s.lines = append(s.lines, cloned)
+	return 0
 }
 
 // PushN adds n lines from the buffer starting at line y to the scrollback.
@@ -72,7 +132,7 @@ func (s *Scrollback) Len() int {
 	if s == nil {
 		return 0
 	}
-	return len(s.lines)
+	return s.file.Len() + len(s.lines)
 }
 
 // MaxLines returns the maximum number of lines the scrollback buffer can hold.
@@ -91,20 +151,23 @@ func (s *Scrollback) SetMaxLines(maxLines int) {
 	}
 
 	s.maxLines = maxLines
-	if len(s.lines) > maxLines {
+	if n := s.Len() - maxLines; n > 0 {
 		// Remove oldest lines
-		s.lines = s.lines[len(s.lines)-maxLines:]
+		s.drop(n)
 	}
 }
 
 // Line returns the line at the given index.
 // Index 0 is the oldest line, Len()-1 is the most recent.
-// Returns nil if index is out of bounds.
+// Returns nil if index is out of bounds or the line can't be read from the
+// scrollback file. The line is decoded on each call, modifying it doesn't
+// change the scrollback buffer.
 func (s *Scrollback) Line(index int) uv.Line {
-	if s == nil || index < 0 || index >= len(s.lines) {
+	l, ok := s.line(index)
+	if !ok {
 		return nil
 	}
-	return s.lines[index]
+	return l.line()
 }
 
 // Lines returns all lines in the scrollback buffer.
@@ -113,7 +176,11 @@ func (s *Scrollback) Lines() []uv.Line {
 	if s == nil {
 		return nil
 	}
-	return s.lines
+	lines := make([]uv.Line, s.Len())
+	for i := range lines {
+		lines[i] = s.Line(i)
+	}
+	return lines
 }
 
 // Clear removes all lines from the scrollback buffer.
@@ -121,16 +188,100 @@ func (s *Scrollback) Clear() {
 	if s == nil {
 		return
 	}
+	clear(s.lines)
 	s.lines = s.lines[:0]
+	if s.file != nil {
+		if err := s.file.reset(); err != nil {
+			s.err = err
+		}
+	}
+}
+
+// IsWrapped returns whether the line at the given index is soft-wrapped,
+// meaning it continues on the next line. Lines that end with a hard newline
+// are not wrapped.
+func (s *Scrollback) IsWrapped(index int) bool {
+	if s == nil || index < 0 || index >= s.Len() {
+		return false
+	}
+	if n := s.file.Len(); index < n {
+		return s.file.wrapped[index]
+	}
+	return s.lines[index-s.file.Len()].wrapped
+}
+
+// setLines replaces the scrollback lines and their wrapped state. Oldest
+// lines exceeding the maximum number of lines are dropped.
+func (s *Scrollback) setLines(lines []uv.Line, wrapped []bool) {
+	if s == nil {
+		return
+	}
+	if n := len(lines) - s.maxLines; n > 0 {
+		lines, wrapped = lines[n:], wrapped[n:]
+	}
+	s.Clear()
+	for i, line := range lines {
+		s.lines = append(s.lines, newCompactLine(line, wrapped[i]))
+	}
+	s.spill()
 }
 
 // CellAt returns the cell at the given position in the scrollback buffer.
 // x is the column, y is the line index (0 = oldest).
-// Returns nil if position is out of bounds.
+// Returns nil if position is out of bounds. The cell is decoded on each
+// call, modifying it doesn't change the scrollback buffer.
 func (s *Scrollback) CellAt(x, y int) *uv.Cell {
-	line := s.Line(y)
-	if line == nil || x < 0 || x >= len(line) {
+	l, ok := s.line(y)
+	if !ok {
+		return nil
+	}
+	c, ok := l.cell(x)
+	if !ok {
+		return nil
+	}
+	return &c
+}
+
+// SetFile moves the scrollback lines beyond the most recent memLines lines
+// to the given file, keeping them out of memory. The file is truncated and
+// owned by the scrollback buffer until another file is set. Pass a nil file
+// to keep all lines in memory again.
+func (s *Scrollback) SetFile(f ScrollbackFile, memLines int) error {
+	if s == nil {
+		return nil
+	}
+
+	// Load the stored lines back into memory.
+	if n := s.file.Len(); n > 0 {
+		lines := make([]compactLine, n, n+len(s.lines))
+		for i := range lines {
+			l, err := s.file.load(i)
+			if err != nil {
+				return err
+			}
+			lines[i] = l
+		}
+		s.lines = append(lines, s.lines...)
+	}
+	s.file, s.err = nil, nil
+	if f == nil {
+		return nil
+	}
+
+	file := &scrollbackFile{f: f, memLines: max(memLines, 0)}
+	if err := file.reset(); err != nil {
+		return err
+	}
+	s.file = file
+	s.spill()
+	return s.err
+}
+
+// Err returns the last error that occurred while writing the scrollback
+// file, if any. Lines that can't be written to the file are kept in memory.
+func (s *Scrollback) Err() error {
+	if s == nil {
 		return nil
 	}
-	return &line[x]
+	return s.err
 }
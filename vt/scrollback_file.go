@@ -0,0 +1,109 @@
+package vt
+
+import (
+	"fmt"
+	"io"
+)
+
+// ScrollbackFile is the storage of the file-backed scrollback tier. An
+// [os.File] opened for reading and writing can be used as a scrollback file.
+// Its content is owned by the scrollback buffer.
+type ScrollbackFile interface {
+	io.ReaderAt
+	io.WriterAt
+	Truncate(size int64) error
+}
+
+// scrollbackFile stores the oldest lines of a scrollback buffer in a file.
+// Lines are appended at the end of the file and dropped from the start. The
+// dropped data is reclaimed once it outgrows the stored lines.
+type scrollbackFile struct {
+	f ScrollbackFile
+	// memLines is the number of recent lines kept in memory.
+	memLines int
+	// offs are the offsets of the stored lines, oldest first. Each line ends
+	// where the next one starts, and the last one at size.
+	offs []int64
+	// wrapped reports whether each stored line is soft-wrapped.
+	wrapped []bool
+	size    int64
+	buf     []byte
+}
+
+// Len returns the number of lines stored in the file.
+func (f *scrollbackFile) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.offs)
+}
+
+// store appends a line to the file.
+func (f *scrollbackFile) store(l *compactLine) error {
+	f.buf = l.appendBinary(f.buf[:0])
+	if _, err := f.f.WriteAt(f.buf, f.size); err != nil {
+		return fmt.Errorf("vt: writing scrollback file: %w", err)
+	}
+	f.offs = append(f.offs, f.size)
+	f.wrapped = append(f.wrapped, l.wrapped)
+	f.size += int64(len(f.buf))
+	return nil
+}
+
+// load reads the line at index i.
+func (f *scrollbackFile) load(i int) (compactLine, error) {
+	end := f.size
+	if i+1 < len(f.offs) {
+		end = f.offs[i+1]
+	}
+	b := make([]byte, end-f.offs[i])
+	if _, err := f.f.ReadAt(b, f.offs[i]); err != nil {
+		return compactLine{}, fmt.Errorf("vt: reading scrollback file: %w", err)
+	}
+	return decodeCompactLine(b)
+}
+
+// drop removes the n oldest lines.
+func (f *scrollbackFile) drop(n int) error {
+	n = min(n, len(f.offs))
+	f.offs, f.wrapped = f.offs[n:], f.wrapped[n:]
+	if len(f.offs) == 0 {
+		return f.reset()
+	}
+
+	// Move the stored lines to the start of the file once the dropped data
+	// is larger than them. The lines are then copied over the dropped data
+	// only, so a failed copy leaves them intact.
+	dead := f.offs[0]
+	if dead <= f.size-dead {
+		return nil
+	}
+	buf := make([]byte, min(f.size-dead, 32*1024)) //nolint:mnd
+	for off := dead; off < f.size; {
+		n, err := f.f.ReadAt(buf[:min(int64(len(buf)), f.size-off)], off)
+		if err != nil {
+			return fmt.Errorf("vt: reading scrollback file: %w", err)
+		}
+		if _, err := f.f.WriteAt(buf[:n], off-dead); err != nil {
+			return fmt.Errorf("vt: writing scrollback file: %w", err)
+		}
+		off += int64(n)
+	}
+	for i := range f.offs {
+		f.offs[i] -= dead
+	}
+	f.size -= dead
+	if err := f.f.Truncate(f.size); err != nil {
+		return fmt.Errorf("vt: truncating scrollback file: %w", err)
+	}
+	return nil
+}
+
+// reset removes all the lines.
+func (f *scrollbackFile) reset() error {
+	f.offs, f.wrapped, f.size = f.offs[:0], f.wrapped[:0], 0
+	if err := f.f.Truncate(0); err != nil {
+		return fmt.Errorf("vt: truncating scrollback file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package vt
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// compactLine is a scrollback line stored compactly. The cell contents are
+// concatenated into a single string, and the styles and hyperlinks are
+// stored as runs of cells sharing them. Cells are decoded on demand.
+type compactLine struct {
+	// text is the concatenated content of the cells.
+	text string
+	// cells are the end offsets in text and the widths of the cells. It's
+	// nil when every cell is a single ASCII character one cell wide, in which
+	// case cell i is text[i].
+	cells []cellSpan
+	// runs are the style runs of the line, ordered by their first cell. It's
+	// nil when the line has no styles or hyperlinks.
+	runs    []styleRun
+	wrapped bool
+}
+
+// cellSpan is the end offset of a cell content and its width.
+type cellSpan struct {
+	end   uint32
+	width uint8
+}
+
+// styleRun is a run of cells sharing a style and a hyperlink.
+type styleRun struct {
+	start int32
+	style uv.Style
+	link  uv.Link
+}
+
+// newCompactLine encodes a line.
+func newCompactLine(line uv.Line, wrapped bool) compactLine {
+	l := compactLine{wrapped: wrapped}
+
+	ascii := true
+	size := 0
+	for i := range line {
+		c := &line[i]
+		size += len(c.Content)
+		if ascii && (c.Width != 1 || len(c.Content) != 1 || c.Content[0] >= utf8.RuneSelf) {
+			ascii = false
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(size)
+	if !ascii {
+		l.cells = make([]cellSpan, len(line))
+	}
+	for i := range line {
+		c := &line[i]
+		b.WriteString(c.Content)
+		if !ascii {
+			l.cells[i] = cellSpan{end: uint32(b.Len()), width: uint8(c.Width)} //nolint:gosec
+		}
+		if n := len(l.runs); n > 0 && sameStyle(&l.runs[n-1].style, &c.Style) && l.runs[n-1].link == c.Link {
+			continue
+		}
+		if len(l.runs) == 0 && sameStyle(&c.Style, &uv.Style{}) && c.Link == (uv.Link{}) {
+			continue
+		}
+		l.runs = append(l.runs, styleRun{start: int32(i), style: c.Style, link: c.Link}) //nolint:gosec
+	}
+	l.text = b.String()
+	return l
+}
+
+// sameStyle reports whether both styles are the same. Unlike
+// [uv.Style.Equal], colors of different types are different even when they
+// have the same RGBA values.
+func sameStyle(a, b *uv.Style) bool {
+	return a.Attrs == b.Attrs && a.Underline == b.Underline &&
+		a.Fg == b.Fg && a.Bg == b.Bg && a.UnderlineColor == b.UnderlineColor
+}
+
+// Len returns the number of cells in the line.
+func (l *compactLine) Len() int {
+	if l.cells == nil {
+		return len(l.text)
+	}
+	return len(l.cells)
+}
+
+// cell decodes the cell at x. It returns false if x is out of bounds.
+func (l *compactLine) cell(x int) (uv.Cell, bool) {
+	if x < 0 || x >= l.Len() {
+		return uv.Cell{}, false
+	}
+	var c uv.Cell
+	if l.cells == nil {
+		c.Content, c.Width = l.text[x:x+1], 1
+	} else {
+		var start uint32
+		if x > 0 {
+			start = l.cells[x-1].end
+		}
+		c.Content, c.Width = l.text[start:l.cells[x].end], int(l.cells[x].width)
+	}
+	// Find the last run starting at or before x.
+	if i := sort.Search(len(l.runs), func(i int) bool { return int(l.runs[i].start) > x }); i > 0 {
+		c.Style, c.Link = l.runs[i-1].style, l.runs[i-1].link
+	}
+	return c, true
+}
+
+// line decodes the cells of the line.
+func (l *compactLine) line() uv.Line {
+	n := l.Len()
+	if n == 0 {
+		return nil
+	}
+	line := make(uv.Line, n)
+	var start uint32
+	for x := range line {
+		c := &line[x]
+		if l.cells == nil {
+			c.Content, c.Width = l.text[x:x+1], 1
+		} else {
+			c.Content, c.Width = l.text[start:l.cells[x].end], int(l.cells[x].width)
+			start = l.cells[x].end
+		}
+	}
+	for i, r := range l.runs {
+		end := n
+		if i+1 < len(l.runs) {
+			end = int(l.runs[i+1].start)
+		}
+		for x := int(r.start); x < end; x++ {
+			line[x].Style, line[x].Link = r.style, r.link
+		}
+	}
+	return line
+}
+
+// Compact line flags of the binary encoding.
+const (
+	lineWrapped = 1 << iota
+	lineCells
+)
+
+// appendBinary appends the binary encoding of the line to b. It's used to
+// store lines in a scrollback file.
+func (l *compactLine) appendBinary(b []byte) []byte {
+	var flags byte
+	if l.wrapped {
+		flags |= lineWrapped
+	}
+	if l.cells != nil {
+		flags |= lineCells
+	}
+	b = append(b, flags)
+	b = appendString(b, l.text)
+	if l.cells != nil {
+		b = binary.AppendUvarint(b, uint64(len(l.cells)))
+		for _, c := range l.cells {
+			b = binary.AppendUvarint(b, uint64(c.end))
+			b = append(b, c.width)
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(len(l.runs)))
+	for _, r := range l.runs {
+		b = binary.AppendUvarint(b, uint64(r.start)) //nolint:gosec
+		b = appendString(b, encodeColor(r.style.Fg))
+		b = appendString(b, encodeColor(r.style.Bg))
+		b = appendString(b, encodeColor(r.style.UnderlineColor))
+		b = append(b, r.style.Underline, r.style.Attrs)
+		b = appendString(b, r.link.URL)
+		b = appendString(b, r.link.Params)
+	}
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// errCorruptLine is returned when a stored scrollback line can't be decoded.
+var errCorruptLine = errors.New("vt: corrupt scrollback line")
+
+// decodeCompactLine decodes a line encoded with [compactLine.appendBinary].
+func decodeCompactLine(b []byte) (compactLine, error) {
+	d := lineDecoder{b: b}
+	flags := d.byte()
+	l := compactLine{wrapped: flags&lineWrapped != 0, text: d.string()}
+	if flags&lineCells != 0 {
+		n := d.uvarint()
+		if n > uint64(len(d.b)) {
+			return compactLine{}, errCorruptLine
+		}
+		l.cells = make([]cellSpan, n)
+		for i := range l.cells {
+			l.cells[i] = cellSpan{end: uint32(d.uvarint()), width: d.byte()} //nolint:gosec
+			if int(l.cells[i].end) > len(l.text) || (i > 0 && l.cells[i].end < l.cells[i-1].end) {
+				return compactLine{}, errCorruptLine
+			}
+		}
+	}
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		return compactLine{}, errCorruptLine
+	}
+	if n > 0 {
+		l.runs = make([]styleRun, n)
+	}
+	for i := range l.runs {
+		r := &l.runs[i]
+		r.start = int32(d.uvarint()) //nolint:gosec
+		r.style.Fg = decodeColor(d.string())
+		r.style.Bg = decodeColor(d.string())
+		r.style.UnderlineColor = decodeColor(d.string())
+		r.style.Underline = d.byte()
+		r.style.Attrs = d.byte()
+		r.link.URL = d.string()
+		r.link.Params = d.string()
+		if r.start < 0 || int(r.start) >= l.Len() || (i > 0 && r.start <= l.runs[i-1].start) {
+			return compactLine{}, errCorruptLine
+		}
+	}
+	if d.err {
+		return compactLine{}, errCorruptLine
+	}
+	return l, nil
+}
+
+// lineDecoder reads the fields of a binary encoded line. Reading past the
+// end of the data sets err.
+type lineDecoder struct {
+	b   []byte
+	err bool
+}
+
+func (d *lineDecoder) byte() byte {
+	if len(d.b) == 0 {
+		d.err = true
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *lineDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *lineDecoder) string() string {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.err = true
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
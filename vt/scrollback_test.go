@@ -1,7 +1,16 @@
 package vt
 
 import (
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
 )
 
 func TestScrollback(t *testing.T) {
@@ -163,4 +172,224 @@ func TestScrollback(t *testing.T) {
 			t.Errorf("expected empty scrollback after ED 3, got %d", e.ScrollbackLen())
 		}
 	})
+
+	t.Run("scroll region saves to scrollback", func(t *testing.T) {
+		e := NewEmulator(20, 4)
+
+		// Scroll a region below a status line, like a pager or a shell with
+		// a status bar does.
+		e.WriteString("status\x1b[2;4r\x1b[2;1Hone\r\ntwo\r\nthree\r\nfour")
+
+		if got := e.ScrollbackLen(); got != 1 {
+			t.Fatalf("expected 1 line in scrollback, got %d", got)
+		}
+		if got := e.Scrollback().Line(0).String(); got != "one" {
+			t.Errorf("scrollback line = %q, want %q", got, "one")
+		}
+		if got := e.scr.buf.Line(0).String(); got != "status" {
+			t.Errorf("status line = %q, want %q", got, "status")
+		}
+	})
+}
+
+// screenLine returns the first line of an emulator after writing s.
+func screenLine(s string) uv.Line {
+	e := NewEmulator(20, 2)
+	e.WriteString(s)
+	return e.scr.buf.Line(0)
+}
+
+func TestScrollbackEncoding(t *testing.T) {
+	lines := []uv.Line{
+		screenLine("plain ascii"),
+		screenLine("\x1b[1;31mred\x1b[m and \x1b[38;2;1;2;3;48;5;4mtrue\x1b[4:3m color"),
+		screenLine("wide 世界 and é"),
+		screenLine("\x1b]8;id=1;https://example.com\x1b\\link\x1b]8;;\x1b\\ text"),
+		{{Content: "a", Width: 1}, {}, {Content: "b", Width: 1}},
+		nil,
+	}
+
+	sb := NewScrollback(10)
+	for i, line := range lines {
+		sb.push(line, i == 1)
+	}
+	for i, line := range lines {
+		want := line
+		if i != 1 {
+			want = line[:trimmedLen(line)]
+		}
+		if len(want) == 0 {
+			want = nil
+		}
+		if got := sb.Line(i); !reflect.DeepEqual(got, want) {
+			t.Errorf("line %d = %v, want %v", i, got, want)
+		}
+		for x := range want {
+			if got := sb.CellAt(x, i); got == nil || !reflect.DeepEqual(*got, want[x]) {
+				t.Errorf("cell %d,%d = %v, want %v", x, i, got, want[x])
+			}
+		}
+		if sb.IsWrapped(i) != (i == 1) {
+			t.Errorf("line %d wrapped = %v, want %v", i, sb.IsWrapped(i), i == 1)
+		}
+	}
+	if sb.CellAt(20, 0) != nil || sb.CellAt(0, 6) != nil {
+		t.Errorf("expected nil cells out of bounds")
+	}
+
+	// Modifying a decoded line doesn't change the scrollback.
+	sb.Line(0)[0].Content = "x"
+	if got := sb.CellAt(0, 0).Content; got != "p" {
+		t.Errorf("cell content = %q, want %q", got, "p")
+	}
+
+	for i, line := range lines[:4] {
+		l := newCompactLine(line, i == 1)
+		got, err := decodeCompactLine(l.appendBinary(nil))
+		if err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got.line(), l.line()) || got.wrapped != l.wrapped {
+			t.Errorf("decoded line %d = %v, want %v", i, got.line(), l.line())
+		}
+		b := l.appendBinary(nil)
+		if _, err := decodeCompactLine(b[:len(b)-1]); err == nil {
+			t.Errorf("expected an error decoding truncated line %d", i)
+		}
+	}
+}
+
+func TestScrollbackFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "scrollback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	e := NewEmulator(20, 2)
+	e.SetScrollbackSize(50)
+	e.WriteString("\x1b[32mbefore\x1b[m\r\n")
+	if err := e.SetScrollbackFile(f, 10); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 200 {
+		fmt.Fprintf(e, "\x1b[32mline %d\x1b[m\r\n", i)
+	}
+
+	sb := e.Scrollback()
+	if sb.Len() != 50 || sb.file.Len() != 40 || len(sb.lines) != 10 {
+		t.Fatalf("lines = %d, stored = %d, in memory = %d, want 50, 40, 10", sb.Len(), sb.file.Len(), len(sb.lines))
+	}
+	for _, i := range []int{0, 39, 40, 49} {
+		want := fmt.Sprintf("line %d", 149+i)
+		if got := lineText(sb.Line(i)); got != want {
+			t.Errorf("line %d = %q, want %q", i, got, want)
+		}
+	}
+	if c := e.ScrollbackCellAt(0, 0); c == nil || c.Style.Fg != ansi.Green {
+		t.Errorf("stored cell = %v, want a green cell", c)
+	}
+
+	// The data of the dropped lines is reclaimed.
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != sb.file.size || sb.file.size > 2*(sb.file.size-sb.file.offs[0])+64 {
+		t.Errorf("file size = %d, want at most twice the stored lines", fi.Size())
+	}
+
+	if err := e.SetScrollbackFile(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if sb.Len() != 50 || len(sb.lines) != 50 || lineText(sb.Line(0)) != "line 149" {
+		t.Errorf("lines = %d, first = %q, want 50 lines in memory", len(sb.lines), lineText(sb.Line(0)))
+	}
+	if err := sb.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// lineText returns the content of a line.
+func lineText(line uv.Line) string {
+	var b strings.Builder
+	for _, c := range line {
+		b.WriteString(c.Content)
+	}
+	return b.String()
+}
+
+// benchmarkLines returns lines of a colored log output.
+func benchmarkLines() []uv.Line {
+	e := NewEmulator(120, 1)
+	lines := make([]uv.Line, 0, 100)
+	for i := range cap(lines) {
+		e.WriteString("\x1b[H\x1b[2K")
+		fmt.Fprintf(e, "\x1b[2m2024-01-01T00:00:%02dZ\x1b[m \x1b[32mINFO\x1b[m request handled path=/api/v1/items/%d status=200 duration=%dms", i%60, i, i*7)
+		lines = append(lines, slices.Clone(e.scr.buf.Line(0)))
+	}
+	return lines
+}
+
+// BenchmarkScrollbackMemory reports the heap used by 10k lines of scrollback
+// stored compactly and as cloned cells.
+func BenchmarkScrollbackMemory(b *testing.B) {
+	const n = 10000
+	lines := benchmarkLines()
+	heap := func() uint64 {
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+
+	b.Run("compact", func(b *testing.B) {
+		var sb *Scrollback
+		for range b.N {
+			before := heap()
+			sb = NewScrollback(n)
+			for i := range n {
+				sb.Push(lines[i%len(lines)])
+			}
+			b.ReportMetric(float64(heap()-before)/n, "B/line")
+		}
+		runtime.KeepAlive(sb)
+	})
+
+	b.Run("cells", func(b *testing.B) {
+		var cells []uv.Line
+		for range b.N {
+			before := heap()
+			cells = make([]uv.Line, 0, n)
+			for i := range n {
+				line := lines[i%len(lines)]
+				cells = append(cells, slices.Clone(line[:trimmedLen(line)]))
+			}
+			b.ReportMetric(float64(heap()-before)/n, "B/line")
+		}
+		runtime.KeepAlive(cells)
+	})
+}
+
+func BenchmarkScrollbackPush(b *testing.B) {
+	lines := benchmarkLines()
+	sb := NewScrollback(DefaultScrollbackSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		sb.Push(lines[i%len(lines)])
+	}
+}
+
+func BenchmarkScrollbackCellAt(b *testing.B) {
+	lines := benchmarkLines()
+	sb := NewScrollback(len(lines))
+	for _, line := range lines {
+		sb.Push(line)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		_ = sb.CellAt(i%80, i%len(lines))
+	}
 }
@@ -0,0 +1,234 @@
+package vt
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// SearchOptions are the options of a search.
+type SearchOptions struct {
+	// Regex interprets the pattern as a regular expression using the
+	// [regexp] syntax instead of a literal string.
+	Regex bool
+	// IgnoreCase makes the search case-insensitive.
+	IgnoreCase bool
+}
+
+// SearchMatch is a search match on the screen.
+//
+// Positions are relative to the top of the screen, positions in the
+// scrollback buffer have negative Y coordinates. Both ends are inclusive so a
+// match can be used as a [Selection].
+type SearchMatch struct {
+	Start, End uv.Position
+}
+
+// search is an active search on a screen.
+type search struct {
+	re *regexp.Regexp
+	// cur is the current match, if any.
+	cur *SearchMatch
+}
+
+// searchLine is a logical line made of soft-wrapped rows.
+type searchLine struct {
+	text string
+	// offsets are the byte offsets of the cells in text and cells are their
+	// positions, widths, and hyperlinks.
+	offsets []int
+	cells   []uv.Position
+	widths  []int
+	links   []uv.Link
+}
+
+// Search starts a search for the given pattern over the scrollback buffer
+// and the active screen. It returns an error if the pattern is not a valid
+// regular expression.
+//
+// The search keeps going as new output arrives, use [Emulator.FindNext] and
+// [Emulator.FindPrev] to move between matches and [Emulator.SearchMatches] to
+// get the matches to highlight.
+func (e *Emulator) Search(pattern string, opts SearchOptions) error {
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	e.scr.search = &search{re: re}
+	return nil
+}
+
+// ClearSearch clears the active search.
+func (e *Emulator) ClearSearch() {
+	e.scr.search = nil
+}
+
+// SearchMatches returns the matches of the active search that intersect the
+// rows from top to bottom inclusive, in reading order. Negative rows are in
+// the scrollback buffer.
+func (e *Emulator) SearchMatches(top, bottom int) []SearchMatch {
+	s := e.scr
+	if s.search == nil {
+		return nil
+	}
+	top = max(top, -s.scrollback.Len())
+	bottom = min(bottom, s.buf.Height()-1)
+	var matches []SearchMatch
+	for y := s.lineStart(top); y <= bottom; {
+		line, next := s.searchLine(y)
+		for _, m := range line.matches(s.search.re) {
+			if m.End.Y >= top && m.Start.Y <= bottom {
+				matches = append(matches, m)
+			}
+		}
+		y = next
+	}
+	return matches
+}
+
+// FindNext moves to the next match of the active search in reading order and
+// returns it. It starts from the oldest match and wraps around. It returns
+// false if there are no matches.
+func (e *Emulator) FindNext() (SearchMatch, bool) {
+	s := e.scr
+	if s.search == nil {
+		return SearchMatch{}, false
+	}
+	top, height := -s.scrollback.Len(), s.buf.Height()
+
+	from := top
+	if s.search.cur != nil {
+		from = s.lineStart(s.search.cur.Start.Y)
+	}
+	for _, wrap := range []bool{false, true} {
+		for y := from; y < height; {
+			line, next := s.searchLine(y)
+			for _, m := range line.matches(s.search.re) {
+				if wrap || s.search.cur == nil || posBefore(s.search.cur.Start, m.Start) {
+					s.search.cur = &m
+					return m, true
+				}
+			}
+			y = next
+		}
+		from = top
+	}
+	return SearchMatch{}, false
+}
+
+// FindPrev moves to the previous match of the active search in reading order
+// and returns it. It starts from the newest match and wraps around. It
+// returns false if there are no matches.
+func (e *Emulator) FindPrev() (SearchMatch, bool) {
+	s := e.scr
+	if s.search == nil {
+		return SearchMatch{}, false
+	}
+	top, height := -s.scrollback.Len(), s.buf.Height()
+
+	from := s.lineStart(height - 1)
+	if s.search.cur != nil {
+		from = s.lineStart(s.search.cur.Start.Y)
+	}
+	for _, wrap := range []bool{false, true} {
+		for y := from; y >= top; y = s.lineStart(y - 1) {
+			line, _ := s.searchLine(y)
+			matches := line.matches(s.search.re)
+			for i := len(matches) - 1; i >= 0; i-- {
+				m := matches[i]
+				if wrap || s.search.cur == nil || posBefore(m.Start, s.search.cur.Start) {
+					s.search.cur = &m
+					return m, true
+				}
+			}
+			if y == top {
+				break
+			}
+		}
+		from = s.lineStart(height - 1)
+	}
+	return SearchMatch{}, false
+}
+
+// searchMarks returns pointers to the positions of the current search match.
+func (s *Screen) searchMarks() []*uv.Position {
+	if s.search == nil || s.search.cur == nil {
+		return nil
+	}
+	return []*uv.Position{&s.search.cur.Start, &s.search.cur.End}
+}
+
+// lineStart returns the first row of the logical line containing the given
+// row.
+func (s *Screen) lineStart(y int) int {
+	top := -s.scrollback.Len()
+	for y > top {
+		if _, wrapped := s.lineAt(y - 1); !wrapped {
+			break
+		}
+		y--
+	}
+	return y
+}
+
+// searchLine returns the logical line starting at the given row and the row
+// following it.
+func (s *Screen) searchLine(y int) (searchLine, int) {
+	var line searchLine
+	var b strings.Builder
+	for ; y < s.buf.Height(); y++ {
+		l, wrapped := s.lineAt(y)
+		for x, c := range l {
+			if c.Content == "" {
+				// Skip wide character placeholders.
+				continue
+			}
+			line.offsets = append(line.offsets, b.Len())
+			line.cells = append(line.cells, uv.Pos(x, y))
+			line.widths = append(line.widths, max(1, c.Width))
+			line.links = append(line.links, c.Link)
+			b.WriteString(c.Content)
+		}
+		if !wrapped {
+			break
+		}
+	}
+	line.text = b.String()
+	return line, y + 1
+}
+
+// matches returns the non-empty matches of the given regular expression in
+// the line.
+func (l searchLine) matches(re *regexp.Regexp) []SearchMatch {
+	var matches []SearchMatch
+	for _, loc := range re.FindAllStringIndex(l.text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		matches = append(matches, l.span(loc[0], loc[1]))
+	}
+	return matches
+}
+
+// cellIndex returns the index of the cell containing the byte at the given
+// offset of the text.
+func (l searchLine) cellIndex(offset int) int {
+	return sort.SearchInts(l.offsets, offset+1) - 1
+}
+
+// span returns the cells spanned by the non-empty text between the start and
+// end byte offsets.
+func (l searchLine) span(start, end int) SearchMatch {
+	i, j := l.cellIndex(start), l.cellIndex(end-1)
+	last := l.cells[j]
+	last.X += l.widths[j] - 1
+	return SearchMatch{Start: l.cells[i], End: last}
+}
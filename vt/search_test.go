@@ -0,0 +1,112 @@
+package vt
+
+import (
+	"reflect"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+func TestSearch(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		pattern string
+		opts    SearchOptions
+		want    []SearchMatch
+	}{
+		{
+			name:    "literal",
+			input:   "a.b axb\r\na.b",
+			pattern: "a.b",
+			want: []SearchMatch{
+				{Start: uv.Pos(0, 0), End: uv.Pos(2, 0)},
+				{Start: uv.Pos(0, 1), End: uv.Pos(2, 1)},
+			},
+		},
+		{
+			name:    "regex",
+			input:   "a.b axb",
+			pattern: "a.b",
+			opts:    SearchOptions{Regex: true},
+			want: []SearchMatch{
+				{Start: uv.Pos(0, 0), End: uv.Pos(2, 0)},
+				{Start: uv.Pos(4, 0), End: uv.Pos(6, 0)},
+			},
+		},
+		{
+			name:    "ignore case",
+			input:   "Foo foo",
+			pattern: "FOO",
+			opts:    SearchOptions{IgnoreCase: true},
+			want: []SearchMatch{
+				{Start: uv.Pos(0, 0), End: uv.Pos(2, 0)},
+				{Start: uv.Pos(4, 0), End: uv.Pos(6, 0)},
+			},
+		},
+		{
+			name:    "soft wrap",
+			input:   "xxxxxxxhello",
+			pattern: "hello",
+			want:    []SearchMatch{{Start: uv.Pos(7, 0), End: uv.Pos(1, 1)}},
+		},
+		{
+			name:    "wide",
+			input:   "a世界b",
+			pattern: "界b",
+			want:    []SearchMatch{{Start: uv.Pos(3, 0), End: uv.Pos(5, 0)}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(10, 4)
+			_, _ = e.WriteString(tc.input)
+			if err := e.Search(tc.pattern, tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := e.SearchMatches(0, 3); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("matches = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchInvalid(t *testing.T) {
+	e := NewEmulator(10, 4)
+	if err := e.Search("(", SearchOptions{Regex: true}); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
+
+func TestFindNextPrev(t *testing.T) {
+	e := NewEmulator(10, 3)
+	e.SetScrollbackSize(10)
+	_, _ = e.WriteString("foo 1\r\nbar\r\nfoo 2\r\nfoo 3")
+	if err := e.Search("foo", SearchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(fn func() (SearchMatch, bool), want uv.Position) {
+		t.Helper()
+		m, ok := fn()
+		if !ok || m.Start != want {
+			t.Errorf("match = %v, %v, want start %v", m, ok, want)
+		}
+	}
+
+	find(e.FindPrev, uv.Pos(0, 2))
+	find(e.FindPrev, uv.Pos(0, 1))
+	find(e.FindPrev, uv.Pos(0, -1))
+	find(e.FindPrev, uv.Pos(0, 2)) // wraps around
+	find(e.FindNext, uv.Pos(0, -1))
+	find(e.FindNext, uv.Pos(0, 1))
+
+	// New output moves the current match and adds new matches.
+	_, _ = e.WriteString("\r\nfoo 4")
+	if m := e.SearchMatches(-10, 10); len(m) != 4 {
+		t.Errorf("expected 4 matches, got %v", m)
+	}
+	find(e.FindNext, uv.Pos(0, 1))
+	find(e.FindNext, uv.Pos(0, 2))
+}
@@ -0,0 +1,380 @@
+package vt
+
+import (
+	"strings"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// SelectionMode is the mode of a text selection.
+type SelectionMode uint8
+
+// Selection modes.
+const (
+	// SelectChar selects the characters between the two ends.
+	SelectChar SelectionMode = iota
+	// SelectWord selects the characters between the two ends expanded to
+	// word boundaries.
+	SelectWord
+	// SelectLine selects the lines between the two ends.
+	SelectLine
+	// SelectRect selects the rectangle spanning the two ends.
+	SelectRect
+)
+
+// wordDelimiters are the characters that delimit words in word selections.
+const wordDelimiters = " \t\"'`()[]{}<>|,;"
+
+// Selection is a text selection on the screen.
+//
+// Positions are relative to the top of the screen, positions in the
+// scrollback buffer have negative Y coordinates. Start is the position where
+// the selection started and End is where it ends, End may come before Start.
+// Both ends are inclusive.
+type Selection struct {
+	Mode       SelectionMode
+	Start, End uv.Position
+}
+
+// SetSelection sets the text selection of the active screen. The positions
+// are clamped to the screen and scrollback buffer.
+func (e *Emulator) SetSelection(sel Selection) {
+	s := e.scr
+	top := -s.scrollback.Len()
+	clamp := func(p uv.Position) uv.Position {
+		return uv.Pos(
+			max(0, min(p.X, s.buf.Width()-1)),
+			max(top, min(p.Y, s.buf.Height()-1)),
+		)
+	}
+	sel.Start, sel.End = clamp(sel.Start), clamp(sel.End)
+	s.selection = &sel
+}
+
+// Selection returns the text selection of the active screen and whether
+// there is one.
+func (e *Emulator) Selection() (Selection, bool) {
+	if e.scr.selection == nil {
+		return Selection{}, false
+	}
+	return *e.scr.selection, true
+}
+
+// ClearSelection clears the text selection of the active screen.
+func (e *Emulator) ClearSelection() {
+	e.scr.selection = nil
+}
+
+// IsSelected reports whether the cell at the given position is selected.
+func (e *Emulator) IsSelected(x, y int) bool {
+	s := e.scr
+	if s.selection == nil {
+		return false
+	}
+	start, end := s.selectionBounds()
+	p := uv.Pos(x, y)
+	if s.selection.Mode == SelectRect {
+		return p.In(uv.Rectangle{Min: start, Max: end.Add(uv.Pos(1, 1))})
+	}
+	return !posBefore(p, start) && !posBefore(end, p)
+}
+
+// SelectedText returns the selected text as plain text. Soft-wrapped lines
+// are joined and trailing spaces are trimmed.
+func (e *Emulator) SelectedText() string {
+	return e.scr.selectedText(false)
+}
+
+// SelectedStyledText returns the selected text with ANSI escape sequences for
+// the cell styles and hyperlinks.
+func (e *Emulator) SelectedStyledText() string {
+	return e.scr.selectedText(true)
+}
+
+// selectedText returns the selected text, optionally styled.
+func (s *Screen) selectedText(styled bool) string {
+	if s.selection == nil {
+		return ""
+	}
+	start, end := s.selectionBounds()
+	var lines [][]uv.Cell
+	if s.selection.Mode == SelectRect {
+		for y := start.Y; y <= end.Y; y++ {
+			lines = append(lines, s.cellRange(uv.Pos(start.X, y), uv.Pos(end.X+1, y))...)
+		}
+	} else {
+		lines = s.cellRange(start, uv.Pos(end.X+1, end.Y))
+	}
+	return cellsText(lines, styled)
+}
+
+// selectionBounds returns the ordered and inclusive bounds of the selection
+// expanded according to the selection mode.
+func (s *Screen) selectionBounds() (start, end uv.Position) {
+	sel := s.selection
+	start, end = sel.Start, sel.End
+	switch sel.Mode {
+	case SelectRect:
+		return uv.Pos(min(start.X, end.X), min(start.Y, end.Y)),
+			uv.Pos(max(start.X, end.X), max(start.Y, end.Y))
+	}
+
+	if posBefore(end, start) {
+		start, end = end, start
+	}
+	switch sel.Mode {
+	case SelectWord:
+		if s.isWordCell(start) {
+			for p, ok := s.prevPos(start); ok && s.isWordCell(p); p, ok = s.prevPos(p) {
+				start = p
+			}
+		}
+		if s.isWordCell(end) {
+			for p, ok := s.nextPos(end); ok && s.isWordCell(p); p, ok = s.nextPos(p) {
+				end = p
+			}
+		}
+	case SelectLine:
+		for start.Y > -s.scrollback.Len() {
+			if _, wrapped := s.lineAt(start.Y - 1); !wrapped {
+				break
+			}
+			start.Y--
+		}
+		for end.Y < s.buf.Height()-1 {
+			if _, wrapped := s.lineAt(end.Y); !wrapped {
+				break
+			}
+			end.Y++
+		}
+		start.X, end.X = 0, s.buf.Width()-1
+	}
+
+	// Don't start in the middle of a wide character.
+	for start.X > 0 && s.isPlaceholder(start) {
+		start.X--
+	}
+	return start, end
+}
+
+// posBefore reports whether the position a comes before b in reading order.
+func posBefore(a, b uv.Position) bool {
+	return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+}
+
+// cellAtPos returns the cell at the given position including the scrollback
+// buffer, or nil if there's none.
+func (s *Screen) cellAtPos(p uv.Position) *uv.Cell {
+	l, _ := s.lineAt(p.Y)
+	if p.X < 0 || p.X >= len(l) {
+		return nil
+	}
+	return &l[p.X]
+}
+
+// isPlaceholder reports whether the cell at the given position is the
+// placeholder of a wide character.
+func (s *Screen) isPlaceholder(p uv.Position) bool {
+	c := s.cellAtPos(p)
+	return c != nil && c.Width == 0 && c.Content == ""
+}
+
+// isWordCell reports whether the cell at the given position is part of a
+// word.
+func (s *Screen) isWordCell(p uv.Position) bool {
+	c := s.cellAtPos(p)
+	if c == nil {
+		return false
+	}
+	if c.Content == "" {
+		return s.isPlaceholder(p)
+	}
+	return !strings.ContainsAny(c.Content, wordDelimiters)
+}
+
+// prevPos returns the position before the given one following soft-wrapped
+// lines.
+func (s *Screen) prevPos(p uv.Position) (uv.Position, bool) {
+	if p.X > 0 {
+		return uv.Pos(p.X-1, p.Y), true
+	}
+	if p.Y <= -s.scrollback.Len() {
+		return p, false
+	}
+	l, wrapped := s.lineAt(p.Y - 1)
+	if !wrapped || len(l) == 0 {
+		return p, false
+	}
+	return uv.Pos(len(l)-1, p.Y-1), true
+}
+
+// nextPos returns the position after the given one following soft-wrapped
+// lines.
+func (s *Screen) nextPos(p uv.Position) (uv.Position, bool) {
+	l, wrapped := s.lineAt(p.Y)
+	if p.X+1 < len(l) {
+		return uv.Pos(p.X+1, p.Y), true
+	}
+	if !wrapped || p.Y+1 >= s.buf.Height() {
+		return p, false
+	}
+	return uv.Pos(0, p.Y+1), true
+}
+
+// selectionMarks returns pointers to the positions of the selection.
+func (s *Screen) selectionMarks() []*uv.Position {
+	if s.selection == nil {
+		return nil
+	}
+	return []*uv.Position{&s.selection.Start, &s.selection.End}
+}
+
+// selectionRows returns the first and last rows of the selection.
+func (s *Screen) selectionRows() (int, int) {
+	sel := s.selection
+	return min(sel.Start.Y, sel.End.Y), max(sel.Start.Y, sel.End.Y)
+}
+
+// insertSelection moves the selection down after n lines are inserted at y
+// within the given scroll region. The selection is cleared when its content
+// is split or pushed out of the scroll region.
+func (s *Screen) insertSelection(y, n int, scroll uv.Rectangle) {
+	if s.selection == nil {
+		return
+	}
+	lo, hi := s.selectionRows()
+	if hi < y || lo >= scroll.Max.Y {
+		return
+	}
+	if lo >= y && hi+n < scroll.Max.Y && s.fullWidth(scroll) {
+		s.selection.Start.Y += n
+		s.selection.End.Y += n
+		return
+	}
+	s.selection = nil
+}
+
+// deleteSelection moves the selection up after n lines are deleted at y
+// within the given scroll region. When the deleted lines are saved to the
+// scrollback buffer, the selection moves along with them. Otherwise, the
+// selection is cleared when its content is deleted or split.
+func (s *Screen) deleteSelection(y, n int, scroll uv.Rectangle, scrollback bool) {
+	if s.selection == nil {
+		return
+	}
+	lo, hi := s.selectionRows()
+	if (hi < y && !scrollback) || lo >= scroll.Max.Y {
+		return
+	}
+	if scrollback && hi < scroll.Max.Y {
+		// The selection moves along with its lines unless they're split
+		// by the lines above the scroll region.
+		d := savedRow(lo, y, n, scroll) - lo
+		if savedRow(hi, y, n, scroll)-hi == d && (y == 0 || hi < y || lo >= y) {
+			s.selection.Start.Y += d
+			s.selection.End.Y += d
+			return
+		}
+	} else if lo >= y+n && hi < scroll.Max.Y && s.fullWidth(scroll) {
+		s.selection.Start.Y -= n
+		s.selection.End.Y -= n
+		return
+	}
+	s.selection = nil
+}
+
+// fullWidth reports whether the given scroll region spans the screen width.
+func (s *Screen) fullWidth(scroll uv.Rectangle) bool {
+	return scroll.Min.X == 0 && scroll.Max.X == s.buf.Width()
+}
+
+// lineAt returns the line at the given y position and whether it's
+// soft-wrapped. Negative y positions are lines in the scrollback buffer,
+// where -1 is the most recent one.
+func (s *Screen) lineAt(y int) (uv.Line, bool) {
+	if y < 0 {
+		i := s.scrollback.Len() + y
+		return s.scrollback.Line(i), s.scrollback.IsWrapped(i)
+	}
+	return s.buf.Line(y), s.IsWrapped(y)
+}
+
+// cellRange returns the cells between the start and end positions, excluding
+// the end position, as lines. Soft-wrapped lines are joined.
+func (s *Screen) cellRange(start, end uv.Position) [][]uv.Cell {
+	var lines [][]uv.Cell
+	var line []uv.Cell
+	for y := start.Y; y <= end.Y; y++ {
+		l, wrapped := s.lineAt(y)
+		x0, x1 := 0, len(l)
+		if y == start.Y {
+			x0 = min(start.X, x1)
+		}
+		if y == end.Y {
+			x1 = max(x0, min(end.X, x1))
+		}
+		line = append(line, l[x0:x1]...)
+		if wrapped && y != end.Y {
+			continue
+		}
+		lines = append(lines, line)
+		line = nil
+	}
+	return lines
+}
+
+// textRange returns the plain text between the start and end positions,
+// excluding the end position. Soft-wrapped lines are joined and trailing
+// spaces are trimmed from lines ending with a newline.
+func (s *Screen) textRange(start, end uv.Position) string {
+	return cellsText(s.cellRange(start, end), false)
+}
+
+// cellsText returns the text of the given lines of cells joined with
+// newlines. Trailing blank cells are trimmed. When styled is true, the text
+// includes ANSI escape sequences for the cell styles and hyperlinks.
+func cellsText(lines [][]uv.Cell, styled bool) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		writeCells(&b, line, styled, true)
+	}
+	return b.String()
+}
+
+// writeCells writes the content of the given cells to b. When styled is
+// true, it includes ANSI escape sequences for the cell styles and hyperlinks
+// and resets them at the end. When trim is true, trailing blank cells are
+// skipped.
+func writeCells(b *strings.Builder, line []uv.Cell, styled, trim bool) {
+	n := len(line)
+	for trim && n > 0 && line[n-1].Content == " " &&
+		(!styled || (line[n-1].Style.IsZero() && line[n-1].Link == uv.Link{})) {
+		n--
+	}
+
+	var pen uv.Style
+	var link uv.Link
+	for _, c := range line[:n] {
+		if styled {
+			if !c.Style.Equal(&pen) {
+				b.WriteString(c.Style.Diff(&pen))
+				pen = c.Style
+			}
+			if c.Link != link {
+				b.WriteString(ansi.SetHyperlink(c.Link.URL, c.Link.Params))
+				link = c.Link
+			}
+		}
+		b.WriteString(c.Content)
+	}
+	if !pen.IsZero() {
+		b.WriteString(ansi.ResetStyle)
+	}
+	if link != (uv.Link{}) {
+		b.WriteString(ansi.ResetHyperlink())
+	}
+}
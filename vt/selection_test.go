@@ -0,0 +1,134 @@
+package vt
+
+import (
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+func TestSelection(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		sel   Selection
+		want  string
+	}{
+		{
+			name:  "char",
+			input: "hello world\r\nfoo bar",
+			sel:   Selection{Mode: SelectChar, Start: uv.Pos(6, 0), End: uv.Pos(2, 1)},
+			want:  "world\nfoo",
+		},
+		{
+			name:  "char reversed",
+			input: "hello world",
+			sel:   Selection{Mode: SelectChar, Start: uv.Pos(4, 0), End: uv.Pos(1, 0)},
+			want:  "ello",
+		},
+		{
+			name:  "soft wrap",
+			input: "abcdefghijklmnopqrstuvwxyz",
+			sel:   Selection{Mode: SelectChar, Start: uv.Pos(10, 0), End: uv.Pos(3, 1)},
+			want:  "klmnopqrstuvwx",
+		},
+		{
+			name:  "word",
+			input: "foo bar-baz (qux)",
+			sel:   Selection{Mode: SelectWord, Start: uv.Pos(6, 0), End: uv.Pos(6, 0)},
+			want:  "bar-baz",
+		},
+		{
+			name:  "word across soft wrap",
+			input: "abc defghijklmnopqrstuvwxyz",
+			sel:   Selection{Mode: SelectWord, Start: uv.Pos(1, 1), End: uv.Pos(1, 1)},
+			want:  "defghijklmnopqrstuvwxyz",
+		},
+		{
+			name:  "line",
+			input: "first\r\nsecond line\r\nthird",
+			sel:   Selection{Mode: SelectLine, Start: uv.Pos(3, 1), End: uv.Pos(0, 2)},
+			want:  "second line\nthird",
+		},
+		{
+			name:  "rect",
+			input: "abcdef\r\nghijkl\r\nmnopqr",
+			sel:   Selection{Mode: SelectRect, Start: uv.Pos(3, 2), End: uv.Pos(1, 0)},
+			want:  "bcd\nhij\nnop",
+		},
+		{
+			name:  "wide",
+			input: "a世界b",
+			sel:   Selection{Mode: SelectChar, Start: uv.Pos(2, 0), End: uv.Pos(3, 0)},
+			want:  "世界",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(20, 5)
+			_, _ = e.WriteString(tc.input)
+			e.SetSelection(tc.sel)
+			if got := e.SelectedText(); got != tc.want {
+				t.Errorf("selected text = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelectionStyled(t *testing.T) {
+	e := NewEmulator(20, 5)
+	_, _ = e.WriteString("a \x1b[1;31mbold\x1b[m c")
+	e.SetSelection(Selection{Start: uv.Pos(0, 0), End: uv.Pos(19, 0)})
+	want := "a \x1b[31;1mbold\x1b[m c"
+	if got := e.SelectedStyledText(); got != want {
+		t.Errorf("styled text = %q, want %q", got, want)
+	}
+	if !e.IsSelected(5, 0) || e.IsSelected(0, 1) {
+		t.Errorf("unexpected selected cells")
+	}
+}
+
+func TestSelectionScroll(t *testing.T) {
+	e := NewEmulator(10, 3)
+	e.SetScrollbackSize(2)
+	_, _ = e.WriteString("one\r\ntwo\r\nthree")
+	e.SetSelection(Selection{Start: uv.Pos(0, 1), End: uv.Pos(2, 1)})
+
+	// Scrolling into the scrollback buffer moves the selection.
+	_, _ = e.WriteString("\r\nfour")
+	sel, ok := e.Selection()
+	if !ok || sel.Start != uv.Pos(0, 0) || sel.End != uv.Pos(2, 0) {
+		t.Fatalf("selection = %+v, %v, want moved up", sel, ok)
+	}
+	_, _ = e.WriteString("\r\nfive")
+	if got := e.SelectedText(); got != "two" {
+		t.Errorf("selected text = %q, want %q", got, "two")
+	}
+
+	// The selection is cleared once it scrolls out of the scrollback buffer.
+	_, _ = e.WriteString("\r\nsix\r\nseven")
+	if _, ok := e.Selection(); ok {
+		t.Errorf("expected selection to be cleared")
+	}
+
+	// Lines scrolled off the top of a scroll region are saved to the
+	// scrollback buffer along with the selection, the lines above the region
+	// stay in place.
+	e.SetSelection(Selection{Start: uv.Pos(0, 1), End: uv.Pos(2, 1)})
+	_, _ = e.WriteString("\x1b[2;3r\x1b[3;1H\n")
+	sel, ok = e.Selection()
+	if !ok || sel.Start != uv.Pos(0, -1) || sel.End != uv.Pos(2, -1) {
+		t.Fatalf("selection = %+v, %v, want moved to the scrollback", sel, ok)
+	}
+	if got := e.SelectedText(); got != "six" {
+		t.Errorf("selected text = %q, want %q", got, "six")
+	}
+
+	// Scrolling a region narrower than the screen doesn't save lines to the
+	// scrollback buffer and clears the selected lines.
+	e.SetSelection(Selection{Start: uv.Pos(0, 1), End: uv.Pos(2, 1)})
+	_, _ = e.WriteString("\x1b[?69h\x1b[1;5s\x1b[3;1H\n")
+	if _, ok := e.Selection(); ok {
+		t.Errorf("expected selection to be cleared")
+	}
+}
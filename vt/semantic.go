@@ -0,0 +1,236 @@
+package vt
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// commandStage is the stage of a shell command tracked using semantic prompt
+// sequences.
+type commandStage uint8
+
+// Command stages.
+const (
+	commandPrompt commandStage = iota
+	commandInput
+	commandOutput
+	commandFinished
+)
+
+// Command is a shell command tracked using semantic prompt sequences, also
+// known as FinalTerm or shell integration sequences (OSC 133).
+//
+// Positions are relative to the top of the screen, positions in the
+// scrollback buffer have negative Y coordinates.
+type Command struct {
+	// PromptStart is the position where the prompt starts.
+	PromptStart uv.Position
+	// InputStart is the position where the command input starts, i.e. the
+	// end of the prompt.
+	InputStart uv.Position
+	// OutputStart is the position where the command output starts.
+	OutputStart uv.Position
+	// OutputEnd is the position where the command output ends.
+	OutputEnd uv.Position
+	// ExitCode is the exit code of the command reported by the shell. It's
+	// only valid when the command has finished.
+	ExitCode int
+
+	stage commandStage
+}
+
+// Finished reports whether the command has finished.
+func (c Command) Finished() bool {
+	return c.stage == commandFinished
+}
+
+// handleSemanticPrompt handles OSC 133 semantic prompt sequences.
+//
+//	OSC 133 ; A ST          Prompt start
+//	OSC 133 ; B ST          Command input start
+//	OSC 133 ; C ST          Command output start
+//	OSC 133 ; D [; Ps] ST   Command finished with exit code Ps
+//
+// See: https://gitlab.freedesktop.org/Per_Bothner/specifications/blob/master/proposals/semantic-prompts.md
+func (e *Emulator) handleSemanticPrompt(cmd int, data []byte) {
+	parts := bytes.Split(data, []byte{';'})
+	if len(parts) < 2 || len(parts[1]) != 1 || cmd != 133 {
+		// Invalid, ignore
+		return
+	}
+
+	s := e.scr
+	x, y := s.CursorPosition()
+	if e.atPhantom {
+		// The mark belongs to the start of the next line.
+		x, y = 0, y+1
+	}
+	pos := uv.Pos(x, y)
+
+	var c *Command
+	if len(s.commands) > 0 {
+		c = &s.commands[len(s.commands)-1]
+	}
+
+	switch parts[1][0] {
+	case 'A':
+		if c != nil && c.stage == commandPrompt && c.PromptStart == pos {
+			// Repeated prompt start, e.g. when the prompt is redrawn.
+			return
+		}
+		s.commands = append(s.commands, Command{
+			PromptStart: pos,
+			InputStart:  pos,
+			OutputStart: pos,
+			OutputEnd:   pos,
+		})
+	case 'B':
+		if c == nil || c.stage >= commandOutput {
+			return
+		}
+		c.InputStart, c.OutputStart, c.OutputEnd = pos, pos, pos
+		c.stage = commandInput
+	case 'C':
+		if c == nil || c.stage >= commandOutput {
+			return
+		}
+		if c.stage == commandPrompt {
+			c.InputStart = pos
+		}
+		c.OutputStart, c.OutputEnd = pos, pos
+		c.stage = commandOutput
+	case 'D':
+		if c == nil || c.stage == commandFinished {
+			return
+		}
+		if c.stage < commandOutput {
+			// The command was cancelled or no command was entered.
+			c.OutputStart = pos
+		}
+		c.OutputEnd = pos
+		c.ExitCode = 0
+		if len(parts) > 2 { //nolint:mnd
+			c.ExitCode, _ = strconv.Atoi(string(parts[2]))
+		}
+		c.stage = commandFinished
+	}
+}
+
+// commandMarks returns pointers to the positions of the tracked commands.
+func (s *Screen) commandMarks() []*uv.Position {
+	marks := make([]*uv.Position, 0, len(s.commands)*4) //nolint:mnd
+	for i := range s.commands {
+		c := &s.commands[i]
+		marks = append(marks, &c.PromptStart, &c.InputStart, &c.OutputStart, &c.OutputEnd)
+	}
+	return marks
+}
+
+// marks returns pointers to the positions that move along with the text they
+// point to: the command marks and the search position.
+func (s *Screen) marks() []*uv.Position {
+	return append(s.commandMarks(), s.searchMarks()...)
+}
+
+// insertMarks moves the marks down after n lines are inserted at y within the
+// given scroll region.
+func (s *Screen) insertMarks(y, n int, scroll uv.Rectangle) {
+	for _, m := range s.marks() {
+		if m.Y >= y && m.Y < scroll.Max.Y {
+			m.Y = min(m.Y+n, scroll.Max.Y-1)
+		}
+	}
+}
+
+// deleteMarks moves the marks up after n lines are deleted at y within the
+// given scroll region. When the deleted lines are saved to the scrollback
+// buffer, the marks move along with them.
+func (s *Screen) deleteMarks(y, n int, scroll uv.Rectangle, scrollback bool) {
+	for _, m := range s.marks() {
+		switch {
+		case scrollback:
+			m.Y = savedRow(m.Y, y, n, scroll)
+		case m.Y >= y && m.Y < scroll.Max.Y:
+			m.Y -= n
+			if m.Y < y {
+				m.X, m.Y = 0, y
+			}
+		}
+	}
+}
+
+// pruneScrollback removes the images, commands, and selection that are no
+// longer in the screen or the scrollback buffer. The search position is
+// clamped to the top of the scrollback buffer.
+func (s *Screen) pruneScrollback() {
+	top := -s.scrollback.Len()
+	s.images = removeImages(s.images, func(p ImagePlacement) bool {
+		return p.Bounds.Max.Y <= top
+	})
+
+	n := 0
+	for _, c := range s.commands {
+		if c.stage == commandFinished && c.OutputEnd.Y < top {
+			continue
+		}
+		// Clamp the marks of partially removed commands.
+		for _, m := range []*uv.Position{&c.PromptStart, &c.InputStart, &c.OutputStart, &c.OutputEnd} {
+			if m.Y < top {
+				*m = uv.Pos(0, top)
+			}
+		}
+		s.commands[n] = c
+		n++
+	}
+	s.commands = s.commands[:n]
+
+	for _, m := range s.searchMarks() {
+		if m.Y < top {
+			*m = uv.Pos(0, top)
+		}
+	}
+
+	if s.selection != nil {
+		if lo, _ := s.selectionRows(); lo < top {
+			s.selection = nil
+		}
+	}
+}
+
+// ClearScrollback clears the scrollback buffer along with the images,
+// commands, and selection in it.
+func (s *Screen) ClearScrollback() {
+	if s.scrollback == nil {
+		return
+	}
+	s.scrollback.Clear()
+	s.pruneScrollback()
+}
+
+// Commands returns the shell commands tracked on the active screen using
+// semantic prompt sequences (OSC 133), oldest first.
+func (e *Emulator) Commands() []Command {
+	return append([]Command(nil), e.scr.commands...)
+}
+
+// CommandOutput returns the output text of the command at the given index as
+// returned by [Emulator.Commands]. The output of a running command ends at
+// the cursor position.
+func (e *Emulator) CommandOutput(i int) (string, bool) {
+	if i < 0 || i >= len(e.scr.commands) {
+		return "", false
+	}
+	c := e.scr.commands[i]
+	if c.stage < commandOutput {
+		return "", true
+	}
+	end := c.OutputEnd
+	if c.stage != commandFinished {
+		end = e.CursorPosition()
+	}
+	// Output usually ends with a newline before the next prompt.
+	return strings.TrimSuffix(e.scr.textRange(c.OutputStart, end), "\n"), true
+}
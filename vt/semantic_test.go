@@ -0,0 +1,90 @@
+package vt
+
+import (
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestSemanticPrompt(t *testing.T) {
+	e := NewEmulator(10, 4)
+	e.SetScrollbackSize(10)
+
+	prompt := func(cmd, output, code string) {
+		_, _ = e.WriteString(ansi.FinalTermPrompt() + "$ " + ansi.FinalTermCmdStart() + cmd + "\r\n")
+		_, _ = e.WriteString(ansi.FinalTermCmdExecuted() + output + ansi.FinalTermCmdFinished(code))
+	}
+
+	prompt("ls", "a b\r\nc\r\n", "0")
+	cmds := e.Commands()
+	if len(cmds) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(cmds))
+	}
+	c := cmds[0]
+	if c.PromptStart != uv.Pos(0, 0) || c.InputStart != uv.Pos(2, 0) ||
+		c.OutputStart != uv.Pos(0, 1) || c.OutputEnd != uv.Pos(0, 3) {
+		t.Errorf("unexpected command positions %+v", c)
+	}
+	if !c.Finished() || c.ExitCode != 0 {
+		t.Errorf("expected finished command with exit code 0, got %+v", c)
+	}
+	if out, _ := e.CommandOutput(0); out != "a b\nc" {
+		t.Errorf("output = %q, want %q", out, "a b\nc")
+	}
+
+	// The marks move into the scrollback buffer along with the lines.
+	prompt("false", "", "1")
+	_, _ = e.WriteString(ansi.FinalTermPrompt() + "$ " + ansi.FinalTermCmdStart() + "cat\r\n" +
+		ansi.FinalTermCmdExecuted() + "running")
+	cmds = e.Commands()
+	if len(cmds) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(cmds))
+	}
+	if c := cmds[0]; c.PromptStart != uv.Pos(0, -2) || c.OutputEnd != uv.Pos(0, 1) {
+		t.Errorf("unexpected scrolled command positions %+v", c)
+	}
+	if out, _ := e.CommandOutput(0); out != "a b\nc" {
+		t.Errorf("scrolled output = %q, want %q", out, "a b\nc")
+	}
+	if c := cmds[1]; !c.Finished() || c.ExitCode != 1 {
+		t.Errorf("expected exit code 1, got %+v", c)
+	}
+	if c := cmds[2]; c.Finished() {
+		t.Errorf("expected running command, got %+v", c)
+	}
+	if out, _ := e.CommandOutput(2); out != "running" {
+		t.Errorf("running output = %q, want %q", out, "running")
+	}
+
+	// Clearing the scrollback drops the commands in it and clamps the ones
+	// partially in it.
+	_, _ = e.WriteString("\r\n\r\n" + ansi.EraseDisplay(3))
+	cmds = e.Commands()
+	if len(cmds) != 2 {
+		t.Fatalf("expected 2 commands after clearing the scrollback, got %d", len(cmds))
+	}
+	if c := cmds[0]; c.ExitCode != 1 || c.PromptStart != uv.Pos(0, 0) {
+		t.Errorf("unexpected clamped command %+v", c)
+	}
+}
+
+func TestSemanticPromptReflow(t *testing.T) {
+	e := NewEmulator(6, 4)
+	e.SetScrollbackSize(10)
+	_, _ = e.WriteString(ansi.FinalTermPrompt() + "$ " + ansi.FinalTermCmdStart() + "echo\r\n" +
+		ansi.FinalTermCmdExecuted() + "abcdefgh\r\n" + ansi.FinalTermCmdFinished("0"))
+
+	e.Resize(3, 4)
+	if out, _ := e.CommandOutput(0); out != "abcdefgh" {
+		t.Errorf("output = %q, want %q", out, "abcdefgh")
+	}
+	e.Resize(10, 4)
+	if out, _ := e.CommandOutput(0); out != "abcdefgh" {
+		t.Errorf("output = %q, want %q", out, "abcdefgh")
+	}
+	// The top of the screen is kept, leaving the prompt in the scrollback.
+	if c := e.Commands()[0]; c.InputStart != uv.Pos(2, -1) {
+		t.Errorf("input start = %v, want %v", c.InputStart, uv.Pos(2, -1))
+	}
+}
@@ -0,0 +1,138 @@
+package vt
+
+import (
+	"bytes"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/ansi/sixel"
+)
+
+const (
+	// ModeSixelDisplay is the Sixel Display Mode (DECSDM). When set, sixel
+	// scrolling is disabled, images are drawn at the top-left corner of the
+	// screen and the cursor doesn't move.
+	//
+	//	CSI ? 80 h
+	//	CSI ? 80 l
+	ModeSixelDisplay = ansi.DECMode(80)
+
+	// ModeSixelCursorRight leaves the cursor to the right of the last row of
+	// a sixel image instead of moving it to the line below the image.
+	//
+	//	CSI ? 8452 h
+	//	CSI ? 8452 l
+	ModeSixelCursorRight = ansi.DECMode(8452)
+)
+
+// handleSixel decodes a Sixel image and places it on the screen.
+//
+// Like xterm, when sixel scrolling is enabled, the image is placed at the
+// cursor position scrolling the screen if the image doesn't fit, and the
+// cursor is moved to the line below the image.
+func (e *Emulator) handleSixel(data []byte) {
+	if w, h := sixelSize(data); w > 0 && h > maxSixelPixels/w {
+		e.logf("sixel image too large: %dx%d", w, h)
+		return
+	}
+
+	var dec sixel.Decoder
+	img, err := dec.Decode(bytes.NewReader(data))
+	if err != nil {
+		e.logf("invalid sixel image: %v", err)
+		return
+	}
+
+	size := img.Bounds().Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return
+	}
+
+	cw, ch := e.CellSize()
+	cols, rows := (size.X+cw-1)/cw, (size.Y+ch-1)/ch
+
+	if e.isModeSet(ModeSixelDisplay) {
+		e.scr.addImage(ImagePlacement{Image: img, Bounds: uv.Rect(0, 0, cols, rows)})
+		return
+	}
+
+	x, y := e.scr.CursorPosition()
+	e.scr.addImage(ImagePlacement{Image: img, Bounds: uv.Rect(x, y, cols, rows)})
+
+	// Move the cursor to the last row of the image scrolling the image along
+	// with the text if it doesn't fit the screen.
+	for range rows - 1 {
+		e.index()
+	}
+	if e.isModeSet(ModeSixelCursorRight) {
+		e.scr.setCursorX(x+cols, false)
+	} else {
+		e.index()
+		e.scr.setCursorX(x, false)
+	}
+	e.atPhantom = false
+}
+
+// maxSixelPixels is the maximum number of pixels of a Sixel image. Larger
+// images are dropped without being decoded.
+const maxSixelPixels = 4096 * 4096
+
+// sixelSize returns the size in pixels of a Sixel image, the largest of the
+// size given by its raster attributes and the size covered by its pixel data.
+// Numbers are clamped to maxSixelPixels so that huge values can't overflow.
+func sixelSize(data []byte) (width, height int) {
+	number := func(i int) (int, int) {
+		n := 0
+		for ; i < len(data) && data[i] >= '0' && data[i] <= '9'; i++ {
+			n = min(n*10+int(data[i]-'0'), maxSixelPixels)
+		}
+		return n, i
+	}
+
+	i := 0
+	if len(data) > 0 && data[0] == sixel.RasterAttribute {
+		// "Pan;Pad;Ph;Pv
+		var params [4]int
+		for k := range params {
+			params[k], i = number(i + 1)
+			if i >= len(data) || data[i] != ';' {
+				break
+			}
+		}
+		width, height = params[2], params[3]
+	}
+
+	var x, bands int
+	newBand := true
+	for i < len(data) {
+		count := 1
+		b := data[i]
+		switch b {
+		case sixel.LineBreak:
+			x, newBand = 0, true
+			i++
+			continue
+		case sixel.CarriageReturn:
+			x = 0
+			i++
+			continue
+		case sixel.RepeatIntroducer:
+			count, i = number(i + 1)
+			if i >= len(data) {
+				continue
+			}
+			b = data[i]
+		}
+		i++
+		if b < '?' || b > '~' {
+			continue
+		}
+		x = min(x+count, maxSixelPixels)
+		if newBand {
+			newBand = false
+			bands = min(bands+1, maxSixelPixels)
+		}
+		width = max(width, x)
+	}
+	return width, max(height, bands*6) //nolint:mnd
+}
@@ -0,0 +1,865 @@
+package vt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// stateVersion is the version of the encoded emulator state.
+const stateVersion = 1
+
+// ErrStateVersion is returned when decoding an emulator state with an
+// unsupported version.
+var ErrStateVersion = errors.New("vt: unsupported state version")
+
+// state is the encoded emulator state.
+type state struct {
+	Version int           `json:"version"`
+	Styles  []styleState  `json:"styles,omitempty"`
+	Links   []uv.Link     `json:"links,omitempty"`
+	Screens []screenState `json:"screens"`
+	Alt     bool          `json:"alt,omitempty"`
+
+	Modes    []modeState `json:"modes"`
+	Charsets [4]string   `json:"charsets"`
+	GL       int         `json:"gl"`
+	GR       int         `json:"gr"`
+	GSingle  int         `json:"gsingle,omitempty"`
+	Tabstops []int       `json:"tabstops"`
+
+	Palette map[int]string `json:"palette,omitempty"`
+	Special [5]string      `json:"special"`
+	Fg      string         `json:"fg,omitempty"`
+	Bg      string         `json:"bg,omitempty"`
+	Cursor  string         `json:"cursor,omitempty"`
+
+	Title    string `json:"title,omitempty"`
+	IconName string `json:"iconName,omitempty"`
+	Cwd      string `json:"cwd,omitempty"`
+
+	ModifyOtherKeys int  `json:"modifyOtherKeys,omitempty"`
+	AtPhantom       bool `json:"atPhantom,omitempty"`
+	LastChar        rune `json:"lastChar,omitempty"`
+}
+
+// screenState is the encoded state of a screen.
+type screenState struct {
+	Width         int          `json:"width"`
+	Height        int          `json:"height"`
+	Lines         []lineState  `json:"lines"`
+	Scrollback    []lineState  `json:"scrollback,omitempty"`
+	ScrollbackMax int          `json:"scrollbackMax"`
+	Cursor        cursorState  `json:"cursor"`
+	Saved         cursorState  `json:"saved"`
+	Scroll        uv.Rectangle `json:"scroll"`
+	KittyFlags    []int        `json:"kittyFlags,omitempty"`
+}
+
+// cursorState is the encoded state of a cursor.
+type cursorState struct {
+	X       int         `json:"x"`
+	Y       int         `json:"y"`
+	Pen     int         `json:"pen,omitempty"`
+	Link    int         `json:"link,omitempty"`
+	Style   CursorStyle `json:"style,omitempty"`
+	Steady  bool        `json:"steady,omitempty"`
+	Hidden  bool        `json:"hidden,omitempty"`
+	Protect bool        `json:"protect,omitempty"`
+}
+
+// lineState is the encoded state of a line as runs of cells sharing the
+// same style and hyperlink.
+type lineState struct {
+	Runs    []runState `json:"r,omitempty"`
+	Wrapped bool       `json:"w,omitempty"`
+	Size    LineSize   `json:"s,omitempty"`
+	// Protected are the cells protected from selective erase as pairs of
+	// start and end columns.
+	Protected []int `json:"p,omitempty"`
+}
+
+// runState is the encoded state of a run of cells. Style and Link are
+// 1-based indices in the state tables, zero means none. Widths is only set
+// when a cell width differs from 1 for non-empty cells and 0 for empty ones.
+type runState struct {
+	Style  int      `json:"s,omitempty"`
+	Link   int      `json:"l,omitempty"`
+	Cells  []string `json:"c"`
+	Widths []int    `json:"w,omitempty"`
+}
+
+// styleState is the encoded state of a cell style.
+type styleState struct {
+	Fg        string       `json:"fg,omitempty"`
+	Bg        string       `json:"bg,omitempty"`
+	Ul        string       `json:"ul,omitempty"`
+	Underline uv.Underline `json:"u,omitempty"`
+	Attrs     uint8        `json:"a,omitempty"`
+}
+
+// modeState is the encoded state of a terminal mode.
+type modeState struct {
+	Mode    int              `json:"mode"`
+	DEC     bool             `json:"dec,omitempty"`
+	Setting ansi.ModeSetting `json:"setting"`
+}
+
+// stateEncoder builds the style and hyperlink tables of a state.
+type stateEncoder struct {
+	st     *state
+	styles map[styleState]int
+	links  map[uv.Link]int
+}
+
+func (enc *stateEncoder) style(s uv.Style) int {
+	if s.IsZero() {
+		return 0
+	}
+	ss := styleState{
+		Fg:        encodeColor(s.Fg),
+		Bg:        encodeColor(s.Bg),
+		Ul:        encodeColor(s.UnderlineColor),
+		Underline: s.Underline,
+		Attrs:     s.Attrs,
+	}
+	i, ok := enc.styles[ss]
+	if !ok {
+		enc.st.Styles = append(enc.st.Styles, ss)
+		i = len(enc.st.Styles)
+		enc.styles[ss] = i
+	}
+	return i
+}
+
+func (enc *stateEncoder) link(l uv.Link) int {
+	if l == (uv.Link{}) {
+		return 0
+	}
+	i, ok := enc.links[l]
+	if !ok {
+		enc.st.Links = append(enc.st.Links, l)
+		i = len(enc.st.Links)
+		enc.links[l] = i
+	}
+	return i
+}
+
+func (enc *stateEncoder) line(l uv.Line, wrapped bool) lineState {
+	ls := lineState{Wrapped: wrapped}
+	for _, c := range l {
+		style, link := enc.style(c.Style), enc.link(c.Link)
+		if n := len(ls.Runs); n == 0 || ls.Runs[n-1].Style != style || ls.Runs[n-1].Link != link {
+			ls.Runs = append(ls.Runs, runState{Style: style, Link: link})
+		}
+		r := &ls.Runs[len(ls.Runs)-1]
+		if c.Width != defaultCellWidth(c.Content) && r.Widths == nil {
+			r.Widths = make([]int, len(r.Cells))
+			for i, content := range r.Cells {
+				r.Widths[i] = defaultCellWidth(content)
+			}
+		}
+		r.Cells = append(r.Cells, c.Content)
+		if r.Widths != nil {
+			r.Widths = append(r.Widths, c.Width)
+		}
+	}
+	return ls
+}
+
+func (enc *stateEncoder) cursor(c Cursor) cursorState {
+	return cursorState{
+		X:       c.X,
+		Y:       c.Y,
+		Pen:     enc.style(c.Pen),
+		Link:    enc.link(c.Link),
+		Style:   c.Style,
+		Steady:  c.Steady,
+		Hidden:  c.Hidden,
+		Protect: c.protected,
+	}
+}
+
+// defaultCellWidth returns the width of a cell assumed by the encoded state
+// for the given content.
+func defaultCellWidth(content string) int {
+	if content == "" {
+		return 0
+	}
+	return 1
+}
+
+// MarshalBinary encodes the emulator state, including both screens, the
+// scrollback buffer, the cursors, modes, character sets, tab stops, colors,
+// title, working directory, hyperlinks, and protected cells. It implements
+// [encoding.BinaryMarshaler].
+//
+// Images, tracked commands, the selection, and the search are not encoded.
+func (e *Emulator) MarshalBinary() ([]byte, error) {
+	st := state{
+		Version:         stateVersion,
+		Alt:             e.scr == &e.scrs[1],
+		GL:              e.gl,
+		GR:              e.gr,
+		GSingle:         e.gsingle,
+		Fg:              encodeColor(e.fgColor),
+		Bg:              encodeColor(e.bgColor),
+		Cursor:          encodeColor(e.curColor),
+		Title:           e.title,
+		IconName:        e.iconName,
+		Cwd:             e.cwd,
+		ModifyOtherKeys: e.modifyOtherKeys,
+		AtPhantom:       e.atPhantom,
+		LastChar:        e.lastChar,
+	}
+	enc := stateEncoder{st: &st, styles: map[styleState]int{}, links: map[uv.Link]int{}}
+
+	for i := range e.scrs {
+		s := &e.scrs[i]
+		ss := screenState{
+			Width:      s.buf.Width(),
+			Height:     s.buf.Height(),
+			Cursor:     enc.cursor(s.cur),
+			Saved:      enc.cursor(s.saved),
+			Scroll:     s.scroll,
+			KittyFlags: s.kittyFlags,
+		}
+		if sb := s.scrollback; sb != nil {
+			ss.ScrollbackMax = sb.MaxLines()
+			for j := range sb.Len() {
+				ss.Scrollback = append(ss.Scrollback, enc.line(sb.Line(j), sb.IsWrapped(j)))
+			}
+		}
+		for y := range s.buf.Height() {
+			ls := enc.line(s.buf.Line(y), s.IsWrapped(y))
+			ls.Size = s.LineSize(y)
+			if s.protected != nil {
+				ls.Protected = s.protected.ranges(y)
+			}
+			ss.Lines = append(ss.Lines, ls)
+		}
+		st.Screens = append(st.Screens, ss)
+	}
+
+	for mode, setting := range e.modes {
+		_, dec := mode.(ansi.DECMode)
+		st.Modes = append(st.Modes, modeState{Mode: mode.Mode(), DEC: dec, Setting: setting})
+	}
+	slices.SortFunc(st.Modes, func(a, b modeState) int {
+		if a.DEC != b.DEC {
+			if a.DEC {
+				return 1
+			}
+			return -1
+		}
+		return a.Mode - b.Mode
+	})
+
+	for i, cs := range e.charsets {
+		name, ok := charsetName(cs)
+		if !ok {
+			return nil, fmt.Errorf("vt: unknown character set in G%d", i)
+		}
+		st.Charsets[i] = name
+	}
+	for x := range e.tabstops.Width() {
+		if e.tabstops.IsStop(x) {
+			st.Tabstops = append(st.Tabstops, x)
+		}
+	}
+
+	for i, c := range e.colors {
+		if c != nil {
+			if st.Palette == nil {
+				st.Palette = map[int]string{}
+			}
+			st.Palette[i] = encodeColor(c)
+		}
+	}
+	for i, c := range e.specialColors {
+		st.Special[i] = encodeColor(c)
+	}
+
+	return json.Marshal(st) //nolint:wrapcheck
+}
+
+// UnmarshalBinary restores the emulator state encoded by
+// [Emulator.MarshalBinary]. The emulator is resized to the encoded screen
+// size. It implements [encoding.BinaryUnmarshaler].
+func (e *Emulator) UnmarshalBinary(data []byte) error {
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("vt: invalid state: %w", err)
+	}
+	if st.Version != stateVersion {
+		return fmt.Errorf("%w: %d", ErrStateVersion, st.Version)
+	}
+	if len(st.Screens) != len(e.scrs) {
+		return invalidState("expected %d screens, got %d", len(e.scrs), len(st.Screens))
+	}
+	if err := st.validate(len(data)); err != nil {
+		return err
+	}
+
+	styles := make([]uv.Style, len(st.Styles)+1)
+	for i, ss := range st.Styles {
+		styles[i+1] = uv.Style{
+			Fg:             decodeColor(ss.Fg),
+			Bg:             decodeColor(ss.Bg),
+			UnderlineColor: decodeColor(ss.Ul),
+			Underline:      ss.Underline,
+			Attrs:          ss.Attrs,
+		}
+	}
+	links := append([]uv.Link{{}}, st.Links...)
+	line := func(ls lineState) uv.Line {
+		var l uv.Line
+		for _, r := range ls.Runs {
+			for i, content := range r.Cells {
+				c := uv.Cell{Content: content, Width: defaultCellWidth(content), Style: styles[r.Style], Link: links[r.Link]}
+				if i < len(r.Widths) {
+					c.Width = r.Widths[i]
+				}
+				l = append(l, c)
+			}
+		}
+		return l
+	}
+	cursor := func(cs cursorState) Cursor {
+		return Cursor{
+			Position:  uv.Pos(cs.X, cs.Y),
+			Pen:       styles[cs.Pen],
+			Link:      links[cs.Link],
+			Style:     cs.Style,
+			Steady:    cs.Steady,
+			Hidden:    cs.Hidden,
+			protected: cs.Protect,
+		}
+	}
+
+	for i, ss := range st.Screens {
+		s := &e.scrs[i]
+		s.Reset()
+		s.Resize(ss.Width, ss.Height)
+		for y, ls := range ss.Lines {
+			for x, c := range line(ls) {
+				if c.Width == 0 && c.Content == "" {
+					// Wide character placeholders are set by the buffer.
+					continue
+				}
+				s.buf.SetCell(x, y, &c)
+			}
+			s.setWrapped(y, ls.Wrapped)
+			for j := 0; j < len(ls.Protected); j += 2 {
+				s.protect(uv.Rect(ls.Protected[j], y, ls.Protected[j+1]-ls.Protected[j], 1), true)
+			}
+			if ls.Size != LineSingle {
+				if s.lineSizes == nil {
+					s.lineSizes = make([]LineSize, ss.Height)
+				}
+				s.lineSizes[y] = ls.Size
+			}
+		}
+		sb := NewScrollback(ss.ScrollbackMax)
+		if s.scrollback != nil {
+			// Keep storing the oldest lines in the scrollback file.
+			sb.file = s.scrollback.file
+		}
+		s.scrollback = sb
+		lines := make([]uv.Line, len(ss.Scrollback))
+		wrapped := make([]bool, len(ss.Scrollback))
+		for j, ls := range ss.Scrollback {
+			lines[j], wrapped[j] = line(ls), ls.Wrapped
+		}
+		s.scrollback.setLines(lines, wrapped)
+		s.cur, s.saved = cursor(ss.Cursor), cursor(ss.Saved)
+		s.scroll = ss.Scroll
+		s.kittyFlags = s.kittyFlags[:0]
+		for _, flags := range ss.KittyFlags {
+			s.kittyFlags = append(s.kittyFlags, flags&ansi.KittyAllFlags)
+		}
+		s.buf.Touched = nil
+		s.touchArea(s.Bounds())
+	}
+	e.scr = &e.scrs[0]
+	if st.Alt {
+		e.scr = &e.scrs[1]
+	}
+
+	e.modes = defaultModes()
+	for _, m := range st.Modes {
+		if m.DEC {
+			e.modes[ansi.DECMode(m.Mode)] = m.Setting
+		} else {
+			e.modes[ansi.ANSIMode(m.Mode)] = m.Setting
+		}
+	}
+
+	for i, name := range st.Charsets {
+		e.charsets[i] = charsetByName(name)
+	}
+	e.gl, e.gr, e.gsingle = st.GL, st.GR, st.GSingle
+	e.tabstops = uv.DefaultTabStops(e.Width())
+	e.tabstops.Clear()
+	for _, x := range st.Tabstops {
+		e.tabstops.Set(x)
+	}
+
+	e.colors = [256]color.Color{}
+	for i, c := range st.Palette {
+		e.colors[i] = decodeColor(c)
+	}
+	for i, c := range st.Special {
+		e.specialColors[i] = decodeColor(c)
+	}
+	e.fgColor, e.bgColor, e.curColor = decodeColor(st.Fg), decodeColor(st.Bg), decodeColor(st.Cursor)
+
+	e.title, e.iconName, e.cwd = st.Title, st.IconName, st.Cwd
+	e.modifyOtherKeys = st.ModifyOtherKeys
+	e.atPhantom = st.AtPhantom
+	e.lastChar = st.LastChar
+	e.grapheme = e.grapheme[:0]
+	e.kitty.reset()
+
+	e.frame = nil
+	if e.isModeSet(ansi.ModeSynchronizedOutput) {
+		e.beginSync()
+	}
+
+	return nil
+}
+
+// minStateCells is the number of screen cells a decoded state can always
+// declare. States declaring larger screens must be at least one byte per
+// cell long, so that the memory allocated stays proportional to the input.
+const minStateCells = 1 << 18
+
+// invalidState returns an error describing an invalid encoded state.
+func invalidState(format string, args ...any) error {
+	return fmt.Errorf("vt: invalid state: "+format, args...)
+}
+
+// validate checks that the indices and positions of the decoded state are
+// within the bounds of its screens and tables. The size is the length of the
+// encoded state.
+func (st *state) validate(size int) error {
+	table := func(i, n int) bool { return i >= 0 && i <= n }
+	line := func(ls lineState, width int) error {
+		cells := 0
+		for _, r := range ls.Runs {
+			if !table(r.Style, len(st.Styles)) || !table(r.Link, len(st.Links)) {
+				return invalidState("invalid style %d or link %d", r.Style, r.Link)
+			}
+			if r.Widths != nil && len(r.Widths) != len(r.Cells) {
+				return invalidState("expected %d cell widths, got %d", len(r.Cells), len(r.Widths))
+			}
+			for _, w := range r.Widths {
+				if w < 0 || w > 2 { //nolint:mnd
+					return invalidState("invalid cell width %d", w)
+				}
+			}
+			cells += len(r.Cells)
+		}
+		if width > 0 && cells > width {
+			return invalidState("line of %d cells wider than the screen", cells)
+		}
+		if ls.Size > LineDoubleHeightBottom {
+			return invalidState("invalid line size %d", ls.Size)
+		}
+		if len(ls.Protected)%2 != 0 {
+			return invalidState("odd number of protected columns")
+		}
+		for j := 0; j < len(ls.Protected); j += 2 {
+			start, end := ls.Protected[j], ls.Protected[j+1]
+			if start < 0 || start >= end || end > width || (j > 0 && start <= ls.Protected[j-1]) {
+				return invalidState("invalid protected columns %d-%d", start, end)
+			}
+		}
+		return nil
+	}
+	cursor := func(cs cursorState, bounds uv.Rectangle) error {
+		if !uv.Pos(cs.X, cs.Y).In(bounds) {
+			return invalidState("cursor position %d,%d out of bounds", cs.X, cs.Y)
+		}
+		if !table(cs.Pen, len(st.Styles)) || !table(cs.Link, len(st.Links)) {
+			return invalidState("invalid cursor style %d or link %d", cs.Pen, cs.Link)
+		}
+		if cs.Style < CursorBlock || cs.Style > CursorBar {
+			return invalidState("invalid cursor shape %d", cs.Style)
+		}
+		return nil
+	}
+
+	cells := 0
+	for i, ss := range st.Screens {
+		if ss.Width <= 0 || ss.Height <= 0 || ss.Height > max(minStateCells, size)/ss.Width {
+			return invalidState("invalid screen size %dx%d", ss.Width, ss.Height)
+		}
+		if i > 0 && (ss.Width != st.Screens[0].Width || ss.Height != st.Screens[0].Height) {
+			return invalidState("screens of different sizes %dx%d and %dx%d",
+				st.Screens[0].Width, st.Screens[0].Height, ss.Width, ss.Height)
+		}
+		if cells += ss.Width * ss.Height; cells > max(minStateCells, size) {
+			return invalidState("%d screen cells in a state of %d bytes", cells, size)
+		}
+		bounds := uv.Rect(0, 0, ss.Width, ss.Height)
+		if len(ss.Lines) > ss.Height {
+			return invalidState("%d lines on a screen of height %d", len(ss.Lines), ss.Height)
+		}
+		for _, ls := range ss.Lines {
+			if err := line(ls, ss.Width); err != nil {
+				return err
+			}
+		}
+		if ss.ScrollbackMax < 0 {
+			return invalidState("invalid scrollback size %d", ss.ScrollbackMax)
+		}
+		for _, ls := range ss.Scrollback {
+			if err := line(ls, 0); err != nil {
+				return err
+			}
+		}
+		if err := cursor(ss.Cursor, bounds); err != nil {
+			return err
+		}
+		if err := cursor(ss.Saved, bounds); err != nil {
+			return err
+		}
+		if ss.Scroll.Empty() || !ss.Scroll.In(bounds) {
+			return invalidState("invalid scroll region %v", ss.Scroll)
+		}
+		if len(ss.KittyFlags) > maxKittyKeyboardStack {
+			return invalidState("%d Kitty keyboard flags entries", len(ss.KittyFlags))
+		}
+	}
+
+	for _, name := range st.Charsets {
+		if name != "" && charsetByName(name) == nil {
+			return invalidState("unknown character set %q", name)
+		}
+	}
+	if st.GL < 0 || st.GL >= len(st.Charsets) || st.GR < 0 || st.GR >= len(st.Charsets) {
+		return invalidState("invalid character set invocation GL %d, GR %d", st.GL, st.GR)
+	}
+	if st.GSingle != 0 && st.GSingle != 2 && st.GSingle != 3 { //nolint:mnd
+		return invalidState("invalid single shift %d", st.GSingle)
+	}
+
+	width := st.Screens[0].Width
+	if st.Alt {
+		width = st.Screens[1].Width
+	}
+	for _, x := range st.Tabstops {
+		if x < 0 || x >= width {
+			return invalidState("tab stop %d out of bounds", x)
+		}
+	}
+	for i := range st.Palette {
+		if i < 0 || i > 255 { //nolint:mnd
+			return invalidState("invalid palette index %d", i)
+		}
+	}
+	return nil
+}
+
+// ReplayANSI returns an ANSI byte stream that repaints a fresh terminal of
+// the same size to match the emulator state. The scrollback buffer is written
+// before the main screen so it ends up in the terminal scrollback.
+func (e *Emulator) ReplayANSI() []byte {
+	var b strings.Builder
+	main, alt := &e.scrs[0], &e.scrs[1]
+	defaults := defaultModes()
+
+	b.WriteString(ansi.ResetStyle + ansi.CursorHomePosition + ansi.EraseEntireScreen)
+
+	// Colors.
+	for i, c := range e.colors {
+		if c != nil {
+			fmt.Fprintf(&b, "\x1b]4;%d;%s\x07", i, ansi.XRGBColor{Color: c})
+		}
+	}
+	for i, c := range e.specialColors {
+		if c != nil {
+			fmt.Fprintf(&b, "\x1b]5;%d;%s\x07", i, ansi.XRGBColor{Color: c})
+		}
+	}
+	if e.fgColor != nil {
+		b.WriteString(ansi.SetForegroundColor(ansi.XRGBColor{Color: e.fgColor}.String()))
+	}
+	if e.bgColor != nil {
+		b.WriteString(ansi.SetBackgroundColor(ansi.XRGBColor{Color: e.bgColor}.String()))
+	}
+	if e.curColor != nil {
+		b.WriteString(ansi.SetCursorColor(ansi.XRGBColor{Color: e.curColor}.String()))
+	}
+
+	// The scrollback buffer and the main screen.
+	var rows []uv.Line
+	var wrapped []bool
+	if sb := main.scrollback; sb != nil {
+		for i := range sb.Len() {
+			rows, wrapped = append(rows, sb.Line(i)), append(wrapped, sb.IsWrapped(i))
+		}
+	}
+	for y := range main.buf.Height() {
+		rows, wrapped = append(rows, main.buf.Line(y)), append(wrapped, main.IsWrapped(y))
+	}
+	for i, row := range rows {
+		writeCells(&b, row, true, !wrapped[i])
+		if !wrapped[i] && i < len(rows)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	for y := range main.buf.Height() {
+		if size := main.LineSize(y); size != LineSingle {
+			b.WriteString(ansi.CursorPosition(1, y+1) + lineSizeSequence(size))
+		}
+	}
+
+	// The alternate screen.
+	if e.scr == alt {
+		switch {
+		case e.modes[ansi.ModeAltScreenSaveCursor].IsSet():
+			b.WriteString(ansi.SetModeAltScreenSaveCursor)
+		default:
+			b.WriteString(ansi.SetModeAltScreen)
+		}
+		for y := range alt.buf.Height() {
+			b.WriteString(ansi.CursorPosition(1, y+1))
+			if size := alt.LineSize(y); size != LineSingle {
+				b.WriteString(lineSizeSequence(size))
+			}
+			writeCells(&b, alt.buf.Line(y), true, true)
+		}
+	}
+
+	// Modes.
+	var modes []ansi.Mode
+	for mode, setting := range e.modes {
+		switch mode {
+		case ansi.ModeAltScreen, ansi.ModeSaveCursor, ansi.ModeAltScreenSaveCursor:
+			continue
+		}
+		if setting.IsSet() != defaults[mode].IsSet() {
+			modes = append(modes, mode)
+		}
+	}
+	slices.SortFunc(modes, func(a, b ansi.Mode) int { return a.Mode() - b.Mode() })
+	for _, mode := range modes {
+		if e.modes[mode].IsSet() {
+			b.WriteString(ansi.SetMode(mode))
+		} else {
+			b.WriteString(ansi.ResetMode(mode))
+		}
+	}
+
+	// Margins.
+	scr := e.scr
+	scroll := scr.scroll
+	if scroll.Min.Y != 0 || scroll.Max.Y != scr.Height() {
+		b.WriteString(ansi.SetTopBottomMargins(scroll.Min.Y+1, scroll.Max.Y))
+	}
+	if scroll.Min.X != 0 || scroll.Max.X != scr.Width() {
+		b.WriteString(ansi.SetLeftRightMargins(scroll.Min.X+1, scroll.Max.X))
+	}
+
+	// Tab stops.
+	if !slices.Equal(tabStopList(e.tabstops), tabStopList(uv.DefaultTabStops(e.Width()))) {
+		b.WriteString(ansi.TabClear(3)) //nolint:mnd
+		for _, x := range tabStopList(e.tabstops) {
+			b.WriteString(ansi.CursorPosition(x+1, 1) + "\x1bH") // HTS
+		}
+	}
+
+	// Character sets.
+	for i, cs := range e.charsets {
+		if cs != nil {
+			b.WriteString(ansi.SelectCharacterSet(" ()*+"[i+1], charsetDesignator(cs)))
+		}
+	}
+	switch e.gl {
+	case 1:
+		b.WriteByte(ansi.SO)
+	case 2: //nolint:mnd
+		b.WriteString(ansi.LS2)
+	case 3: //nolint:mnd
+		b.WriteString(ansi.LS3)
+	}
+	switch e.gr {
+	case 2: //nolint:mnd
+		b.WriteString(ansi.LS2R)
+	case 3: //nolint:mnd
+		b.WriteString(ansi.LS3R)
+	}
+
+	// Title, icon name, and working directory.
+	if e.iconName != "" {
+		b.WriteString(ansi.SetIconName(e.iconName))
+	}
+	if e.title != "" {
+		b.WriteString(ansi.SetWindowTitle(e.title))
+	}
+	if e.cwd != "" {
+		b.WriteString("\x1b]7;" + e.cwd + "\x07")
+	}
+
+	// Keyboard.
+	for _, flags := range scr.kittyFlags {
+		b.WriteString(ansi.PushKittyKeyboard(flags))
+	}
+	if e.modifyOtherKeys != 0 {
+		b.WriteString(ansi.KeyModifierOptions(4, e.modifyOtherKeys)) //nolint:mnd
+	}
+
+	// Cursors.
+	cup := func(x, y int) string {
+		if e.isModeSet(ansi.ModeOrigin) {
+			x, y = x-scroll.Min.X, y-scroll.Min.Y
+		}
+		return ansi.CursorPosition(x+1, y+1)
+	}
+	b.WriteString(cup(scr.saved.X, scr.saved.Y) + scr.saved.Pen.String() + ansi.SaveCursor)
+	b.WriteString(ansi.ResetStyle)
+
+	x, y := scr.cur.X, scr.cur.Y
+	if e.atPhantom {
+		// Write the last cell again to restore the pending wrap state.
+		px := x
+		for px > 0 && scr.buf.CellAt(px, y) != nil && scr.buf.CellAt(px, y).Width == 0 {
+			px--
+		}
+		if c := scr.buf.CellAt(px, y); c != nil {
+			b.WriteString(cup(px, y))
+			writeCells(&b, []uv.Cell{*c}, true, false)
+		}
+	} else {
+		b.WriteString(cup(x, y))
+	}
+	if !scr.cur.Pen.IsZero() {
+		b.WriteString(scr.cur.Pen.String())
+	}
+	if scr.cur.Link != (uv.Link{}) {
+		b.WriteString(ansi.SetHyperlink(scr.cur.Link.URL, scr.cur.Link.Params))
+	}
+	if scr.cur.Style != CursorBlock || scr.cur.Steady {
+		n := int(scr.cur.Style)*2 + 1 //nolint:mnd
+		if scr.cur.Steady {
+			n++
+		}
+		b.WriteString(ansi.SetCursorStyle(n))
+	}
+
+	return []byte(b.String())
+}
+
+// tabStopList returns the columns of the given tab stops.
+func tabStopList(ts *uv.TabStops) []int {
+	var stops []int
+	for x := range ts.Width() {
+		if ts.IsStop(x) {
+			stops = append(stops, x)
+		}
+	}
+	return stops
+}
+
+// charsetName returns the name of the given character set in the encoded
+// state. It returns false if the character set is unknown.
+func charsetName(cs CharSet) (string, bool) {
+	switch {
+	case cs == nil:
+		return "", true
+	case sameCharset(cs, UK):
+		return "uk", true
+	case sameCharset(cs, SpecialDrawing):
+		return "special", true
+	}
+	return "", false
+}
+
+// charsetByName returns the character set with the given name in the encoded
+// state.
+func charsetByName(name string) CharSet {
+	switch name {
+	case "uk":
+		return UK
+	case "special":
+		return SpecialDrawing
+	}
+	return nil
+}
+
+// charsetDesignator returns the final byte designating the given character
+// set.
+func charsetDesignator(cs CharSet) byte {
+	if sameCharset(cs, UK) {
+		return 'A'
+	}
+	if sameCharset(cs, SpecialDrawing) {
+		return '0'
+	}
+	return 'B'
+}
+
+// sameCharset reports whether both character sets are the same.
+func sameCharset(a, b CharSet) bool {
+	return maps.Equal(a, b)
+}
+
+// encodeColor encodes a color in the encoded state.
+func encodeColor(c color.Color) string {
+	switch c := c.(type) {
+	case nil:
+		return ""
+	case ansi.BasicColor:
+		return "b" + strconv.Itoa(int(c))
+	case ansi.IndexedColor:
+		return "i" + strconv.Itoa(int(c))
+	default:
+		r, g, b, a := c.RGBA()
+		return fmt.Sprintf("#%02x%02x%02x%02x", r>>8, g>>8, b>>8, a>>8)
+	}
+}
+
+// decodeColor decodes a color from the encoded state.
+func decodeColor(s string) color.Color {
+	switch {
+	case s == "":
+		return nil
+	case s[0] == 'b':
+		n, _ := strconv.Atoi(s[1:])
+		return ansi.BasicColor(n) //nolint:gosec
+	case s[0] == 'i':
+		n, _ := strconv.Atoi(s[1:])
+		return ansi.IndexedColor(n) //nolint:gosec
+	case s[0] == '#' && len(s) == 9:
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil {
+			return nil
+		}
+		return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)} //nolint:gosec
+	}
+	return nil
+}
+
+// lineSizeSequence returns the escape sequence that sets the given line size.
+func lineSizeSequence(size LineSize) string {
+	switch size {
+	case LineDoubleHeightTop:
+		return "\x1b#3"
+	case LineDoubleHeightBottom:
+		return "\x1b#4"
+	case LineDoubleWidth:
+		return "\x1b#6"
+	default:
+		return "\x1b#5"
+	}
+}
@@ -0,0 +1,247 @@
+package vt
+
+import (
+	"encoding/json"
+	"errors"
+	"image/color"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// stateInput exercises most of the emulator state.
+const stateInput = "\x1b]2;title\x07\x1b]7;file:///tmp\x07\x1b]4;1;#ff0000\x07" +
+	"\x1b]8;;https://example.com\x07link\x1b]8;;\x07 \x1b[1;38;2;1;2;3mbold\x1b[m 世界\r\n" +
+	"a long line that wraps\r\n" +
+	"\x1b[?2004h\x1b[?1h\x1b(0qqq\x1b(B\x1b[3g\x1b[1;5H\x1bH" +
+	"\x1b[2;4r\x1b[5 q\x1b[4;3H\x1b7\x1b[31m\x1b[3;2Hx"
+
+func TestStateRoundTrip(t *testing.T) {
+	e := NewEmulator(10, 5)
+	e.SetScrollbackSize(100)
+	_, _ = e.WriteString("scrolled\r\n\r\n\r\n\r\n\r\n" + stateInput)
+	_, _ = e.WriteString("\x1b[?1049h\x1b[1;1Halt")
+
+	data, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := NewEmulator(2, 2)
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Width() != 10 || got.Height() != 5 {
+		t.Errorf("size = %dx%d, want 10x5", got.Width(), got.Height())
+	}
+	if !got.IsAltScreen() || got.Render() != e.Render() {
+		t.Errorf("alt screen = %q, want %q", got.Render(), e.Render())
+	}
+	_, _ = got.WriteString("\x1b[?1049l")
+	_, _ = e.WriteString("\x1b[?1049l")
+	if got.Render() != e.Render() {
+		t.Errorf("main screen = %q, want %q", got.Render(), e.Render())
+	}
+	if got.ScrollbackLen() != e.ScrollbackLen() {
+		t.Fatalf("scrollback len = %d, want %d", got.ScrollbackLen(), e.ScrollbackLen())
+	}
+	for i := range e.ScrollbackLen() {
+		if a, b := got.Scrollback().Lines()[i].Render(), e.Scrollback().Lines()[i].Render(); a != b {
+			t.Errorf("scrollback line %d = %q, want %q", i, a, b)
+		}
+	}
+	if got.CursorPosition() != e.CursorPosition() {
+		t.Errorf("cursor = %v, want %v", got.CursorPosition(), e.CursorPosition())
+	}
+	if got.scr.cur.Pen.Fg != e.scr.cur.Pen.Fg || got.scr.cur.Style != CursorBar || got.scr.saved.Position != e.scr.saved.Position {
+		t.Errorf("cursor state = %+v, want %+v", got.scr.cur, e.scr.cur)
+	}
+	if got.scr.ScrollRegion() != uv.Rect(0, 1, 10, 3) {
+		t.Errorf("scroll region = %v", got.scr.ScrollRegion())
+	}
+	if !got.isModeSet(ansi.ModeBracketedPaste) || !got.isModeSet(ansi.ModeCursorKeys) {
+		t.Errorf("expected modes to be restored")
+	}
+	if got.title != "title" || got.cwd != "file:///tmp" {
+		t.Errorf("title = %q, cwd = %q", got.title, got.cwd)
+	}
+	if !colorEqual(got.IndexedColor(1), color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("palette color = %v", got.IndexedColor(1))
+	}
+	if got.tabstops.IsStop(8) || !got.tabstops.IsStop(4) {
+		t.Errorf("tab stops were not restored")
+	}
+	if cell := got.CellAt(0, 0); cell == nil || cell.Content != "世" || cell.Width != 2 {
+		t.Errorf("unexpected cell %v", cell)
+	}
+}
+
+func TestStateVersion(t *testing.T) {
+	e := NewEmulator(10, 5)
+	if err := e.UnmarshalBinary([]byte(`{"version":999}`)); !errors.Is(err, ErrStateVersion) {
+		t.Errorf("err = %v, want %v", err, ErrStateVersion)
+	}
+}
+
+func TestStateInvalid(t *testing.T) {
+	src := NewEmulator(10, 5)
+	_, _ = src.WriteString(stateInput)
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		modify func(st map[string]any)
+	}{
+		{"gl", func(st map[string]any) { st["gl"] = 9 }},
+		{"gr", func(st map[string]any) { st["gr"] = -1 }},
+		{"single shift", func(st map[string]any) { st["gsingle"] = 1 }},
+		{"charset", func(st map[string]any) { st["charsets"] = []string{"bogus", "", "", ""} }},
+		{"tab stop", func(st map[string]any) { st["tabstops"] = []int{4, 50} }},
+		{"palette", func(st map[string]any) { st["palette"] = map[string]string{"300": "#ff0000ff"} }},
+		{"screen size", func(st map[string]any) { stateScreen(st)["width"] = 1 << 30 }},
+		{"large screens", func(st map[string]any) {
+			for _, ss := range st["screens"].([]any) {
+				ss.(map[string]any)["width"], ss.(map[string]any)["height"] = 4096, 4096
+			}
+		}},
+		{"different screen sizes", func(st map[string]any) {
+			st["screens"].([]any)[1].(map[string]any)["width"] = 20
+		}},
+		{"kitty flags", func(st map[string]any) { stateScreen(st)["kittyFlags"] = make([]int, maxKittyKeyboardStack+1) }},
+		{"cursor", func(st map[string]any) { stateCursor(st, "cursor")["x"] = 10 }},
+		{"saved cursor", func(st map[string]any) { stateCursor(st, "saved")["y"] = -1 }},
+		{"cursor pen", func(st map[string]any) { stateCursor(st, "cursor")["pen"] = 99 }},
+		{"cursor shape", func(st map[string]any) { stateCursor(st, "cursor")["style"] = 7 }},
+		{"scroll region", func(st map[string]any) {
+			stateScreen(st)["scroll"] = map[string]any{"Min": map[string]int{"X": 0, "Y": 2}, "Max": map[string]int{"X": 10, "Y": 9}}
+		}},
+		{"empty scroll region", func(st map[string]any) {
+			stateScreen(st)["scroll"] = map[string]any{"Min": map[string]int{"X": 0, "Y": 2}, "Max": map[string]int{"X": 10, "Y": 2}}
+		}},
+		{"too many lines", func(st map[string]any) {
+			lines := stateScreen(st)["lines"].([]any)
+			stateScreen(st)["lines"] = append(lines, lines...)
+		}},
+		{"wide line", func(st map[string]any) {
+			stateLine(st)["r"] = []any{map[string]any{"c": make([]string, 11)}}
+		}},
+		{"run style", func(st map[string]any) {
+			stateLine(st)["r"] = []any{map[string]any{"s": 99, "c": []string{"a"}}}
+		}},
+		{"run widths", func(st map[string]any) {
+			stateLine(st)["r"] = []any{map[string]any{"c": []string{"a", "b"}, "w": []int{1}}}
+		}},
+		{"cell width", func(st map[string]any) {
+			stateLine(st)["r"] = []any{map[string]any{"c": []string{"a"}, "w": []int{-3}}}
+		}},
+		{"line size", func(st map[string]any) { stateLine(st)["s"] = 9 }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var st map[string]any
+			if err := json.Unmarshal(data, &st); err != nil {
+				t.Fatal(err)
+			}
+			tc.modify(st)
+			bad, err := json.Marshal(st)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e := NewEmulator(10, 5)
+			_, _ = e.WriteString("before")
+			want := e.Render()
+			if err := e.UnmarshalBinary(bad); err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got := e.Render(); got != want {
+				t.Errorf("emulator changed to %q, want %q", got, want)
+			}
+			_, _ = e.WriteString("\x1bNx\x0ey")
+		})
+	}
+}
+
+func TestStateKittyFlags(t *testing.T) {
+	src := NewEmulator(10, 5)
+	_, _ = src.WriteString(ansi.PushKittyKeyboard(ansi.KittyAllFlags))
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st map[string]any
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatal(err)
+	}
+	stateScreen(st)["kittyFlags"] = []int{0xffff}
+	if data, err = json.Marshal(st); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEmulator(10, 5)
+	if err := e.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.scr.kittyKeyboardFlags(); got != ansi.KittyAllFlags {
+		t.Errorf("flags = %d, want %d", got, ansi.KittyAllFlags)
+	}
+}
+
+func TestStateUnknownCharset(t *testing.T) {
+	e := NewEmulator(10, 5)
+	e.charsets[1] = CharSet{'a': "x"}
+	if _, err := e.MarshalBinary(); err == nil {
+		t.Errorf("expected an error for an unknown character set")
+	}
+}
+
+// stateScreen returns the main screen of a decoded state.
+func stateScreen(st map[string]any) map[string]any {
+	return st["screens"].([]any)[0].(map[string]any)
+}
+
+// stateCursor returns the named cursor of the main screen of a decoded state.
+func stateCursor(st map[string]any, name string) map[string]any {
+	return stateScreen(st)[name].(map[string]any)
+}
+
+// stateLine returns the first line of the main screen of a decoded state.
+func stateLine(st map[string]any) map[string]any {
+	return stateScreen(st)["lines"].([]any)[0].(map[string]any)
+}
+
+func TestReplayANSI(t *testing.T) {
+	e := NewEmulator(10, 5)
+	_, _ = e.WriteString("scrolled\r\n\r\n\r\n\r\n\r\n" + stateInput)
+
+	got := NewEmulator(10, 5)
+	_, _ = got.Write(e.ReplayANSI())
+
+	if got.Render() != e.Render() {
+		t.Errorf("screen = %q, want %q", got.Render(), e.Render())
+	}
+	if got.ScrollbackLen() != e.ScrollbackLen() {
+		t.Errorf("scrollback len = %d, want %d", got.ScrollbackLen(), e.ScrollbackLen())
+	}
+	if got.CursorPosition() != e.CursorPosition() {
+		t.Errorf("cursor = %v, want %v", got.CursorPosition(), e.CursorPosition())
+	}
+	if got.scr.ScrollRegion() != e.scr.ScrollRegion() {
+		t.Errorf("scroll region = %v, want %v", got.scr.ScrollRegion(), e.scr.ScrollRegion())
+	}
+	if got.title != e.title || !got.isModeSet(ansi.ModeBracketedPaste) {
+		t.Errorf("expected title and modes to be replayed")
+	}
+
+	// Continue writing to both terminals, they should stay in sync.
+	_, _ = e.WriteString("yz\x1b8saved")
+	_, _ = got.WriteString("yz\x1b8saved")
+	if got.Render() != e.Render() {
+		t.Errorf("screen after writing = %q, want %q", got.Render(), e.Render())
+	}
+}
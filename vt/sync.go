@@ -0,0 +1,86 @@
+package vt
+
+import (
+	"slices"
+	"time"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// DefaultSyncTimeout is the default maximum duration of a synchronized
+// update, see [Emulator.SetSyncTimeout].
+const DefaultSyncTimeout = time.Second
+
+// syncFrame is the frame presented while a synchronized update is in
+// progress.
+type syncFrame struct {
+	// buf is a snapshot of the active screen when the update started.
+	buf *uv.Buffer
+	// lineSizes are the line sizes of the snapshot.
+	lineSizes []LineSize
+	// start is when the update started.
+	start time.Time
+}
+
+// SetSyncTimeout sets the maximum duration of a synchronized update started
+// with [ansi.ModeSynchronizedOutput]. Once it elapses, the emulator presents
+// the screen as is and the update ends with the next write. Non-positive
+// values reset the timeout to [DefaultSyncTimeout].
+func (e *Emulator) SetSyncTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultSyncTimeout
+	}
+	e.syncTimeout = d
+}
+
+// beginSync starts a synchronized update by taking a snapshot of the active
+// screen. Nested updates are ignored.
+func (e *Emulator) beginSync() {
+	if e.frame != nil {
+		return
+	}
+	e.frame = &syncFrame{
+		buf:       e.scr.buf.Clone(),
+		lineSizes: slices.Clone(e.scr.lineSizes),
+		start:     time.Now(),
+	}
+}
+
+// lineSize returns the size attribute of the line at the given y position in
+// the snapshot.
+func (f *syncFrame) lineSize(y int) LineSize {
+	if y < 0 || y >= len(f.lineSizes) {
+		return LineSingle
+	}
+	return f.lineSizes[y]
+}
+
+// endSync ends the synchronized update, if any, and reports the complete
+// frame.
+func (e *Emulator) endSync() {
+	if e.frame == nil {
+		return
+	}
+	e.frame = nil
+	if e.cb.FrameComplete != nil {
+		e.cb.FrameComplete()
+	}
+	emit(&e.events, FrameComplete{})
+}
+
+// expireSync ends the synchronized update if it's been running for longer
+// than the sync timeout.
+func (e *Emulator) expireSync() {
+	if e.frame != nil && !e.syncing() {
+		e.logf("synchronized update timed out")
+		e.setMode(ansi.ModeSynchronizedOutput, ansi.ModeReset)
+	}
+}
+
+// syncing reports whether a synchronized update is in progress and hasn't
+// timed out. While syncing, the damage and touched lines are held back and
+// [Emulator.Draw] draws the snapshot taken when the update started.
+func (e *Emulator) syncing() bool {
+	return e.frame != nil && time.Since(e.frame.start) < e.syncTimeout
+}
@@ -0,0 +1,63 @@
+package vt
+
+import (
+	"testing"
+	"time"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestSynchronizedOutput(t *testing.T) {
+	var frames int
+	e := NewEmulator(10, 2)
+	e.SetCallbacks(Callbacks{FrameComplete: func() { frames++ }})
+	_, _ = e.WriteString("old")
+	e.DrainDamage()
+
+	_, _ = e.WriteString(ansi.SetModeSynchronizedOutput + "\x1b[2J\x1b[Hnew")
+	if d := e.DrainDamage(); d != nil {
+		t.Errorf("damage = %v, want nil while syncing", d)
+	}
+	if e.Touched() != nil {
+		t.Errorf("expected no touched lines while syncing")
+	}
+	scr := uv.NewScreenBuffer(10, 2)
+	e.Draw(scr, scr.Bounds())
+	if got := scr.CellAt(0, 0).Content; got != "o" {
+		t.Errorf("drawn cell = %q, want the frame before the update", got)
+	}
+	if frames != 0 {
+		t.Errorf("frames = %d, want 0", frames)
+	}
+
+	_, _ = e.WriteString(ansi.ResetModeSynchronizedOutput)
+	if frames != 1 {
+		t.Errorf("frames = %d, want 1", frames)
+	}
+	if d := e.DrainDamage(); len(d) == 0 {
+		t.Errorf("expected the held back damage")
+	}
+	e.Draw(scr, scr.Bounds())
+	if got := scr.CellAt(0, 0).Content; got != "n" {
+		t.Errorf("drawn cell = %q, want the new frame", got)
+	}
+}
+
+func TestSynchronizedOutputTimeout(t *testing.T) {
+	var frames int
+	e := NewEmulator(10, 2)
+	e.SetCallbacks(Callbacks{FrameComplete: func() { frames++ }})
+	e.SetSyncTimeout(time.Millisecond)
+	_, _ = e.WriteString(ansi.SetModeSynchronizedOutput + "new")
+	time.Sleep(5 * time.Millisecond)
+
+	// The screen is presented as is once the timeout elapses.
+	if e.Touched() == nil {
+		t.Errorf("expected touched lines after the timeout")
+	}
+	_, _ = e.WriteString("!")
+	if frames != 1 || e.isModeSet(ansi.ModeSynchronizedOutput) {
+		t.Errorf("frames = %d, want the update to end after the timeout", frames)
+	}
+}
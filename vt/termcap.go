@@ -0,0 +1,68 @@
+package vt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// DefaultCapabilities returns the default terminal capabilities reported to
+// XTGETTCAP requests. Boolean capabilities have empty values.
+func DefaultCapabilities() map[string]string {
+	return map[string]string{
+		"TN":     "xterm-256color",
+		"name":   "xterm-256color",
+		"Co":     "256",
+		"colors": "256",
+		"RGB":    "8/8/8",
+		"Tc":     "",
+		"Su":     "",
+		"Smulx":  "\x1b[4:%p1%dm",
+		"Setulc": "\x1b[58:2::%p1%{65536}%/%d:%p1%{256}%/%{255}%&%d:%p1%{255}%&%dm",
+		"Ms":     "\x1b]52;%p1%s;%p2%s\x07",
+		"Ss":     "\x1b[%p1%d q",
+		"Se":     "\x1b[2 q",
+		"fsl":    "\x07",
+		"tsl":    "\x1b]2;",
+	}
+}
+
+// SetCapabilities sets the terminal capabilities reported to XTGETTCAP
+// requests. The keys are the termcap or terminfo capability names and the
+// values are the capability strings, boolean capabilities have empty values.
+// A nil map disables all capabilities.
+func (e *Emulator) SetCapabilities(caps map[string]string) {
+	e.caps = caps
+}
+
+// handleRequestTermcap handles an XTGETTCAP request by replying with the
+// values of the requested capabilities.
+//
+//	DCS + q Pt ST
+//
+// Where Pt is a list of hex encoded capability names separated by
+// semicolons. Each capability is reported as DCS 1 + r name = value ST where
+// name and value are hex encoded, or DCS 0 + r name ST when it's unknown.
+func (e *Emulator) handleRequestTermcap(data []byte) {
+	for _, p := range bytes.Split(data, []byte{';'}) {
+		name, err := hex.DecodeString(string(p))
+		if err != nil || len(name) == 0 {
+			e.logf("invalid XTGETTCAP request: %q", p)
+			_, _ = io.WriteString(e.pw, "\x1bP0+r"+string(p)+"\x1b\\")
+			continue
+		}
+
+		value, ok := e.caps[string(name)]
+		if !ok {
+			_, _ = io.WriteString(e.pw, "\x1bP0+r"+string(p)+"\x1b\\")
+			continue
+		}
+
+		report := strings.ToUpper(hex.EncodeToString(name))
+		if value != "" {
+			report += "=" + strings.ToUpper(hex.EncodeToString([]byte(value)))
+		}
+		_, _ = io.WriteString(e.pw, "\x1bP1+r"+report+"\x1b\\")
+	}
+}
@@ -1,8 +1,11 @@
 package vt
 
 import (
+	"image"
 	"image/color"
+	"image/draw"
 	"io"
+	"time"
 
 	uv "github.com/charmbracelet/ultraviolet"
 )
@@ -13,17 +16,34 @@ type Terminal interface {
 	Blur()
 	Bounds() uv.Rectangle
 	CellAt(x int, y int) *uv.Cell
+	CellSize() (width, height int)
 	ClearScrollback()
+	ClearSearch()
+	ClearSelection()
 	Close() error
+	CommandOutput(i int) (string, bool)
+	Commands() []Command
 	CursorColor() color.Color
 	CursorPosition() uv.Position
 	Draw(scr uv.Screen, area uv.Rectangle)
+	DrainDamage() []Damage
+	DrawImages(dst draw.Image, cellWidth, cellHeight int)
+	FindNext() (SearchMatch, bool)
+	FindPrev() (SearchMatch, bool)
 	Focus()
 	ForegroundColor() color.Color
 	Height() int
+	Images() []ImagePlacement
 	IndexedColor(i int) color.Color
 	InputPipe() io.Writer
 	IsAltScreen() bool
+	IsSelected(x, y int) bool
+	KittyImage(id int) (image.Image, bool)
+	KittyVirtualPlacements() []ImagePlacement
+	LineSize(y int) LineSize
+	LinkAt(x, y int) (LinkSpan, bool)
+	Links(top, bottom int) []LinkSpan
+	MarshalBinary() ([]byte, error)
 	Paste(text string)
 	Read(p []byte) (n int, err error)
 	RegisterApcHandler(handler ApcHandler)
@@ -34,27 +54,47 @@ type Terminal interface {
 	RegisterPmHandler(handler PmHandler)
 	RegisterSosHandler(handler SosHandler)
 	Render() string
+	ReplayANSI() []byte
 	Resize(width int, height int)
 	Scrollback() *Scrollback
 	ScrollbackCellAt(x, y int) *uv.Cell
 	ScrollbackLen() int
+	Search(pattern string, opts SearchOptions) error
+	SearchMatches(top, bottom int) []SearchMatch
+	SelectedStyledText() string
+	SelectedText() string
+	Selection() (Selection, bool)
 	SendKey(k uv.KeyEvent)
 	SendKeys(keys ...uv.KeyEvent)
 	SendMouse(m Mouse)
 	SendText(text string)
 	SetBackgroundColor(c color.Color)
 	SetCallbacks(cb Callbacks)
+	SetCapabilities(caps map[string]string)
 	SetCell(x int, y int, c *uv.Cell)
+	SetCellSize(width, height int)
+	SetClipboardPolicy(p ClipboardPolicy)
+	SetClipboardProvider(p ClipboardProvider)
 	SetCursorColor(c color.Color)
 	SetDefaultBackgroundColor(c color.Color)
 	SetDefaultCursorColor(c color.Color)
 	SetDefaultForegroundColor(c color.Color)
 	SetForegroundColor(c color.Color)
 	SetIndexedColor(i int, c color.Color)
+	SetKittyFileTransmission(enabled bool)
+	SetKittyImageLimit(limit int)
+	SetLinkDetection(enabled bool)
 	SetLogger(l Logger)
+	SetScrollbackFile(f ScrollbackFile, memLines int) error
 	SetScrollbackSize(maxLines int)
+	SetSelection(sel Selection)
+	SetSpecialColor(i int, c color.Color)
+	SetSyncTimeout(d time.Duration)
+	SpecialColor(i int) color.Color
 	String() string
+	Subscribe(size int) *Subscription
 	Touched() []*uv.LineData
+	UnmarshalBinary(data []byte) error
 	Width() int
 	WidthMethod() uv.WidthMethod
 	Write(p []byte) (n int, err error)
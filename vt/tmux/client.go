@@ -0,0 +1,674 @@
+// Package tmux provides a client of the tmux control mode.
+//
+// In control mode, started with "tmux -C" or "tmux -CC", tmux doesn't draw
+// the session itself. Instead, it reports the output of the panes and the
+// changes to the session as text notifications, and reads commands from its
+// input. The [Client] parses the notifications, feeds the output of each
+// pane into its own [vt.SafeEmulator], and keeps track of the window
+// layouts, so that hosts can draw tmux sessions natively.
+package tmux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/vt"
+)
+
+// Default size of the panes that appear before their window layout.
+const (
+	DefaultWidth  = 80
+	DefaultHeight = 24
+)
+
+// Separator cells drawn between the panes of a window.
+var (
+	VerticalBorder   = uv.Cell{Content: "│", Width: 1}
+	HorizontalBorder = uv.Cell{Content: "─", Width: 1}
+)
+
+// maxLineSize is the maximum size of a control mode line. Pane output is
+// sent in lines of limited size, but command replies can be long.
+const maxLineSize = 16 << 20
+
+// Limits of the panes that aren't part of a window layout.
+const (
+	// maxPendingPanes is the maximum number of panes with output that
+	// aren't part of a window layout yet. The output of more unknown panes
+	// is dropped.
+	maxPendingPanes = 16
+	// maxClosedPanes is the number of closed panes remembered to drop the
+	// output tmux sends for them before reporting they're gone.
+	maxClosedPanes = 64
+)
+
+// ErrUnknownPane is returned when sending input to a pane that doesn't
+// exist.
+var ErrUnknownPane = errors.New("tmux: unknown pane")
+
+// Reply is the reply to a command.
+type Reply struct {
+	// Output are the lines written by the command. When the command failed,
+	// they're the error message.
+	Output []string
+	// Err indicates if the command failed.
+	Err bool
+}
+
+// Window is a tmux window.
+type Window struct {
+	ID   int
+	Name string
+	// Layout is the layout of the window panes. It's nil until tmux reports
+	// it, see [Client.Refresh].
+	Layout *Layout
+	// Active is the identifier of the active pane of the window, or -1.
+	Active int
+}
+
+// Pane is a tmux pane backed by an emulator.
+type Pane struct {
+	id, window int
+	emu        *vt.SafeEmulator
+	area       uv.Rectangle
+}
+
+// ID returns the identifier of the pane.
+func (p *Pane) ID() int {
+	return p.id
+}
+
+// Emulator returns the emulator of the pane.
+func (p *Pane) Emulator() *vt.SafeEmulator {
+	return p.emu
+}
+
+// Callbacks are the callbacks of a [Client]. They're called from the
+// goroutine reading the control mode output.
+type Callbacks struct {
+	// Output callback. When set, this function is called after the output
+	// of a pane is written to its emulator.
+	Output func(pane int)
+
+	// WindowAdd callback. When set, this function is called when a window
+	// is added to the session.
+	WindowAdd func(window int)
+
+	// WindowClose callback. When set, this function is called when a window
+	// is closed.
+	WindowClose func(window int)
+
+	// WindowRenamed callback. When set, this function is called when a
+	// window is renamed.
+	WindowRenamed func(window int, name string)
+
+	// LayoutChange callback. When set, this function is called when the
+	// layout of a window changes.
+	LayoutChange func(window int)
+
+	// ActivePane callback. When set, this function is called when the
+	// active pane of a window changes.
+	ActivePane func(window, pane int)
+
+	// SessionChanged callback. When set, this function is called when the
+	// client is attached to another session.
+	SessionChanged func(session int, name string)
+
+	// WindowChanged callback. When set, this function is called when the
+	// current window of the session changes.
+	WindowChanged func(window int)
+
+	// Exit callback. When set, this function is called when tmux exits
+	// control mode. The reason is empty when the client detached normally.
+	Exit func(reason string)
+}
+
+// Client is a tmux control mode client. It's safe for concurrent use.
+type Client struct {
+	mu sync.Mutex
+	w  io.Writer
+	cb Callbacks
+
+	windows map[int]*Window
+	panes   map[int]*Pane
+	// closed are the identifiers of the last closed panes. tmux never
+	// reuses them.
+	closed []int
+	// window is the current window of the session, or -1.
+	window int
+
+	// replies are the handlers of the commands waiting for a reply, in the
+	// order the commands were sent.
+	replies []func(Reply)
+	// reply is the reply being read, if any, and own indicates if it's the
+	// reply to a command sent by this client.
+	reply *Reply
+	own   bool
+
+	exited bool
+	logger vt.Logger
+}
+
+// NewClient creates a new client that writes commands to w, usually the
+// input of the tmux process.
+func NewClient(w io.Writer) *Client {
+	return &Client{
+		w:       w,
+		windows: make(map[int]*Window),
+		panes:   make(map[int]*Pane),
+		window:  -1,
+	}
+}
+
+// SetCallbacks sets the callbacks of the client.
+func (c *Client) SetCallbacks(cb Callbacks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cb = cb
+}
+
+// SetLogger sets the logger of the client.
+func (c *Client) SetLogger(l vt.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = l
+}
+
+func (c *Client) logf(format string, v ...any) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+// Run reads the control mode output from r until tmux exits control mode or
+// r returns an error. It returns nil when tmux exits and
+// [io.ErrUnexpectedEOF] when the output ends before.
+func (c *Client) Run(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxLineSize)
+	for sc.Scan() {
+		c.HandleLine(sc.Text())
+		c.mu.Lock()
+		exited := c.exited
+		c.mu.Unlock()
+		if exited {
+			return nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("tmux: %w", err)
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// HandleLine handles a line of control mode output without the line ending.
+// The DCS sequence that wraps the output of "tmux -CC" is ignored.
+func (c *Client) HandleLine(line string) {
+	line = strings.TrimSuffix(line, "\r")
+	line = strings.TrimPrefix(line, "\x1bP1000p")
+	if line == "\x1b\\" {
+		return
+	}
+
+	c.mu.Lock()
+	var done []func()
+	defer func() {
+		c.mu.Unlock()
+		// Callbacks and reply handlers run without the lock so that they
+		// can use the client.
+		for _, fn := range done {
+			fn()
+		}
+	}()
+
+	if c.reply != nil {
+		name, _, _ := strings.Cut(line, " ")
+		if name != "%end" && name != "%error" {
+			c.reply.Output = append(c.reply.Output, line)
+			return
+		}
+		reply := *c.reply
+		reply.Err = name == "%error"
+		c.reply = nil
+		if c.own && len(c.replies) > 0 {
+			fn := c.replies[0]
+			c.replies = c.replies[1:]
+			if fn != nil {
+				done = append(done, func() { fn(reply) })
+			}
+		}
+		return
+	}
+
+	name, args, _ := strings.Cut(line, " ")
+	if fn := c.handleNotification(name, args); fn != nil {
+		done = append(done, fn)
+	}
+}
+
+// handleNotification handles a notification and returns the callback to
+// call, if any.
+func (c *Client) handleNotification(name, args string) func() {
+	cb := c.cb
+	switch name {
+	case "%begin":
+		// %begin time number flags
+		fields := strings.Fields(args)
+		c.reply = &Reply{}
+		c.own = len(fields) == 3 && fields[2] == "1"
+	case "%output":
+		// %output %pane data
+		id, data, _ := strings.Cut(args, " ")
+		return c.output(id, data)
+	case "%extended-output":
+		// %extended-output %pane age ... : data
+		id, rest, _ := strings.Cut(args, " ")
+		_, data, _ := strings.Cut(rest, ": ")
+		return c.output(id, data)
+	case "%layout-change":
+		// %layout-change @window layout visible-layout flags
+		fields := strings.Fields(args)
+		id, ok := parseID(fields, 0, '@')
+		if !ok || len(fields) < 2 { //nolint:mnd
+			break
+		}
+		if err := c.setLayout(id, fields[1]); err != nil {
+			c.logf("invalid layout of window @%d: %v", id, err)
+			break
+		}
+		if cb.LayoutChange != nil {
+			return func() { cb.LayoutChange(id) }
+		}
+	case "%window-add":
+		id, ok := parseID(strings.Fields(args), 0, '@')
+		if !ok {
+			break
+		}
+		c.addWindow(id)
+		if cb.WindowAdd != nil {
+			return func() { cb.WindowAdd(id) }
+		}
+	case "%window-close", "%unlinked-window-close":
+		id, ok := parseID(strings.Fields(args), 0, '@')
+		if !ok || c.windows[id] == nil {
+			break
+		}
+		c.closeWindow(id)
+		if cb.WindowClose != nil {
+			return func() { cb.WindowClose(id) }
+		}
+	case "%window-renamed":
+		// %window-renamed @window name
+		w, name, _ := strings.Cut(args, " ")
+		id, ok := parseID([]string{w}, 0, '@')
+		if !ok {
+			break
+		}
+		c.addWindow(id).Name = name
+		if cb.WindowRenamed != nil {
+			return func() { cb.WindowRenamed(id, name) }
+		}
+	case "%window-pane-changed":
+		// %window-pane-changed @window %pane
+		fields := strings.Fields(args)
+		id, ok1 := parseID(fields, 0, '@')
+		pane, ok2 := parseID(fields, 1, '%')
+		if !ok1 || !ok2 {
+			break
+		}
+		c.addWindow(id).Active = pane
+		if cb.ActivePane != nil {
+			return func() { cb.ActivePane(id, pane) }
+		}
+	case "%session-changed":
+		// %session-changed $session name
+		s, name, _ := strings.Cut(args, " ")
+		id, ok := parseID([]string{s}, 0, '$')
+		if ok && cb.SessionChanged != nil {
+			return func() { cb.SessionChanged(id, name) }
+		}
+	case "%session-window-changed":
+		// %session-window-changed $session @window
+		id, ok := parseID(strings.Fields(args), 1, '@')
+		if !ok {
+			break
+		}
+		c.window = id
+		if cb.WindowChanged != nil {
+			return func() { cb.WindowChanged(id) }
+		}
+	case "%exit":
+		c.exited = true
+		for _, p := range c.panes {
+			_ = p.emu.Close()
+		}
+		if cb.Exit != nil {
+			return func() { cb.Exit(args) }
+		}
+	default:
+		// Other notifications, such as %sessions-changed or
+		// %pane-mode-changed, are not needed to draw the session.
+	}
+	return nil
+}
+
+// output writes the escaped output of a pane to its emulator.
+func (c *Client) output(id, data string) func() {
+	pane, ok := parseID([]string{id}, 0, '%')
+	if !ok {
+		return nil
+	}
+	p, ok := c.panes[pane]
+	if !ok {
+		if slices.Contains(c.closed, pane) || c.pendingPanes() >= maxPendingPanes {
+			c.logf("dropping output of unknown pane %%%d", pane)
+			return nil
+		}
+		p = c.pane(pane, -1)
+	}
+	_, _ = p.emu.Write(unescape(data))
+	if cb := c.cb.Output; cb != nil {
+		return func() { cb(pane) }
+	}
+	return nil
+}
+
+// pane returns the pane with the given identifier, creating it if needed.
+// Negative window identifiers keep the window of existing panes.
+func (c *Client) pane(id, window int) *Pane {
+	p, ok := c.panes[id]
+	if !ok {
+		p = &Pane{id: id, window: window, emu: vt.NewSafeEmulator(DefaultWidth, DefaultHeight)}
+		// The emulator replies to the queries of the programs, but tmux
+		// already does. Drop them.
+		go io.Copy(io.Discard, p.emu) //nolint:errcheck
+		c.panes[id] = p
+	}
+	if window >= 0 {
+		p.window = window
+	}
+	return p
+}
+
+// pendingPanes returns the number of panes that aren't part of a window.
+func (c *Client) pendingPanes() int {
+	n := 0
+	for _, p := range c.panes {
+		if p.window < 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// closePane closes a pane and remembers it was closed.
+func (c *Client) closePane(id int) {
+	if p, ok := c.panes[id]; ok {
+		_ = p.emu.Close()
+		delete(c.panes, id)
+	}
+	c.closed = append(c.closed, id)
+	if len(c.closed) > maxClosedPanes {
+		c.closed = slices.Delete(c.closed, 0, len(c.closed)-maxClosedPanes)
+	}
+}
+
+// addWindow returns the window with the given identifier, creating it if
+// needed.
+func (c *Client) addWindow(id int) *Window {
+	w, ok := c.windows[id]
+	if !ok {
+		w = &Window{ID: id, Active: -1}
+		c.windows[id] = w
+	}
+	return w
+}
+
+// closeWindow removes a window and closes its panes.
+func (c *Client) closeWindow(id int) {
+	delete(c.windows, id)
+	for pid, p := range c.panes {
+		if p.window == id {
+			c.closePane(pid)
+		}
+	}
+}
+
+// setLayout sets the layout of a window. The panes of the layout are resized
+// to fit their cells and the panes that are no longer part of the window
+// are closed.
+func (c *Client) setLayout(id int, layout string) error {
+	l, err := ParseLayout(layout)
+	if err != nil {
+		return err
+	}
+	w := c.addWindow(id)
+	w.Layout = l
+
+	cells := l.Panes()
+	for _, cell := range cells {
+		p := c.pane(cell.Pane, id)
+		p.area = cell.Bounds()
+		if p.emu.Width() != cell.Width || p.emu.Height() != cell.Height {
+			p.emu.Resize(cell.Width, cell.Height)
+		}
+	}
+	for pid, p := range c.panes {
+		if p.window == id && !slices.ContainsFunc(cells, func(l *Layout) bool { return l.Pane == pid }) {
+			c.closePane(pid)
+		}
+	}
+	return nil
+}
+
+// Window returns a copy of the window with the given identifier.
+func (c *Client) Window(id int) (Window, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.windows[id]
+	if !ok {
+		return Window{}, false
+	}
+	return *w, true
+}
+
+// Windows returns copies of the windows of the session ordered by their
+// identifiers.
+func (c *Client) Windows() []Window {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	windows := make([]Window, 0, len(c.windows))
+	for _, w := range c.windows {
+		windows = append(windows, *w)
+	}
+	slices.SortFunc(windows, func(a, b Window) int { return a.ID - b.ID })
+	return windows
+}
+
+// CurrentWindow returns the identifier of the current window of the
+// session, or -1 if it's not known yet.
+func (c *Client) CurrentWindow() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.window
+}
+
+// Pane returns the pane with the given identifier, or nil if there's none.
+func (c *Client) Pane(id int) *Pane {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.panes[id]
+}
+
+// PaneBounds returns the area of the pane in its window.
+func (c *Client) PaneBounds(id int) uv.Rectangle {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.panes[id]; ok {
+		return p.area
+	}
+	return uv.Rectangle{}
+}
+
+// Command sends a command to tmux. The function, if any, is called with the
+// reply of the command from the goroutine running [Client.Run].
+func (c *Client) Command(cmd string, fn func(Reply)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := io.WriteString(c.w, cmd+"\n"); err != nil {
+		return fmt.Errorf("tmux: %w", err)
+	}
+	c.replies = append(c.replies, fn)
+	return nil
+}
+
+// Refresh requests the names and layouts of the windows of the session.
+// Clients should call it after attaching, since tmux only reports the
+// layouts when they change.
+func (c *Client) Refresh() error {
+	return c.Command(`list-windows -F "#{window_id} #{window_layout} #{window_name}"`, func(r Reply) {
+		if r.Err {
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, line := range r.Output {
+			fields := strings.SplitN(line, " ", 3) //nolint:mnd
+			id, ok := parseID(fields, 0, '@')
+			if !ok || len(fields) != 3 {
+				continue
+			}
+			if err := c.setLayout(id, fields[1]); err != nil {
+				c.logf("invalid layout of window @%d: %v", id, err)
+			}
+			c.windows[id].Name = fields[2]
+		}
+	})
+}
+
+// Resize sets the size of the client. tmux resizes the windows and reports
+// their new layouts.
+func (c *Client) Resize(width, height int) error {
+	return c.Command(fmt.Sprintf("refresh-client -C %dx%d", width, height), nil)
+}
+
+// SendKey sends a key press to the pane using send-keys. Printable text
+// without modifiers is sent literally, other keys are sent by their tmux
+// names. Key releases and keys that tmux can't name are ignored.
+func (c *Client) SendKey(pane int, k uv.KeyEvent) error {
+	if _, ok := k.(uv.KeyReleaseEvent); ok {
+		return nil
+	}
+	key := k.Key()
+	if key.Text != "" && !key.Mod.Contains(uv.ModCtrl) && !key.Mod.Contains(uv.ModAlt) {
+		return c.SendText(pane, key.Text)
+	}
+	name, ok := keyName(key)
+	if !ok {
+		return nil
+	}
+	return c.sendKeys(pane, quote(name))
+}
+
+// SendText sends text to the pane literally using send-keys.
+func (c *Client) SendText(pane int, text string) error {
+	if text == "" {
+		return nil
+	}
+	return c.sendKeys(pane, "-l "+quote(text))
+}
+
+func (c *Client) sendKeys(pane int, args string) error {
+	if c.Pane(pane) == nil {
+		return ErrUnknownPane
+	}
+	return c.Command(fmt.Sprintf("send-keys -t %%%d %s", pane, args), nil)
+}
+
+// Draw draws the panes of the window and the borders between them on the
+// given area of the screen.
+func (c *Client) Draw(scr uv.Screen, area uv.Rectangle, window int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.windows[window]
+	if !ok || w.Layout == nil {
+		return
+	}
+	c.drawCell(scr, area, w.Layout)
+}
+
+func (c *Client) drawCell(scr uv.Screen, area uv.Rectangle, l *Layout) {
+	if l.Type == LayoutPane {
+		if p, ok := c.panes[l.Pane]; ok {
+			if a := l.Bounds().Add(area.Min).Intersect(area); !a.Empty() {
+				p.emu.Draw(scr, a)
+			}
+		}
+		return
+	}
+	for i, child := range l.Children {
+		c.drawCell(scr, area, child)
+		if i == len(l.Children)-1 {
+			break
+		}
+		border, cell := uv.Rect(child.X+child.Width, l.Y, 1, l.Height), &VerticalBorder
+		if l.Type == LayoutTopBottom {
+			border, cell = uv.Rect(l.X, child.Y+child.Height, l.Width, 1), &HorizontalBorder
+		}
+		border = border.Add(area.Min).Intersect(area)
+		for y := border.Min.Y; y < border.Max.Y; y++ {
+			for x := border.Min.X; x < border.Max.X; x++ {
+				scr.SetCell(x, y, cell)
+			}
+		}
+	}
+}
+
+// Close closes the emulators of the panes. It doesn't detach from tmux, send
+// the "detach-client" command to do so.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, p := range c.panes {
+		_ = p.emu.Close()
+		delete(c.panes, id)
+	}
+	return nil
+}
+
+// parseID parses the identifier at index i of fields with the given prefix,
+// such as "%1" for panes or "@1" for windows.
+func parseID(fields []string, i int, prefix byte) (int, bool) {
+	if i >= len(fields) || len(fields[i]) < 2 || fields[i][0] != prefix { //nolint:mnd
+		return 0, false
+	}
+	id, err := strconv.Atoi(fields[i][1:])
+	return id, err == nil && id >= 0
+}
+
+// unescape decodes the pane output of control mode notifications, where
+// the characters below space and backslashes are written as 3 digit octal
+// escapes.
+func unescape(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b = append(b, (s[i+1]-'0')<<6|(s[i+2]-'0')<<3|(s[i+3]-'0'))
+			i += 3
+			continue
+		}
+		b = append(b, s[i])
+	}
+	return b
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
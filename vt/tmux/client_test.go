@@ -0,0 +1,218 @@
+package tmux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// runTranscript runs the client over a control mode transcript recorded from
+// tmux.
+func runTranscript(t *testing.T, c *Client, name string) {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	if err := c.Run(f); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+// lines returns the trimmed lines of the emulator screen.
+func lines(c *Client, pane int) []string {
+	return strings.Split(c.Pane(pane).Emulator().String(), "\n")
+}
+
+func TestClient(t *testing.T) {
+	var cmds bytes.Buffer
+	c := NewClient(&cmds)
+	defer c.Close() //nolint:errcheck
+
+	var events []string
+	var split uv.Rectangle
+	c.SetCallbacks(Callbacks{
+		WindowAdd:     func(w int) { events = append(events, "add @"+strconv.Itoa(w)) },
+		WindowClose:   func(w int) { events = append(events, "close @"+strconv.Itoa(w)) },
+		WindowRenamed: func(w int, name string) { events = append(events, "rename @"+strconv.Itoa(w)+" "+name) },
+		LayoutChange: func(w int) {
+			events = append(events, "layout @"+strconv.Itoa(w))
+			if split.Empty() {
+				split = c.PaneBounds(1)
+			}
+		},
+		ActivePane: func(w, p int) { events = append(events, "active @"+strconv.Itoa(w)+" %"+strconv.Itoa(p)) },
+		Exit:       func(string) { events = append(events, "exit") },
+	})
+
+	// The commands sent when the transcript was recorded.
+	if err := c.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	for range 6 {
+		_ = c.Command("", nil)
+	}
+	var bogus Reply
+	_ = c.Command("bogus-command", func(r Reply) { bogus = r })
+	_ = c.Command("kill-server", nil)
+
+	runTranscript(t, c, "control.txt")
+
+	wantEvents := []string{
+		"add @0",
+		"active @0 %1",
+		"layout @0",
+		"rename @0 my win",
+		"add @1",
+		"layout @0",
+		"active @0 %0",
+		"close @1",
+		"exit",
+	}
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Errorf("events = %q, want %q", events, wantEvents)
+	}
+	if want := uv.Rect(41, 0, 39, 24); split != want {
+		t.Errorf("split pane bounds = %v, want %v", split, want)
+	}
+
+	windows := c.Windows()
+	if len(windows) != 1 || windows[0].Name != "my win" || windows[0].Active != 0 {
+		t.Fatalf("windows = %+v", windows)
+	}
+	if l := windows[0].Layout; l == nil || l.Type != LayoutPane || l.Width != 80 || l.Height != 24 {
+		t.Errorf("layout = %+v, want a single 80x24 pane", l)
+	}
+	if c.Pane(1) != nil {
+		t.Errorf("pane %%1 still exists after it left the layout")
+	}
+
+	got := lines(c, 0)[:3]
+	want := []string{`# printf 'hi\033[31mred\033[m\n'`, "hired", "#"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pane output = %q, want %q", got, want)
+	}
+	if cell := c.Pane(0).Emulator().CellAt(2, 1); cell.Style.Fg != ansi.Red {
+		t.Errorf("cell color = %v, want %v", cell.Style.Fg, color.Color(ansi.Red))
+	}
+
+	if !bogus.Err || len(bogus.Output) != 1 || !strings.Contains(bogus.Output[0], "unknown command") {
+		t.Errorf("bogus command reply = %+v, want an error", bogus)
+	}
+	if !strings.HasPrefix(cmds.String(), `list-windows -F "#{window_id} #{window_layout} #{window_name}"`+"\n") {
+		t.Errorf("commands = %q", cmds.String())
+	}
+}
+
+func TestClientDCS(t *testing.T) {
+	c := NewClient(io.Discard)
+	defer c.Close() //nolint:errcheck
+	for range 5 {
+		_ = c.Command("", nil)
+	}
+
+	runTranscript(t, c, "control_cc.txt")
+
+	if got, want := c.PaneBounds(0), uv.Rect(0, 0, 100, 15); got != want {
+		t.Errorf("pane %%0 bounds = %v, want %v", got, want)
+	}
+	if got, want := c.PaneBounds(1), uv.Rect(0, 16, 100, 14); got != want {
+		t.Errorf("pane %%1 bounds = %v, want %v", got, want)
+	}
+	if e := c.Pane(1).Emulator(); e.Width() != 100 || e.Height() != 14 {
+		t.Errorf("pane %%1 size = %dx%d, want 100x14", e.Width(), e.Height())
+	}
+	if got, want := lines(c, 0)[:3], []string{"# echo ok", "ok", "#"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pane output = %q, want %q", got, want)
+	}
+
+	scr := uv.NewScreenBuffer(100, 30)
+	c.Draw(scr, scr.Bounds(), 0)
+	if got := scr.CellAt(0, 1).Content; got != "o" {
+		t.Errorf("drawn cell = %q, want %q", got, "o")
+	}
+	if got := scr.CellAt(50, 15).Content; got != HorizontalBorder.Content {
+		t.Errorf("border cell = %q, want %q", got, HorizontalBorder.Content)
+	}
+	if got := scr.CellAt(0, 16).Content; got != "#" {
+		t.Errorf("drawn cell = %q, want %q", got, "#")
+	}
+}
+
+func TestClientUnknownPanes(t *testing.T) {
+	c := NewClient(io.Discard)
+	defer c.Close() //nolint:errcheck
+
+	layout := func(l string) string {
+		return fmt.Sprintf("%%layout-change @0 %04x,%s", layoutChecksum(l), l)
+	}
+	c.HandleLine(layout("80x24,0,0{40x24,0,0,0,39x24,41,0,1}"))
+	c.HandleLine("%output %1 x")
+	c.HandleLine(layout("80x24,0,0,0"))
+
+	// tmux can send the output of a pane right after it's closed.
+	c.HandleLine("%output %1 late")
+	if c.Pane(1) != nil {
+		t.Errorf("pane %%1 came back after it was closed")
+	}
+
+	// Output of panes that aren't part of a layout yet is kept for a
+	// limited number of panes.
+	for i := range 2 * maxPendingPanes {
+		c.HandleLine(fmt.Sprintf("%%output %%%d x", 100+i))
+	}
+	if c.Pane(100) == nil {
+		t.Errorf("expected the output of pane %%100 to be kept")
+	}
+	if got := len(c.panes); got != 1+maxPendingPanes {
+		t.Errorf("panes = %d, want %d", got, 1+maxPendingPanes)
+	}
+}
+
+func TestSendKeys(t *testing.T) {
+	var cmds bytes.Buffer
+	c := NewClient(&cmds)
+	defer c.Close() //nolint:errcheck
+	c.HandleLine("%output %3 x")
+
+	_ = c.SendText(3, "a\"$\\\n")
+	_ = c.SendKey(3, uv.KeyPressEvent{Code: 'a', Text: "a"})
+	_ = c.SendKey(3, uv.KeyPressEvent{Code: 'c', Mod: uv.ModCtrl})
+	_ = c.SendKey(3, uv.KeyPressEvent{Code: uv.KeyUp, Mod: uv.ModAlt})
+	_ = c.SendKey(3, uv.KeyPressEvent{Code: uv.KeyF5, Mod: uv.ModShift})
+	_ = c.SendKey(3, uv.KeyPressEvent{Code: uv.KeyEnter})
+	_ = c.SendKey(3, uv.KeyReleaseEvent{Code: uv.KeyEnter})
+	if err := c.SendText(4, "x"); !errors.Is(err, ErrUnknownPane) {
+		t.Errorf("error = %v, want %v", err, ErrUnknownPane)
+	}
+
+	want := strings.Join([]string{
+		`send-keys -t %3 -l "a\"\$\\\012"`,
+		`send-keys -t %3 -l "a"`,
+		`send-keys -t %3 "C-c"`,
+		`send-keys -t %3 "M-Up"`,
+		`send-keys -t %3 "S-F5"`,
+		`send-keys -t %3 "Enter"`,
+	}, "\n") + "\n"
+	if got := cmds.String(); got != want {
+		t.Errorf("commands =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	got := unescape(`a\033[m\134\015\012\0\`)
+	if want := "a\x1b[m\\\r\n\\0\\"; string(got) != want {
+		t.Errorf("unescape = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package tmux
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// keyNames are the tmux names of the special keys.
+var keyNames = map[rune]string{
+	uv.KeyUp:         "Up",
+	uv.KeyDown:       "Down",
+	uv.KeyRight:      "Right",
+	uv.KeyLeft:       "Left",
+	uv.KeyInsert:     "IC",
+	uv.KeyDelete:     "DC",
+	uv.KeyPgUp:       "PPage",
+	uv.KeyPgDown:     "NPage",
+	uv.KeyHome:       "Home",
+	uv.KeyEnd:        "End",
+	uv.KeyEnter:      "Enter",
+	uv.KeyTab:        "Tab",
+	uv.KeyBackspace:  "BSpace",
+	uv.KeyEscape:     "Escape",
+	uv.KeySpace:      "Space",
+	uv.KeyKpEnter:    "KPEnter",
+	uv.KeyKpEqual:    "KP=",
+	uv.KeyKpMultiply: "KP*",
+	uv.KeyKpPlus:     "KP+",
+	uv.KeyKpMinus:    "KP-",
+	uv.KeyKpDecimal:  "KP.",
+	uv.KeyKpDivide:   "KP/",
+}
+
+func init() {
+	for i := range 10 {
+		keyNames[uv.KeyKp0+rune(i)] = "KP" + strconv.Itoa(i)
+	}
+	for i := range 20 {
+		keyNames[uv.KeyF1+rune(i)] = "F" + strconv.Itoa(i+1)
+	}
+}
+
+// keyName returns the tmux name of the key, such as "C-a" or "M-Up". It
+// returns false if tmux has no name for the key.
+func keyName(k uv.Key) (string, bool) {
+	name, ok := keyNames[k.Code]
+	if !ok {
+		if !unicode.IsPrint(k.Code) {
+			return "", false
+		}
+		name = string(k.Code)
+	}
+
+	var b strings.Builder
+	if k.Mod.Contains(uv.ModCtrl) {
+		b.WriteString("C-")
+	}
+	if k.Mod.Contains(uv.ModAlt) || k.Mod.Contains(uv.ModMeta) {
+		b.WriteString("M-")
+	}
+	if k.Mod.Contains(uv.ModShift) {
+		b.WriteString("S-")
+	}
+	b.WriteString(name)
+	return b.String(), true
+}
+
+// quote quotes an argument of a tmux command. Control characters are
+// escaped so that the argument fits on the command line.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := range len(s) {
+		switch c := s[i]; {
+		case c == '\\' || c == '"' || c == '$':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c == ansi.DEL:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,186 @@
+package tmux
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// LayoutType is the type of a layout cell.
+type LayoutType uint8
+
+// Layout cell types.
+const (
+	// LayoutPane is a cell holding a pane.
+	LayoutPane LayoutType = iota
+	// LayoutLeftRight is a cell split between its children from left to
+	// right.
+	LayoutLeftRight
+	// LayoutTopBottom is a cell split between its children from top to
+	// bottom.
+	LayoutTopBottom
+)
+
+// Layout is a cell of a tmux window layout. The children of a split are
+// separated by a one cell wide border.
+type Layout struct {
+	Type          LayoutType
+	X, Y          int
+	Width, Height int
+	// Pane is the pane identifier of a [LayoutPane] cell.
+	Pane int
+	// Children are the cells of a split.
+	Children []*Layout
+}
+
+// ErrLayoutChecksum is returned when the checksum of a layout doesn't match.
+var ErrLayoutChecksum = errors.New("tmux: layout checksum mismatch")
+
+// ParseLayout parses a tmux layout string such as the ones reported by
+// %layout-change notifications and the window_layout format:
+//
+//	b25d,80x24,0,0{40x24,0,0,0,39x24,41,0,1}
+//
+// The string starts with a checksum of the layout, followed by the root
+// cell. Splits list their children in braces when they are left to right
+// and in brackets when they are top to bottom. Cells can't extend past
+// 10000 columns or rows, the largest tmux window.
+func ParseLayout(s string) (*Layout, error) {
+	sum, layout, ok := strings.Cut(s, ",")
+	if !ok || len(sum) != 4 { //nolint:mnd
+		return nil, fmt.Errorf("tmux: invalid layout %q", s)
+	}
+	want, err := strconv.ParseUint(sum, 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("tmux: invalid layout checksum %q", sum)
+	}
+	if uint64(layoutChecksum(layout)) != want {
+		return nil, ErrLayoutChecksum
+	}
+
+	p := layoutParser{s: layout}
+	l, err := p.cell()
+	if err != nil {
+		return nil, err
+	}
+	if p.i != len(p.s) {
+		return nil, p.errorf("trailing data")
+	}
+	return l, nil
+}
+
+// Bounds returns the area of the cell.
+func (l *Layout) Bounds() uv.Rectangle {
+	return uv.Rect(l.X, l.Y, l.Width, l.Height)
+}
+
+// Panes returns the pane cells of the layout from left to right and top to
+// bottom.
+func (l *Layout) Panes() []*Layout {
+	if l.Type == LayoutPane {
+		return []*Layout{l}
+	}
+	var panes []*Layout
+	for _, c := range l.Children {
+		panes = append(panes, c.Panes()...)
+	}
+	return panes
+}
+
+// layoutChecksum returns the checksum of a layout as computed by tmux.
+func layoutChecksum(s string) uint16 {
+	var sum uint16
+	for i := range len(s) {
+		sum = (sum >> 1) + ((sum & 1) << 15) //nolint:mnd
+		sum += uint16(s[i])
+	}
+	return sum
+}
+
+// maxLayoutSize is the maximum width and height of a layout, the largest
+// window size allowed by tmux.
+const maxLayoutSize = 10000
+
+// layoutParser is a recursive descent parser of layout cells.
+type layoutParser struct {
+	s string
+	i int
+}
+
+func (p *layoutParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("tmux: invalid layout at offset %d: "+format, append([]any{p.i}, args...)...)
+}
+
+// cell parses a cell:
+//
+//	WxH,X,Y,ID
+//	WxH,X,Y{cell,cell...}
+//	WxH,X,Y[cell,cell...]
+func (p *layoutParser) cell() (*Layout, error) {
+	var l Layout
+	var err error
+	for _, f := range []struct {
+		v   *int
+		sep byte
+	}{{&l.Width, 'x'}, {&l.Height, ','}, {&l.X, ','}, {&l.Y, 0}} {
+		if *f.v, err = p.number(); err != nil {
+			return nil, err
+		}
+		if f.sep != 0 && !p.consume(f.sep) {
+			return nil, p.errorf("expected %q", f.sep)
+		}
+	}
+	if l.X+l.Width > maxLayoutSize || l.Y+l.Height > maxLayoutSize {
+		return nil, p.errorf("cell %dx%d,%d,%d too large", l.Width, l.Height, l.X, l.Y)
+	}
+
+	var end byte
+	switch {
+	case p.consume('{'):
+		l.Type, end = LayoutLeftRight, '}'
+	case p.consume('['):
+		l.Type, end = LayoutTopBottom, ']'
+	case p.consume(','):
+		l.Type = LayoutPane
+		l.Pane, err = p.number()
+		return &l, err
+	default:
+		return nil, p.errorf("expected a pane or a split")
+	}
+
+	for {
+		c, err := p.cell()
+		if err != nil {
+			return nil, err
+		}
+		l.Children = append(l.Children, c)
+		if p.consume(end) {
+			return &l, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected %q", end)
+		}
+	}
+}
+
+func (p *layoutParser) number() (int, error) {
+	start := p.i
+	for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+		p.i++
+	}
+	if start == p.i {
+		return 0, p.errorf("expected a number")
+	}
+	return strconv.Atoi(p.s[start:p.i]) //nolint:wrapcheck
+}
+
+func (p *layoutParser) consume(c byte) bool {
+	if p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package tmux
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseLayout(t *testing.T) {
+	cases := []struct {
+		name   string
+		layout string
+		want   *Layout
+	}{
+		{
+			name:   "pane",
+			layout: "b25d,80x24,0,0,0",
+			want:   &Layout{Width: 80, Height: 24, Pane: 0},
+		},
+		{
+			name:   "left right",
+			layout: "8205,80x24,0,0{40x24,0,0,0,39x24,41,0,1}",
+			want: &Layout{Type: LayoutLeftRight, Width: 80, Height: 24, Children: []*Layout{
+				{Width: 40, Height: 24, Pane: 0},
+				{X: 41, Width: 39, Height: 24, Pane: 1},
+			}},
+		},
+		{
+			name:   "nested",
+			layout: "d67e,80x24,0,0{40x24,0,0,0,39x24,41,0[39x12,41,0,1,39x11,41,13,2]}",
+			want: &Layout{Type: LayoutLeftRight, Width: 80, Height: 24, Children: []*Layout{
+				{Width: 40, Height: 24, Pane: 0},
+				{Type: LayoutTopBottom, X: 41, Width: 39, Height: 24, Children: []*Layout{
+					{X: 41, Width: 39, Height: 12, Pane: 1},
+					{X: 41, Y: 13, Width: 39, Height: 11, Pane: 2},
+				}},
+			}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLayout(tc.layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("layout = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseLayoutErrors(t *testing.T) {
+	for _, layout := range []string{
+		"",
+		"80x24,0,0,0",
+		"zzzz,80x24,0,0,0",
+		"b25e,80x24,0,0,0",
+		"46aa,80x24,0,0{40x24,0,0,0",
+		"d95a,80x24,0,0,0,",
+	} {
+		if _, err := ParseLayout(layout); err == nil {
+			t.Errorf("ParseLayout(%q) succeeded, want an error", layout)
+		}
+	}
+	for _, layout := range []string{
+		"99999x99999,0,0,0",
+		"80x24,9990,0,0",
+		"80x24,0,0{40x24,0,0,0,39x99999,41,0,1}",
+	} {
+		layout = fmt.Sprintf("%04x,%s", layoutChecksum(layout), layout)
+		if _, err := ParseLayout(layout); err == nil {
+			t.Errorf("ParseLayout(%q) succeeded, want an error", layout)
+		}
+	}
+	if _, err := ParseLayout("b25e,80x24,0,0,0"); !errors.Is(err, ErrLayoutChecksum) {
+		t.Errorf("error = %v, want %v", err, ErrLayoutChecksum)
+	}
+}
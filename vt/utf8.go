@@ -1,6 +1,7 @@
 package vt
 
 import (
+	"strings"
 	"unicode/utf8"
 
 	uv "github.com/charmbracelet/ultraviolet"
@@ -55,6 +56,7 @@ func (e *Emulator) handleGrapheme(content string, width int) {
 		// moves cursor down similar to [Terminal.linefeed] except it doesn't
 		// respects [ansi.LNM] mode.
 		// This will reset the phantom state i.e. pending wrap state.
+		e.scr.setWrapped(y, true)
 		e.index()
 		_, y = e.scr.CursorPosition()
 		x = 0
@@ -86,9 +88,10 @@ func (e *Emulator) handleGrapheme(content string, width int) {
 	}
 
 	e.scr.SetCell(x, y, &cell)
+	e.scr.protect(uv.Rect(x, y, max(1, cell.Width), 1), e.scr.cur.protected)
 
 	// Handle phantom state at the end of the line
-	e.atPhantom = awm && x >= e.scr.Width()-1
+	e.atPhantom = awm && x >= e.scr.lineWidth(y)-1
 	if !e.atPhantom {
 		x += cell.Width
 	}
@@ -96,3 +99,131 @@ func (e *Emulator) handleGrapheme(content string, width int) {
 	// NOTE: We don't reset the phantom state here, we handle it up above.
 	e.scr.setCursor(x, y, false)
 }
+
+// asciiStrings are the contents of the ASCII character cells. Sharing them
+// avoids allocating a string for each printed character.
+var asciiStrings = func() (s [utf8.RuneSelf]string) {
+	for i := range s {
+		s[i] = string(rune(i))
+	}
+	return s
+}()
+
+// printableLen returns the length of the printable text at the start of p,
+// made of printable ASCII characters and valid UTF-8 runes.
+func printableLen(p []byte) int {
+	i := 0
+	for i < len(p) {
+		if c := p[i]; c < utf8.RuneSelf {
+			if c < ansi.SP || c == ansi.DEL {
+				break
+			}
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRune(p[i:])
+		if r == utf8.RuneError && n <= 1 {
+			break
+		}
+		i += n
+	}
+	return i
+}
+
+// firstGrapheme returns the first grapheme of the printable text s and its
+// width. ASCII characters are graphemes of their own, as they are when the
+// parser hands them to [Emulator.handlePrint] one at a time.
+func firstGrapheme(s string) (string, int) {
+	if s[0] < utf8.RuneSelf {
+		return asciiStrings[s[0]], 1
+	}
+	cluster, width := ansi.FirstGraphemeCluster(s, ansi.GraphemeWidth)
+	for i := 1; i < len(cluster); i++ {
+		if cluster[i] < utf8.RuneSelf {
+			return ansi.FirstGraphemeCluster(s[:i], ansi.GraphemeWidth)
+		}
+	}
+	return cluster, width
+}
+
+// printText prints a run of printable text, as returned by [printableLen],
+// without going through the parser. It's equivalent to handling each of its
+// graphemes with [Emulator.handleGrapheme].
+func (e *Emulator) printText(s string) {
+	for len(s) > 0 {
+		if n := e.printLine(s); n > 0 {
+			s = s[n:]
+			continue
+		}
+		cluster, width := firstGrapheme(s)
+		if len(cluster) > 1 {
+			cluster = strings.Clone(cluster)
+		}
+		e.handleGrapheme(cluster, width)
+		s = s[len(cluster):]
+	}
+}
+
+// printLine prints the single width graphemes at the start of s that fit on
+// the cursor line and returns the number of bytes printed. The cells are
+// written in bulk, and the touched cells, the damage, the protected area,
+// and the cursor are updated once for the whole line. It prints nothing when
+// the first grapheme needs to be handled by [Emulator.handleGrapheme], such
+// as wide characters or characters mapped by a character set.
+func (e *Emulator) printLine(s string) int {
+	// Characters mapped by a character set are handled one at a time.
+	mapped := e.gsingle != 0 || e.charsets[e.gl] != nil
+	cluster, width := firstGrapheme(s)
+	if width != 1 || (mapped && len(cluster) == 1) {
+		return 0
+	}
+
+	awm := e.isModeSet(ansi.ModeAutoWrap)
+	x, y := e.scr.CursorPosition()
+	if e.atPhantom && awm {
+		e.scr.setWrapped(y, true)
+		e.index()
+		_, y = e.scr.CursorPosition()
+		x = 0
+	}
+
+	cell := uv.Cell{
+		Width: 1,
+		Style: e.scr.cursorPen(),
+		Link:  e.scr.cursorLink(),
+	}
+	line := e.scr.buf.Line(y)
+	lineWidth := e.scr.lineWidth(y)
+	start, n := x, 0
+	for {
+		if len(cluster) == 1 {
+			cell.Content = cluster
+			e.lastChar = rune(cluster[0])
+		} else {
+			cell.Content = strings.Clone(cluster)
+		}
+		line.Set(x, &cell)
+		n += len(cluster)
+		if x >= lineWidth-1 || n == len(s) {
+			break
+		}
+		cluster, width = firstGrapheme(s[n:])
+		if width != 1 || (mapped && len(cluster) == 1) {
+			break
+		}
+		x++
+	}
+
+	area := uv.Rect(start, y, x-start+1, 1)
+	e.scr.buf.TouchLine(start, y, area.Dx())
+	e.scr.addDamage(CellDamage{X: start, Y: y, Width: area.Dx()})
+	e.scr.protect(area, e.scr.cur.protected)
+
+	// Handle phantom state at the end of the line
+	e.atPhantom = awm && x >= lineWidth-1
+	if !e.atPhantom {
+		x++
+	}
+	e.scr.setCursor(x, y, false)
+	return n
+}
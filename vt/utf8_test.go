@@ -0,0 +1,129 @@
+package vt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi/parser"
+)
+
+// parserWrite writes p one byte at a time through the parser, like
+// [Emulator.Write] does for everything but printable text.
+func parserWrite(e *Emulator, p []byte) {
+	for i := range p {
+		e.parser.Advance(p[i])
+		state := e.parser.State()
+		if len(e.grapheme) > 0 {
+			if (e.lastState == parser.GroundState && state != parser.Utf8State) || i == len(p)-1 {
+				e.flushGrapheme()
+			}
+		}
+		e.lastState = state
+	}
+}
+
+func TestWritePrintable(t *testing.T) {
+	cases := []struct {
+		name  string
+		input []string
+	}{
+		{"wrap", []string{"0123456789abcdefghijklmnopqrstuvwxyz"}},
+		{"no autowrap", []string{"\x1b[?7l0123456789abcdefghijklmnopqrstuvwxyz\r\nnext"}},
+		{"styles", []string{"\x1b[1;31mred\x1b[m \x1b[44mblue background text\x1b[m"}},
+		{"wide", []string{"ab東京cdefghijk世界 and more text"}},
+		{"wide at edge", []string{"012345678世界"}},
+		{"graphemes", []string{"e\u0301 👩‍👩‍👧 🇫🇷 \u06001 x\u200d"}},
+		{"split utf8", []string{"caf\xc3", "\xa9 na\xc3", "\xafve"}},
+		{"split grapheme", []string{"e", "\u0301e\u0301"}},
+		{"invalid utf8", []string{"a\xffb\xc3(c\x80d"}},
+		{"charsets", []string{"\x1b(0lqqk\x1b(B ok \x1bNq\x1bOq plain"}},
+		{"repeat", []string{"ab\x1b[3b", "é\x1b[2b"}},
+		{"protected", []string{"\x1b[1\"qprotected\x1b[0\"q\x1b[1;1H\x1b[?2K"}},
+		{"double width line", []string{"\x1b#6double width line"}},
+		{"insert margins", []string{"\x1b[?69h\x1b[3;6s\x1b[1;3Hmargins text"}},
+		{"scroll", []string{strings.Repeat("line of text\r\n", 8)}},
+		{"log", []string{logOutput(20, true, true)}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, want := NewEmulator(10, 4), NewEmulator(10, 4)
+			for _, s := range tc.input {
+				_, _ = got.WriteString(s)
+				parserWrite(want, []byte(s))
+			}
+			for y := -want.ScrollbackLen(); y < want.Height(); y++ {
+				gl, gw := got.scr.lineAt(y)
+				wl, ww := want.scr.lineAt(y)
+				if !reflect.DeepEqual(gl, wl) {
+					t.Errorf("line %d = %q, want %q", y, gl.String(), wl.String())
+				}
+				if gw != ww {
+					t.Errorf("line %d wrapped = %v, want %v", y, gw, ww)
+				}
+			}
+			if got.CursorPosition() != want.CursorPosition() || got.atPhantom != want.atPhantom {
+				t.Errorf("cursor = %v (phantom %v), want %v (phantom %v)",
+					got.CursorPosition(), got.atPhantom, want.CursorPosition(), want.atPhantom)
+			}
+			if got.lastChar != want.lastChar || got.gsingle != want.gsingle {
+				t.Errorf("last char = %q, single shift = %d, want %q, %d", got.lastChar, got.gsingle, want.lastChar, want.gsingle)
+			}
+			for y := range want.Height() {
+				for x := range want.Width() {
+					if got.scr.isProtected(x, y) != want.scr.isProtected(x, y) {
+						t.Errorf("cell %d,%d protected = %v", x, y, got.scr.isProtected(x, y))
+					}
+				}
+			}
+		})
+	}
+}
+
+// logOutput returns n lines of a log as printed by a program. Colored logs
+// style the timestamps and levels, and UTF-8 logs include accented, wide,
+// and emoji characters.
+func logOutput(n int, color, utf8 bool) string {
+	levels := []string{"INFO", "WARN", "DEBUG", "ERROR"}
+	var b strings.Builder
+	for i := range n {
+		level := levels[i%len(levels)]
+		if color {
+			fmt.Fprintf(&b, "\x1b[2m2024-01-01T00:%02d:%02dZ\x1b[m \x1b[%dm%-5s\x1b[m ", i/60%60, i%60, 31+i%4, level)
+		} else {
+			fmt.Fprintf(&b, "2024-01-01T00:%02d:%02dZ %-5s ", i/60%60, i%60, level)
+		}
+		fmt.Fprintf(&b, "request handled\tmethod=GET path=/api/v1/items/%d status=200 duration=%dms", i, i*7%1000)
+		if utf8 {
+			b.WriteString(" user=José city=東京 mood=🙂")
+		}
+		if i%10 == 0 {
+			b.WriteString(" trace=" + strings.Repeat("0123456789abcdef", 8))
+		}
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+func BenchmarkWrite(b *testing.B) {
+	for _, bc := range []struct {
+		name        string
+		color, utf8 bool
+	}{
+		{"plain", false, false},
+		{"color", true, false},
+		{"utf8", true, true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			p := []byte(logOutput(10000, bc.color, bc.utf8))
+			e := NewEmulator(120, 40)
+			b.SetBytes(int64(len(p)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				_, _ = e.Write(p)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package vt
+
+import (
+	"io"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// WindowOp is a window manipulation requested by a program using XTWINOPS.
+type WindowOp int
+
+// Window operations that are forwarded to the host.
+const (
+	// WindowOpDeiconify de-iconifies the window.
+	WindowOpDeiconify WindowOp = 1
+	// WindowOpIconify iconifies the window.
+	WindowOpIconify WindowOp = 2
+	// WindowOpMove moves the window to the x and y pixel position in the
+	// parameters.
+	WindowOpMove WindowOp = 3
+	// WindowOpResize resizes the window to the height and width in pixels in
+	// the parameters.
+	WindowOpResize WindowOp = 4
+	// WindowOpRaise raises the window to the front of the stacking order.
+	WindowOpRaise WindowOp = 5
+	// WindowOpLower lowers the window to the bottom of the stacking order.
+	WindowOpLower WindowOp = 6
+	// WindowOpRefresh refreshes the window.
+	WindowOpRefresh WindowOp = 7
+	// WindowOpResizeCells resizes the text area to the height and width in
+	// cells in the parameters.
+	WindowOpResizeCells WindowOp = 8
+	// WindowOpMaximize maximizes or restores the window. The parameters
+	// select the dimensions as in xterm.
+	WindowOpMaximize WindowOp = 9
+	// WindowOpFullScreen enters, exits, or toggles full screen mode.
+	WindowOpFullScreen WindowOp = 10
+	// WindowOpResizeLines resizes the text area to the number of lines in
+	// the parameters (DECSLPP).
+	WindowOpResizeLines WindowOp = 24
+)
+
+// WindowRequest is a window manipulation request sent by a program using
+// XTWINOPS. The emulator doesn't act on it, it's up to the host to decide
+// whether to honor it.
+type WindowRequest struct {
+	Op WindowOp
+	// Params are the parameters of the operation. Missing parameters are -1.
+	Params []int
+}
+
+// maxTitleStack is the maximum number of entries in the title stack.
+const maxTitleStack = 10
+
+// titleEntry is a title stack entry.
+type titleEntry struct {
+	title, iconName string
+}
+
+// handleWindowOp handles XTWINOPS sequences. The title stack and the size
+// reports are handled by the emulator while the other operations are
+// forwarded to [Callbacks.WindowRequest]. Reporting the title and icon name
+// is not supported because it allows programs to inject input.
+//
+//	CSI Ps ; Ps ; Ps t
+func (e *Emulator) handleWindowOp(params ansi.Params) bool {
+	n, _, _ := params.Param(0, 0)
+	cw, ch := e.CellSize()
+	switch {
+	case n == 11: // Report window state
+		_, _ = io.WriteString(e.pw, ansi.WindowOp(1)) // Not iconified
+	case n == 14: // Report text area size in pixels
+		_, _ = io.WriteString(e.pw, ansi.WindowOp(4, e.Height()*ch, e.Width()*cw))
+	case n == 16: // Report cell size in pixels
+		_, _ = io.WriteString(e.pw, ansi.WindowOp(6, ch, cw))
+	case n == 18: // Report text area size in characters
+		_, _ = io.WriteString(e.pw, ansi.WindowOp(8, e.Height(), e.Width()))
+	case n == 19: // Report screen size in characters
+		_, _ = io.WriteString(e.pw, ansi.WindowOp(9, e.Height(), e.Width()))
+	case n == 22: // Push title
+		which, _, _ := params.Param(1, 0)
+		e.pushTitle(which)
+	case n == 23: // Pop title
+		which, _, _ := params.Param(1, 0)
+		e.popTitle(which)
+	case n >= 1 && n <= 10, n >= int(WindowOpResizeLines):
+		req := WindowRequest{Op: WindowOp(n)}
+		if n >= int(WindowOpResizeLines) {
+			req.Op, req.Params = WindowOpResizeLines, []int{n}
+		} else {
+			for i := 1; i < len(params); i++ {
+				req.Params = append(req.Params, params[i].Param(-1))
+			}
+		}
+		if e.cb.WindowRequest != nil {
+			e.cb.WindowRequest(req)
+		}
+		emit(&e.events, req)
+	default:
+		return false
+	}
+	return true
+}
+
+// pushTitle saves the window title and icon name on the title stack. Which
+// is 0 for both, 1 for the icon name, and 2 for the window title. Both are
+// always saved, which only matters when restoring them. The oldest entry is
+// dropped when the stack is full.
+func (e *Emulator) pushTitle(which int) {
+	if which < 0 || which > 2 {
+		return
+	}
+	if len(e.titles) >= maxTitleStack {
+		e.titles = append(e.titles[:0], e.titles[1:]...)
+	}
+	e.titles = append(e.titles, titleEntry{title: e.title, iconName: e.iconName})
+}
+
+// popTitle restores the window title, the icon name, or both from the title
+// stack depending on which, see [Emulator.pushTitle].
+func (e *Emulator) popTitle(which int) {
+	if which < 0 || which > 2 || len(e.titles) == 0 {
+		return
+	}
+	t := e.titles[len(e.titles)-1]
+	e.titles = e.titles[:len(e.titles)-1]
+	if which != 2 {
+		e.iconName = t.iconName
+		if e.cb.IconName != nil {
+			e.cb.IconName(t.iconName)
+		}
+		emit(&e.events, IconNameChanged{IconName: t.iconName})
+	}
+	if which != 1 {
+		e.title = t.title
+		if e.cb.Title != nil {
+			e.cb.Title(t.title)
+		}
+		emit(&e.events, TitleChanged{Title: t.title})
+	}
+}
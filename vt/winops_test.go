@@ -0,0 +1,87 @@
+package vt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestWindowOpReports(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"window state", "\x1b[11t", "\x1b[1t"},
+		{"text area pixels", "\x1b[14t", "\x1b[4;60;80t"},
+		{"cell size", "\x1b[16t", "\x1b[6;20;8t"},
+		{"text area cells", "\x1b[18t", "\x1b[8;3;10t"},
+		{"screen cells", "\x1b[19t", "\x1b[9;3;10t"},
+		{"title report", "\x1b[21t", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEmulator(10, 3)
+			e.SetCellSize(8, 20)
+			got := readInput(t, e, func() {
+				_, _ = e.WriteString(tc.input)
+			})
+			if got != tc.want {
+				t.Errorf("reply = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTitleStack(t *testing.T) {
+	var titles, icons []string
+	e := NewEmulator(10, 3)
+	e.SetCallbacks(Callbacks{
+		Title:    func(s string) { titles = append(titles, s) },
+		IconName: func(s string) { icons = append(icons, s) },
+	})
+	_, _ = e.WriteString(ansi.SetIconNameWindowTitle("shell") +
+		"\x1b[22;0t" + ansi.SetIconNameWindowTitle("vim") +
+		"\x1b[22;2t" + ansi.SetWindowTitle("vim file") +
+		"\x1b[23;2t" + "\x1b[23;0t" + "\x1b[23;0t")
+
+	if want := []string{"shell", "vim", "vim file", "vim", "shell"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("titles = %q, want %q", titles, want)
+	}
+	if want := []string{"shell", "vim", "shell"}; !reflect.DeepEqual(icons, want) {
+		t.Errorf("icon names = %q, want %q", icons, want)
+	}
+}
+
+func TestTitleStackLimit(t *testing.T) {
+	e := NewEmulator(10, 3)
+	for range maxTitleStack + 5 {
+		_, _ = e.WriteString("\x1b[22t")
+	}
+	if len(e.titles) != maxTitleStack {
+		t.Errorf("title stack size = %d, want %d", len(e.titles), maxTitleStack)
+	}
+}
+
+func TestWindowRequest(t *testing.T) {
+	var got []WindowRequest
+	e := NewEmulator(10, 3)
+	e.SetCallbacks(Callbacks{WindowRequest: func(req WindowRequest) {
+		got = append(got, req)
+	}})
+	_, _ = e.WriteString("\x1b[2t\x1b[3;10;20t\x1b[8;40t\x1b[48t")
+	want := []WindowRequest{
+		{Op: WindowOpIconify},
+		{Op: WindowOpMove, Params: []int{10, 20}},
+		{Op: WindowOpResizeCells, Params: []int{40}},
+		{Op: WindowOpResizeLines, Params: []int{48}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %+v, want %+v", got, want)
+	}
+	if e.Width() != 10 || e.Height() != 3 {
+		t.Errorf("size = %dx%d, want 10x3", e.Width(), e.Height())
+	}
+}
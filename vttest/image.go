@@ -4,10 +4,12 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"reflect"
 
 	uv "github.com/charmbracelet/ultraviolet"
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
+	xdraw "golang.org/x/image/draw"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/gomono"
 	"golang.org/x/image/font/gofont/gomonobold"
@@ -44,6 +46,15 @@ var DefaultDrawer = func() *Drawer {
 	}
 }()
 
+// Line size attributes as reported by a LineSize(y int) method, see
+// [vt.LineSize].
+const (
+	lineSingle = iota
+	lineDoubleWidth
+	lineDoubleHeightTop
+	lineDoubleHeightBottom
+)
+
 // Drawer contains options for drawing a terminal emulator screen to an image.
 type Drawer struct {
 	// CellWidth is the width of each cell in pixels. Default is 10.
@@ -67,7 +78,11 @@ type Drawer struct {
 // Draw draws a [uv.Screen] to an image using the drawer options.
 //
 // If s implements a [BackgroundColor]() method, it is used to fill the
-// background. Otherwise, [color.Black] is used.
+// background. Otherwise, [color.Black] is used. If s implements a
+// [DrawImages]() method, it is used to draw images, such as Sixel graphics, on
+// top of the cells. If s implements a [LineSize](y int) method returning an
+// unsigned integer, such as [vt.LineSize], lines that are not single width
+// are drawn scaled.
 func (d *Drawer) Draw(t uv.Screen) image.Image {
 	opt := *d
 	if opt.CellWidth <= 0 {
@@ -160,6 +175,35 @@ func (d *Drawer) Draw(t uv.Screen) image.Image {
 		}
 	}
 
+	// Scale double-width and double-height lines
+	if lineSize := lineSizeFunc(t); lineSize != nil {
+		for y := range height {
+			size := lineSize(y)
+			if size == lineSingle {
+				continue
+			}
+			py := y * opt.CellHeight
+			src := image.Rect(0, py, width*opt.CellWidth/2, py+opt.CellHeight)
+			switch size {
+			case lineDoubleHeightTop:
+				src.Max.Y -= opt.CellHeight / 2
+			case lineDoubleHeightBottom:
+				src.Min.Y += opt.CellHeight / 2
+			}
+			line := image.NewRGBA(src)
+			draw.Draw(line, src, img, src.Min, draw.Src)
+			dst := image.Rect(0, py, width*opt.CellWidth, py+opt.CellHeight)
+			xdraw.NearestNeighbor.Scale(img, dst, line, src, draw.Src, nil)
+		}
+	}
+
+	// Draw images on top of the cells
+	if ti, ok := t.(interface {
+		DrawImages(dst draw.Image, cellWidth, cellHeight int)
+	}); ok {
+		ti.DrawImages(img, opt.CellWidth, opt.CellHeight)
+	}
+
 	return img
 }
 
@@ -169,3 +213,25 @@ func (t *Terminal) Image() image.Image {
 	defer t.mu.Unlock()
 	return DefaultDrawer.Draw(t.Emulator)
 }
+
+// lineSizeFunc returns the LineSize(y int) method of t, if any. The method is
+// looked up by name so that its result can be any unsigned integer type,
+// such as [vt.LineSize].
+func lineSizeFunc(t any) func(y int) uint64 {
+	m := reflect.ValueOf(t).MethodByName("LineSize")
+	if !m.IsValid() {
+		return nil
+	}
+	mt := m.Type()
+	if mt.NumIn() != 1 || mt.In(0).Kind() != reflect.Int || mt.NumOut() != 1 {
+		return nil
+	}
+	switch mt.Out(0).Kind() { //nolint:exhaustive
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil
+	}
+	return func(y int) uint64 {
+		return m.Call([]reflect.Value{reflect.ValueOf(y)})[0].Uint()
+	}
+}